@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/julz/knightrider/pkg/apply"
+	"github.com/spf13/cobra"
+)
+
+var applyFile string
+var applyTimeout time.Duration
+
+func init() {
+	applyCmd.Long = `apply generates a knative object and applies it with kubectl, or applies every object in a yml or json file (use '-f -' for stdin).
+
+Objects from a file are applied in the order their references to each other need: secrets, then the service accounts using them, then build templates, then builds and configurations, then routes and services. Routes are only applied once the configurations they send traffic to are ready. Anything referenced which isn't in the file has to exist in the cluster already, and missing references are reported before anything is applied.`
+	applyCmd.Example = `  kr apply service my-service docker.io/busybox
+  kr apply -f app.yaml
+  kr export -n staging | kr apply -n prod -f -`
+	applyCmd.Args = cobra.NoArgs
+	applyCmd.Run = func(cmd *cobra.Command, args []string) {
+		if applyFile == "" {
+			cmd.Help()
+			return
+		}
+
+		objects := readManifest(applyFile)
+		if dryRun {
+			levels, err := apply.Plan(client(), objects)
+			if err != nil {
+				fatalF("Error: %s\n", err)
+			}
+
+			for _, level := range levels {
+				printYaml(level...)
+			}
+
+			return
+		}
+
+		a := &apply.Applier{Client: client(), Timeout: applyTimeout, Log: os.Stderr}
+		if err := a.Apply(objects); err != nil {
+			fatalF("Error: %s\n", err)
+		}
+	}
+
+	applyCmd.Flags().StringVarP(&applyFile, "filename", "f", "", "yml or json file of objects to apply, or - for stdin")
+	applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "check references and print the objects in the order they would be applied, without applying them")
+	applyCmd.Flags().DurationVar(&applyTimeout, "timeout", 5*time.Minute, "how long to wait for each configuration a route needs to become ready, or 0 to wait forever")
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/julz/knightrider/pkg/kube"
+	"github.com/julz/knightrider/pkg/traffic"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/spf13/cobra"
+)
+
+var green string
+var promote bool
+
+var bluegreen = &cobra.Command{
+	Use:   "bluegreen [service or route name]",
+	Short: "preview a revision as a named 0% traffic target, then promote it",
+	Long: `bluegreen adds the --green revision to a route as a named target with 0% of the traffic, so it can be previewed at its own url. With --promote all traffic is moved to green, and the previously serving revision stays reachable as a named 'blue' target.
+
+Services can't have named targets, so for services a separate NAME-preview route is used for the named targets and --promote pins the service to green.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if green == "" {
+			fatalF("Error: --green is required\n")
+		}
+
+		// services own a route of the same name, so check for a service first
+		resource := kube.Services
+		if err := client().Get(kube.Services, args[0], &serving.Service{}); kube.IsNotFound(err) {
+			resource = kube.Routes
+		} else if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		operation, target := traffic.Preview, traffic.GreenTarget
+		if promote {
+			operation, target = traffic.Promote, traffic.BlueTarget
+		}
+
+		objects, url, err := operation(client(), resource, args[0], green)
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		applyOrPrint(objects...)
+
+		if url == "" {
+			url = "(unknown until the route has a domain)"
+		}
+
+		fmt.Fprintf(os.Stderr, "%s is reachable at %s\n", target, url)
+	},
+}
+
+func init() {
+	bluegreen.Flags().StringVar(&green, "green", "", "revision to preview or promote")
+	bluegreen.Flags().BoolVar(&promote, "promote", false, "send all traffic to green, keeping the current revision reachable as blue")
+	bluegreen.Flags().BoolVar(&dryRun, "dry-run", false, "print the resulting yml instead of applying it")
+
+	root.AddCommand(bluegreen)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/julz/knightrider/pkg/builds"
+	"github.com/julz/knightrider/pkg/kube"
+	"github.com/spf13/cobra"
+)
+
+var buildCmd = &cobra.Command{
+	Use:   "build [name]",
+	Short: "create a build, e.g. of a local directory, or rerun, cancel and clean up builds",
+	Long: `build creates a build, just like 'kr create build'. Its subcommands rerun, cancel and clean up builds.
+
+With --local-dir, the build's source is the files in a local directory, so you can try out a build (or a build template) without committing and pushing first. Files ignored by .gitignore or .dockerignore are left out. The files are pushed to --source-image as an image with a single layer, which the build unpacks in to /workspace before its steps run. The registry credentials from 'docker login' are used to push, and the build's service account needs to be able to pull.`,
+	Example: `kr build mybuild --local-dir . --source-image docker.io/me/app-source -t kaniko -a IMAGE=docker.io/me/app --wait
+kr build mybuild --local-dir . --source-image localhost:5000/app-source --insecure-registry --dockerfile Dockerfile --image localhost:5000/app`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		kubectlVerb = "create"
+		fetchTemplate = !skipPreflight
+		generateBuild.Run(cmd, args)
+		runKubectl("create", true)
+	},
+}
+
+var buildRerun = &cobra.Command{
+	Use:   "rerun [name]",
+	Short: "run a build again",
+	Long:  "rerun creates a copy of a build, which runs it again with the same spec. The copy is named after the build with an incremented suffix (my-build-2, my-build-3, ...) and is annotated with the build it was copied from.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := builds.Rerun(client(), args[0])
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		fmt.Printf("build %s created\n", b.Name)
+		if wait {
+			waitForBuild(b.Name)
+		}
+	},
+}
+
+var buildCancel = &cobra.Command{
+	Use:   "cancel [name]",
+	Short: "stop a running build",
+	Long:  "cancel stops a running build by deleting its pod, giving the running step the usual grace period to shut down.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pod, err := builds.Cancel(client(), args[0])
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		fmt.Printf("build %s cancelled (deleted pod %s)\n", args[0], pod)
+	},
+}
+
+var keepBuilds int
+
+var buildGC = &cobra.Command{
+	Use:   "gc",
+	Short: "delete old completed builds",
+	Long:  "gc deletes completed builds, keeping the --keep most recently completed builds of each source repository. Running builds, and builds which belong to a revision, are never deleted.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if keepBuilds < 0 {
+			fatalF("Error: --keep can't be negative\n")
+		}
+
+		prunable, err := builds.Prunable(client(), keepBuilds)
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		for _, b := range prunable {
+			if dryRun {
+				fmt.Printf("would delete build %s\n", b.Name)
+				continue
+			}
+
+			if err := client().Delete(kube.Builds, b.Name); err != nil && !kube.IsNotFound(err) {
+				fatalF("Error: %s\n", err)
+			}
+
+			fmt.Printf("deleted build %s\n", b.Name)
+		}
+	},
+}
+
+func init() {
+	buildCmd.Flags().BoolVar(&skipPreflight, "skip-preflight", false, "don't check that the service account, secrets, volumes and build template the build references exist")
+	// only kr build pushes local directories, so generating, deleting or
+	// patching never pushes an image as a side effect
+	buildCmd.Flags().StringVar(&localDir, "local-dir", "", "use the files in this directory as the source, without committing and pushing them (files ignored by .gitignore or .dockerignore are left out)")
+	buildCmd.Flags().StringVar(&sourceImage, "source-image", "", "with --local-dir, the repository to push the directory to, as an image the build unpacks")
+	buildCmd.Flags().BoolVar(&insecureRegistry, "insecure-registry", false, "with --local-dir, push and pull the source image over http")
+	buildCmd.Flags().BoolVar(&installTemplate, "install-template", false, "if the -t build template isn't in the cluster, install it from the catalog without asking")
+
+	buildRerun.Flags().BoolVar(&wait, "wait", false, "wait for the build to complete and exit non-zero if it fails")
+	buildRerun.Flags().DurationVar(&waitTimeout, "timeout", 0, "how long to --wait before giving up, or 0 to wait forever")
+	buildRerun.Flags().StringVar(&junitReport, "junit", "", "when using --wait, write a junit xml report with a test case per build step to this file")
+
+	buildGC.Flags().IntVar(&keepBuilds, "keep", 5, "how many completed builds of each source to keep")
+	buildGC.Flags().BoolVar(&dryRun, "dry-run", false, "print the builds which would be deleted, without deleting them")
+
+	buildCmd.AddCommand(buildRerun, buildCancel, buildGC)
+	root.AddCommand(buildCmd)
+}
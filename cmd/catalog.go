@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/julz/knightrider/pkg/catalog"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var catalogDir string
+
+// installTemplate is true if a missing build template should be installed
+// from the catalog without asking
+var installTemplate bool
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "list, show and install well-known build templates",
+	Long: `catalog has build templates for the common ways of building images (kaniko, buildpacks, jib) built in, so you don't need to copy their yml around. Add your own (or replace the built in ones) by putting BuildTemplate yml in --catalog-dir.
+
+When you apply, create or replace something with a -t template which isn't installed in the cluster, kr offers to install it from the catalog.`,
+}
+
+var catalogList = &cobra.Command{
+	Use:   "list",
+	Short: "list the build templates in the catalog",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tSOURCE\tDESCRIPTION")
+		for _, e := range loadCatalog().List() {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Name, e.Version, e.Source, e.Description)
+		}
+
+		w.Flush()
+	},
+}
+
+var catalogShow = &cobra.Command{
+	Use:   "show [name]",
+	Short: "print the yml of a build template in the catalog",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printYaml(catalogEntry(args[0]).Template)
+	},
+}
+
+var catalogInstall = &cobra.Command{
+	Use:   "install [name]",
+	Short: "install a build template from the catalog in to the cluster",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		applyOrPrint(catalogEntry(args[0]).Template)
+	},
+}
+
+func init() {
+	defaultDir := os.Getenv("KR_CATALOG_DIR")
+	if defaultDir == "" && os.Getenv("HOME") != "" {
+		defaultDir = filepath.Join(os.Getenv("HOME"), ".kr", "catalog")
+	}
+
+	root.PersistentFlags().StringVar(&catalogDir, "catalog-dir", defaultDir, "directory of your own build template yml to add to the catalog (defaults to $KR_CATALOG_DIR or ~/.kr/catalog)")
+	catalogInstall.Flags().BoolVar(&dryRun, "dry-run", false, "print the build template instead of installing it")
+
+	catalogCmd.AddCommand(catalogList, catalogShow, catalogInstall)
+	root.AddCommand(catalogCmd)
+}
+
+func loadCatalog() *catalog.Catalog {
+	c, err := catalog.Load(catalogDir)
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	return c
+}
+
+func catalogEntry(name string) catalog.Entry {
+	e, ok := loadCatalog().Get(name)
+	if !ok {
+		fatalF("Error: there's no build template called %s in the catalog, see 'kr catalog list'\n", name)
+	}
+
+	return e
+}
+
+// installFromCatalog offers to install the build template passed with -t from
+// the catalog, when it isn't in the cluster, returning it if it was installed
+func installFromCatalog() *build.BuildTemplate {
+	e, ok := loadCatalog().Get(template)
+	if !ok {
+		return nil
+	}
+
+	if !installTemplate {
+		if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Fprintf(os.Stderr, "build template %s is not installed, pass --install-template to install it from the catalog\n", template)
+			return nil
+		}
+
+		fmt.Fprintf(os.Stderr, "build template %s is not installed, install %s %s from the catalog? [y/N] ", template, e.Name, e.Version)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			return nil
+		}
+	}
+
+	if err := client().Apply(e.Template); err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "installed build template %s %s\n", e.Name, e.Version)
+	return e.Template
+}
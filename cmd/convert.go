@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/julz/knightrider/pkg/convert"
+	"github.com/spf13/cobra"
+)
+
+var convertFile, convertRegistry string
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "convert other kinds of yml in to knative yml",
+}
+
+var convertDeployment = &cobra.Command{
+	Use:   "deployment -f FILE",
+	Short: "convert kubernetes deployments, and the services selecting them, in to knative services",
+	Long: `convert deployment reads kubernetes Deployments and Services from a yml or json file (use '-f -' for stdin) and writes a knative Service for each Deployment to stdout. Other objects are written out unchanged.
+
+Anything which knative can't do (extra containers, volumes, node selectors, ...) is dropped, with a warning on stderr.`,
+	Example: `  kr convert deployment -f deploy.yaml | kubectl apply -f -`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if convertFile == "" {
+			fatalF("Error: a file is required, use '-f -' for stdin\n")
+		}
+
+		objects, warnings, err := convert.Deployments(readManifest(convertFile))
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		printWarnings(warnings)
+		printYaml(objects...)
+	},
+}
+
+var convertCompose = &cobra.Command{
+	Use:   "compose -f FILE",
+	Short: "convert docker-compose services in to knative services",
+	Long: `convert compose reads a docker-compose file and writes a knative Service for each of its services, and a Secret for each of its file secrets, to stdout.
+
+Services with a build context are built from a git source (--git-repo or --from-cwd) using the kaniko build template (or another which takes IMAGE and DOCKERFILE arguments), and pushed to --registry. Build contexts are taken to be relative to the root of the git repository.
+
+Anything which knative can't do (volumes, depends_on, extra ports, ...) is dropped, with a warning on stderr.`,
+	Example: `  kr convert compose -f docker-compose.yml --from-cwd --registry docker.io/me -s buildbot | kubectl apply -f -`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if convertFile == "" {
+			fatalF("Error: a file is required, use '-f -' for stdin\n")
+		}
+
+		compose := convert.Compose{
+			Dir:            filepath.Dir(convertFile),
+			Registry:       convertRegistry,
+			Source:         gitSourceOptions(),
+			Template:       template,
+			ServiceAccount: serviceAccount,
+		}
+
+		f := os.Stdin
+		if convertFile != "-" {
+			var err error
+			if f, err = os.Open(convertFile); err != nil {
+				fatalF("Error: %s\n", err)
+			}
+
+			defer f.Close()
+		}
+
+		objects, warnings, err := compose.Convert(f)
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		printWarnings(warnings)
+		printYaml(objects...)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{convertDeployment, convertCompose} {
+		cmd.Flags().StringVarP(&convertFile, "filename", "f", "", "yml or json file to convert, or - for stdin")
+	}
+
+	convertCompose.Flags().StringVar(&convertRegistry, "registry", "", "registry to push images built from build contexts to, e.g. docker.io/me")
+	convertCompose.Flags().StringVarP(&repo, "git-repo", "u", "", "url of a git repository to use as the source of builds")
+	convertCompose.Flags().StringVarP(&revision, "git-revision", "r", "", "revision (sha, tag, or branch) of --git-repo to build (default master)")
+	convertCompose.Flags().BoolVar(&fromCwd, "from-cwd", false, "use the origin and current commit of the git checkout in the working directory as the source of builds")
+	convertCompose.Flags().BoolVar(&allowDirty, "allow-dirty", false, "allow --from-cwd even if the working tree has uncommitted changes")
+	convertCompose.Flags().StringVarP(&template, "template", "t", "kaniko", "build template to build images with, which must take IMAGE and DOCKERFILE arguments")
+	convertCompose.Flags().StringVarP(&serviceAccount, "service-account", "s", "", "service account builds should run using")
+
+	convertCmd.AddCommand(convertDeployment, convertCompose)
+	root.AddCommand(convertCmd)
+}
+
+func printWarnings(warnings []convert.Warning) {
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+}
@@ -2,43 +2,129 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/ghodss/yaml"
+	"github.com/julz/knightrider/pkg/apply"
+	"github.com/julz/knightrider/pkg/git"
 	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	"github.com/julz/knightrider/pkg/source"
+	"github.com/julz/knightrider/pkg/tekton"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var repo, revision, template, serviceAccount string
+var fromCwd, allowDirty, dryRun, skipPreflight bool
+var dockerfile, dockerContext, pushImage, dockerSecret string
+var buildVolumes, cachePaths []string
+var localDir, sourceImage string
+var insecureRegistry bool
+var cache, cacheSize string
+var steps, stepCommands, stepEnv, stepWorkDirs, stepCPU, stepMemory []string
+var buildTimeout time.Duration
 var result io.Reader
 
+// kubectlVerb is the kubectl command the generated objects are passed to, or
+// empty if they're just printed by generate
+var kubectlVerb string
+
 func kubecmd(cmd string) *cobra.Command {
+	// only commands which create or update objects need what they reference to exist
+	checkReferences := cmd == "apply" || cmd == "create" || cmd == "replace"
+
 	c := &cobra.Command{
 		Use:   cmd + " [knative object]",
 		Short: cmd,
+		PersistentPreRun: func(_ *cobra.Command, args []string) {
+			kubectlVerb = cmd
+			fetchTemplate = checkReferences && !skipPreflight
+		},
 		PersistentPostRun: func(_ *cobra.Command, args []string) {
-			kubectl := exec.Command("kubectl", cmd, "-f", "-")
-			kubectl.Stdin = result
-			kubectl.Stdout = os.Stdout
-			kubectl.Stderr = os.Stderr
-
-			if err := kubectl.Run(); err != nil {
-				fatalF("Error: %s", err)
-			}
+			runKubectl(cmd, checkReferences)
 		},
 	}
 
+	if checkReferences {
+		c.PersistentFlags().BoolVar(&skipPreflight, "skip-preflight", false, "don't check that the service accounts, secrets, config maps, volume claims, build templates, configurations and revisions the objects reference exist")
+		c.PersistentFlags().BoolVar(&installTemplate, "install-template", false, "if the -t build template isn't in the cluster, install it from the catalog without asking")
+	}
+
 	return c
 }
 
+// runKubectl passes the generated objects to kubectl cmd, after checking what
+// they reference exists if checkReferences is true, and waits for the build
+// if --wait was passed
+func runKubectl(cmd string, checkReferences bool) {
+	if result == nil {
+		return
+	}
+
+	if checkReferences && !skipPreflight {
+		result = preflight(result)
+	}
+
+	kubectlArgs := []string{cmd, "-f", "-"}
+	if namespace != "" {
+		kubectlArgs = append(kubectlArgs, "-n", namespace)
+	}
+
+	kubectl := exec.Command("kubectl", kubectlArgs...)
+	kubectl.Stdin = result
+	kubectl.Stdout = os.Stdout
+	kubectl.Stderr = os.Stderr
+
+	if err := kubectl.Run(); err != nil {
+		fatalF("Error: %s", err)
+	}
+
+	if waitBuild != "" {
+		waitForBuild(waitBuild)
+	}
+
+	if waitRevisionBuild != "" {
+		waitForRevisionBuild(waitRevisionBuild)
+	}
+}
+
+// preflight checks that everything the objects in r reference exists, and
+// returns a reader of the same objects
+func preflight(r io.Reader) io.Reader {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	objects, err := knative.Decode(bytes.NewReader(b))
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	if err := apply.Preflight(client(), objects); err != nil {
+		fatalF("Error: %s\n(use --skip-preflight to apply anyway)\n", err)
+	}
+
+	return bytes.NewReader(b)
+}
+
 var generate = &cobra.Command{
 	Use:   "generate [knative object]",
 	Short: "generate",
@@ -47,9 +133,11 @@ var generate = &cobra.Command{
 	},
 }
 
+var applyCmd = kubecmd("apply")
+
 var rootCmds = []*cobra.Command{
 	generate,
-	kubecmd("apply"),
+	applyCmd,
 	kubecmd("create"),
 	kubecmd("replace"),
 	kubecmd("patch"),
@@ -61,11 +149,85 @@ var generateBuild = &cobra.Command{
 	Short: "build",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		result = toYaml(knative.NewBuild(args[0], buildOptions()...))
+		checkWait(true)
+
+		b := knative.NewBuild(args[0], buildOptions()...)
+		annotateGitCommit(&b.ObjectMeta)
+		annotateBuildTimeout(&b.ObjectMeta)
+
+		if useTekton() {
+			if wait {
+				fatalF("Error: --wait is not supported with --build-backend=tekton\n")
+			}
+
+			objects, err := tekton.FromBuild(b)
+			if err != nil {
+				fatalF("Error: %s\n", err)
+			}
+
+			result = withCache(objects...)
+			return
+		}
+
+		if wait {
+			waitBuild = args[0]
+		}
+
+		result = withCache(b)
+	},
+}
+
+var templateParams []string
+
+var generateBuildTemplate = &cobra.Command{
+	Use:   "build-template [name] [image] [args]",
+	Short: "build template",
+	Long:  "build-template generates a build template with a single step, which runs image with args. Args can refer to parameters as ${NAME}.",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		options := []knative.BuildTemplateOption{
+			knative.WithTemplateStep("build", args[1], args[2:]...),
+		}
+
+		for _, p := range templateParams {
+			if parts := strings.SplitN(p, "=", 2); len(parts) == 2 {
+				options = append(options, knative.WithParameterDefault(parts[0], "", parts[1]))
+			} else {
+				options = append(options, knative.WithParameter(p, ""))
+			}
+		}
+
+		t := knative.NewBuildTemplate(args[0], options...)
+		if useTekton() {
+			result = toYaml(tekton.FromBuildTemplate(t))
+			return
+		}
+
+		result = toYaml(t)
 	},
 }
 
+var buildBackend string
+
+// useTekton returns true if --build-backend=tekton was passed
+func useTekton() bool {
+	switch buildBackend {
+	case "", "knative":
+		return false
+	case "tekton":
+		return true
+	}
+
+	fatalF("Error: unknown build backend %s, expected knative or tekton\n", buildBackend)
+	return false
+}
+
 var templateArgs, templateEnv []string
+var templateFile string
+
+// fetchTemplate is true if the build template should be fetched from the
+// cluster to check --template-arg against
+var fetchTemplate bool
 var single, alwaysPull bool
 
 var generateConfiguration = &cobra.Command{
@@ -73,7 +235,19 @@ var generateConfiguration = &cobra.Command{
 	Short: "configuration",
 	Args:  cobra.MinimumNArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		result = toYaml(knative.NewConfiguration(args[0], configurationOptions(args[1], args[2:])...))
+		checkWait(hasBuild())
+
+		c := knative.NewConfiguration(args[0], configurationOptions(args[1], args[2:])...)
+		if hasBuild() {
+			annotateGitCommit(&c.ObjectMeta)
+			annotateBuildTimeout(&c.ObjectMeta)
+		}
+
+		if wait {
+			waitRevisionBuild = args[0]
+		}
+
+		result = withCache(c)
 	},
 }
 
@@ -82,7 +256,19 @@ var generateService = &cobra.Command{
 	Short: "service",
 	Args:  cobra.MinimumNArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		result = toYaml(knative.NewRunLatestService(args[0], configurationOptions(args[1], args[2:])...))
+		checkWait(hasBuild())
+
+		s := knative.NewRunLatestService(args[0], configurationOptions(args[1], args[2:])...)
+		if hasBuild() {
+			annotateGitCommit(&s.ObjectMeta)
+			annotateBuildTimeout(&s.ObjectMeta)
+		}
+
+		if wait {
+			waitRevisionBuild = args[0]
+		}
+
+		result = withCache(s)
 	},
 }
 
@@ -134,15 +320,51 @@ var generateServiceAccount = &cobra.Command{
 
 func init() {
 	// everything can take a build, so everything gets the build flags
-	for _, cmd := range []*cobra.Command{generateBuild, generateService, generateConfiguration} {
+	for _, cmd := range []*cobra.Command{generateBuild, generateService, generateConfiguration, buildCmd} {
 		cmd.Flags().StringVarP(&repo, "git-repo", "u", "", "url of a git repository to use as a source")
-		cmd.Flags().StringVarP(&revision, "git-revision", "r", "master", "revision (sha, tag, or branch) to use for the build")
+		cmd.Flags().StringVarP(&revision, "git-revision", "r", "", "revision (sha, tag, or branch) of --git-repo to use for the build (default master)")
+		cmd.Flags().BoolVar(&fromCwd, "from-cwd", false, "use the origin and current commit of the git checkout in the working directory as the source (same as --git-repo=.)")
+		cmd.Flags().BoolVar(&allowDirty, "allow-dirty", false, "allow --from-cwd even if the working tree has uncommitted changes")
 
 		cmd.Flags().StringVarP(&template, "template", "t", "", "build template name")
 		cmd.Flags().StringSliceVarP(&templateArgs, "template-arg", "a", nil, "build template argument in the form name=value")
+		cmd.Flags().StringVar(&templateFile, "template-file", "", "yml file containing the build template, to check --template-arg against its parameters (when applying, the template is fetched from the cluster instead)")
 		cmd.Flags().StringSliceVarP(&templateEnv, "template-env", "e", nil, "build template environment variable in the form name=value")
 
 		cmd.Flags().StringVarP(&serviceAccount, "service-account", "s", "", "service account the build should run using")
+
+		cmd.Flags().StringVar(&dockerfile, "dockerfile", "", "build the image from this Dockerfile (relative to the root of the source) with kaniko, instead of with a build template")
+		cmd.Flags().StringVar(&dockerContext, "context", ".", "with --dockerfile, the directory to use as the docker build context")
+		cmd.Flags().StringVar(&pushImage, "image", "", "with --dockerfile, the image to push (for services and configurations, defaults to the image they run)")
+		cmd.Flags().StringVar(&dockerSecret, "docker-secret", "", "with --dockerfile, push using the credentials in this kubernetes.io/dockerconfigjson secret, rather than the service account's")
+
+		cmd.Flags().StringSliceVar(&buildVolumes, "build-volume", nil, "mount a volume in the build's steps, in the form path=emptyDir, path=secret:name, path=configMap:name or path=pvc:claim")
+		cmd.Flags().StringVar(&cache, "cache", "", "generate a persistent volume claim with this name, and mount it in the build's steps so caches survive between builds (with --dockerfile, kaniko looks for base images in it, and caches layers in the registry next to --image)")
+		cmd.Flags().StringSliceVar(&cachePaths, "cache-path", []string{"/cache"}, "where to mount the --cache volume (pass more than once to mount a directory of it at each path, e.g. /root/.m2 and /go/pkg/mod)")
+		cmd.Flags().StringVar(&cacheSize, "cache-size", "1Gi", "how much storage the --cache volume claim requests")
+
+		cmd.Flags().StringArrayVar(&steps, "step", nil, "add a step to the build instead of using a template, in the form 'name=image arg1 arg2 ...'")
+		cmd.Flags().StringArrayVar(&stepCommands, "step-command", nil, "replace the entrypoint of a step's image, in the form [step:]command (with no step, for every step)")
+		cmd.Flags().StringArrayVar(&stepEnv, "step-env", nil, "set an environment variable in a step, in the form [step:]name=value")
+		cmd.Flags().StringArrayVar(&stepWorkDirs, "step-workdir", nil, "set the directory a step runs in, in the form [step:]dir")
+		cmd.Flags().StringArrayVar(&stepCPU, "step-cpu", nil, "set the cpu a step requests, in the form [step:]quantity, e.g. compile:500m")
+		cmd.Flags().StringArrayVar(&stepMemory, "step-memory", nil, "set the memory a step requests, in the form [step:]quantity, e.g. compile:2Gi")
+	}
+
+	// builds and build templates can be generated as tekton objects instead
+	for _, cmd := range []*cobra.Command{generateBuild, generateBuildTemplate, buildCmd} {
+		cmd.Flags().StringVar(&buildBackend, "build-backend", "knative", "generate knative build objects, or tekton pipelines objects (a TaskRun and PipelineResource for a build, a Task for a build template)")
+	}
+
+	generateBuildTemplate.Flags().StringSliceVarP(&templateParams, "param", "p", nil, "add a parameter, in the form name, or name=default to make it optional")
+
+	// builds, and the builds of services and configurations, can be waited
+	// for, which is handy in CI. The build timeout is only enforced by waiting
+	for _, cmd := range []*cobra.Command{generateBuild, generateService, generateConfiguration, buildCmd} {
+		cmd.Flags().BoolVar(&wait, "wait", false, "after creating the build (for a service or configuration, the build of its new revision), wait for it to complete and exit non-zero if it fails (with create, apply or replace)")
+		cmd.Flags().DurationVar(&waitTimeout, "timeout", 0, "how long to --wait before giving up, or 0 to wait forever")
+		cmd.Flags().StringVar(&junitReport, "junit", "", "when using --wait, write a junit xml report with a test case per build step to this file")
+		cmd.Flags().DurationVar(&buildTimeout, "build-timeout", 0, "with --wait, how long the build may run for before kr cancels it, e.g. 20m (it's recorded in an annotation, but does nothing without --wait)")
 	}
 
 	// service and configuration have extra flags to configure the revision template
@@ -161,8 +383,13 @@ func init() {
 	// serviceAccount takes a list of secret names
 	generateServiceAccount.Flags().StringSliceVarP(&serviceAccountSecrets, "secret", "s", nil, "add a secret to the generated account")
 
+	// anything which prints yml can print it for newer serving api versions
+	for _, cmd := range append([]*cobra.Command{edit, convertCmd}, rootCmds...) {
+		cmd.PersistentFlags().StringVar(&apiVersion, "api-version", knative.V1Alpha1, "serving api version to generate services, configurations and routes for (v1alpha1, v1beta1 or v1)")
+	}
+
 	root.AddCommand(rootCmds...)
-	for _, cmd := range []*cobra.Command{generateSecret, generateServiceAccount, generateBuild, generateService, generateConfiguration, generateRoute} {
+	for _, cmd := range []*cobra.Command{generateSecret, generateServiceAccount, generateBuild, generateBuildTemplate, generateService, generateConfiguration, generateRoute} {
 		for _, parent := range rootCmds {
 			copy := &cobra.Command{}
 			*copy = *cmd
@@ -174,28 +401,464 @@ func init() {
 }
 
 func buildOptions() []knative.BuildSpecOption {
-	var options []knative.BuildSpecOption
-	if repo != "" {
-		options = append(options, knative.WithGitSource(repo, revision))
-	}
+	options := sourceOptions()
 
 	if template != "" {
-		options = append(options, knative.WithBuildTemplate(template, toMap(templateArgs), toMap(templateEnv)))
+		options = append(options, knative.WithBuildTemplate(template, templateArguments(), toMap(templateEnv)))
 	}
 
+	for _, step := range steps {
+		options = append(options, stepOption(step))
+	}
+
+	if dockerfile != "" {
+		options = append(options, kanikoOptions()...)
+	}
+
+	options = append(options, stepOptions()...)
+
 	if serviceAccount != "" {
 		options = append(options, knative.WithServiceAccount(serviceAccount))
 	}
 
+	// mounts go last, so they're added to all of the steps
+	return append(append(options, volumeOptions()...), checkVolumes)
+}
+
+// checkVolumes fails if two of the build's volumes have the same name, or a
+// step mounts two volumes at the same path, which kubernetes would reject
+func checkVolumes(b *build.BuildSpec) {
+	names := make(map[string]bool)
+	for _, v := range b.Volumes {
+		if names[v.Name] {
+			fatalF("Error: the build has two volumes called %s, pass a different --build-volume path\n", v.Name)
+		}
+
+		names[v.Name] = true
+	}
+
+	for _, step := range b.Steps {
+		mounted := make(map[string]string)
+		for _, m := range step.VolumeMounts {
+			if other, ok := mounted[m.MountPath]; ok {
+				fatalF("Error: step %s mounts both %s and %s at %s, pass a different --cache-path or --build-volume path\n", step.Name, other, m.Name, m.MountPath)
+			}
+
+			mounted[m.MountPath] = m.Name
+		}
+	}
+}
+
+// volumeOptions returns the options for --build-volume and --cache
+func volumeOptions() []knative.BuildSpecOption {
+	var options []knative.BuildSpecOption
+	for _, v := range buildVolumes {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") {
+			fatalF("Error: expected --build-volume in the form path=type[:name], with an absolute path, but got %s\n", v)
+		}
+
+		name := volumeName(parts[0])
+		options = append(options, knative.WithBuildVolume(name, volumeSource(parts[1])), knative.WithVolumeMount(name, parts[0], ""))
+	}
+
+	if cache != "" {
+		// the volume is always called cache, so build templates can mount it
+		options = append(options, knative.WithBuildVolume("cache", knative.ClaimVolume(cache)))
+		for _, path := range cachePaths {
+			// with several paths, each gets its own directory of the claim
+			subPath := ""
+			if len(cachePaths) > 1 {
+				subPath = volumeName(path)
+			}
+
+			options = append(options, knative.WithVolumeMount("cache", path, subPath))
+		}
+	}
+
+	if len(options) > 0 && template != "" {
+		fmt.Fprintf(os.Stderr, "note: the steps of build template %s aren't in the build, so its steps need to mount the build's volumes themselves (the --cache volume is called cache)\n", template)
+	}
+
+	return options
+}
+
+// volumeSource parses a volume type in the form emptyDir, secret:name,
+// configMap:name or pvc:claim
+func volumeSource(s string) corev1.VolumeSource {
+	parts := strings.SplitN(s, ":", 2)
+	if parts[0] == "emptyDir" && len(parts) == 1 {
+		return knative.EmptyDirVolume()
+	}
+
+	if len(parts) != 2 || parts[1] == "" {
+		fatalF("Error: expected a volume of emptyDir, secret:name, configMap:name or pvc:claim, but got %s\n", s)
+	}
+
+	switch parts[0] {
+	case "secret":
+		return knative.SecretVolume(parts[1])
+	case "configMap":
+		return knative.ConfigMapVolume(parts[1])
+	case "pvc":
+		return knative.ClaimVolume(parts[1])
+	}
+
+	fatalF("Error: unknown volume type %s, expected emptyDir, secret, configMap or pvc\n", parts[0])
+	return corev1.VolumeSource{}
+}
+
+var nonAlphanumeric = regexp.MustCompile("[^a-z0-9]+")
+
+// volumeName returns a name for the volume mounted at path, e.g. root-m2 for
+// /root/.m2
+func volumeName(path string) string {
+	if name := strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(path), "-"), "-"); name != "" {
+		return name
+	}
+
+	return "root"
+}
+
+// withCache returns the yml of objects, preceded by the --cache volume claim
+// if one was asked for. The claim is only generated to be created: it's
+// left out for delete, so the cache outlives the objects, for replace and
+// patch, as a bound claim can't be changed, and for create once it exists
+func withCache(objects ...interface{}) io.Reader {
+	createsCache := kubectlVerb == "" || kubectlVerb == "apply" || kubectlVerb == "create"
+	if cache != "" && !createsCache {
+		fmt.Fprintf(os.Stderr, "note: leaving volume claim %s alone, it's only generated by generate, apply and create\n", cache)
+	}
+
+	if cache == "" || !createsCache || cacheExists() {
+		if len(objects) == 1 {
+			return toYaml(objects[0])
+		}
+
+		return toYamlDocs(objects...)
+	}
+
+	size, err := resource.ParseQuantity(cacheSize)
+	if err != nil {
+		fatalF("Error: --cache-size %s is not a quantity of storage, e.g. 10Gi\n", cacheSize)
+	}
+
+	claim := knative.NewPersistentVolumeClaim(cache, size)
+	return toYamlDocs(append([]interface{}{&claim}, objects...)...)
+}
+
+// cacheExists returns true if kubectl would be creating the --cache volume
+// claim but it was left by an earlier build. kubectl create fails on anything
+// which already exists, so the claim is reused rather than created again
+func cacheExists() bool {
+	if kubectlVerb != "create" {
+		return false
+	}
+
+	err := client().Get(kube.PersistentVolumeClaims, cache, &corev1.PersistentVolumeClaim{})
+	if err != nil && !kube.IsNotFound(err) {
+		fatalF("Error: %s\n", err)
+	}
+
+	return err == nil
+}
+
+// hasBuild returns true if a build was asked for, with --template, --step or
+// --dockerfile
+func hasBuild() bool {
+	return template != "" || len(steps) > 0 || dockerfile != ""
+}
+
+// stepOption parses a --step in the form 'name=image arg1 arg2 ...'
+func stepOption(step string) knative.BuildSpecOption {
+	if template != "" {
+		fatalF("Error: pass either --template or --step, not both\n")
+	}
+
+	parts := strings.SplitN(step, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || len(strings.Fields(parts[1])) == 0 {
+		fatalF("Error: expected --step in the form 'name=image arg1 arg2 ...' but got %s\n", step)
+	}
+
+	fields := strings.Fields(parts[1])
+	return knative.WithStep(parts[0], fields[0], fields[1:]...)
+}
+
+// stepName matches the names steps can have
+var stepName = regexp.MustCompile("^[a-z0-9]([-a-z0-9]*[a-z0-9])?$")
+
+// stepOptions returns the options for the --step-* flags
+func stepOptions() []knative.BuildSpecOption {
+	names := make(map[string]bool)
+	for _, step := range steps {
+		names[strings.SplitN(step, "=", 2)[0]] = true
+	}
+
+	if dockerfile != "" {
+		names["build-and-push"] = true
+	}
+
+	// values are prefixed with the step they're for, if any. Anything before a
+	// colon which could be a step name has to be one, so typos aren't taken to
+	// be part of the value
+	forStep := func(flag, value string) (string, string) {
+		parts := strings.SplitN(value, ":", 2)
+		if len(names) == 0 {
+			fatalF("Error: %s needs steps to set it on, pass --step or --dockerfile (build template steps can only be given env, with --template-env)\n", flag)
+		}
+
+		if len(parts) == 2 && names[parts[0]] {
+			return parts[0], parts[1]
+		}
+
+		if len(parts) == 2 && stepName.MatchString(parts[0]) {
+			var known []string
+			for name := range names {
+				known = append(known, name)
+			}
+
+			sort.Strings(known)
+			fatalF("Error: %s %s: there's no step called %s, the steps are %s\n", flag, value, parts[0], strings.Join(known, ", "))
+		}
+
+		return "", value
+	}
+
+	var options []knative.BuildSpecOption
+	for _, c := range stepCommands {
+		step, command := forStep("--step-command", c)
+		options = append(options, knative.WithStepOptions(step, knative.WithStepCommand(strings.Fields(command)...)))
+	}
+
+	for _, e := range stepEnv {
+		step, env := forStep("--step-env", e)
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 {
+			fatalF("Error: expected --step-env in the form [step:]name=value but got %s\n", e)
+		}
+
+		options = append(options, knative.WithStepOptions(step, knative.WithStepEnv(parts[0], parts[1])))
+	}
+
+	for _, w := range stepWorkDirs {
+		step, dir := forStep("--step-workdir", w)
+		options = append(options, knative.WithStepOptions(step, knative.WithStepWorkingDir(dir)))
+	}
+
+	for resourceName, values := range map[corev1.ResourceName][]string{corev1.ResourceCPU: stepCPU, corev1.ResourceMemory: stepMemory} {
+		for _, v := range values {
+			step, q := forStep("--step-"+string(resourceName), v)
+			quantity, err := resource.ParseQuantity(q)
+			if err != nil {
+				fatalF("Error: --step-%s %s is not a quantity, e.g. 500m or 2Gi\n", resourceName, v)
+			}
+
+			options = append(options, knative.WithStepOptions(step, knative.WithStepRequests(corev1.ResourceList{resourceName: quantity})))
+		}
+	}
+
+	return options
+}
+
+// annotateBuildTimeout records --build-timeout on a build, or on the service
+// or configuration the build belongs to
+func annotateBuildTimeout(meta *metav1.ObjectMeta) {
+	if buildTimeout < 0 {
+		fatalF("Error: --build-timeout can't be negative\n")
+	}
+
+	if buildTimeout > 0 {
+		knative.AnnotateBuildTimeout(meta, buildTimeout)
+	}
+}
+
+// kanikoOptions returns the options for building --dockerfile with an inline
+// kaniko step, so no build template needs to be installed
+func kanikoOptions() []knative.BuildSpecOption {
+	if template != "" {
+		fatalF("Error: pass either --template or --dockerfile, not both\n")
+	}
+
+	if pushImage == "" {
+		fatalF("Error: --dockerfile needs --image, the image to push\n")
+	}
+
+	options := []knative.BuildSpecOption{knative.WithKanikoStep(dockerfile, dockerContext, pushImage)}
+	if dockerSecret != "" {
+		options = append(options, knative.WithDockerConfigSecret(dockerSecret))
+	}
+
+	// the cache volume itself is added by volumeOptions
+	if cache != "" {
+		options = append(options, knative.WithKanikoCache("cache"))
+	}
+
 	return options
 }
 
+// templateArguments returns the --template-arg values, checked against the
+// parameters of the build template if it's known, either from --template-file
+// or, when applying, from the cluster
+func templateArguments() map[string]string {
+	args := toMap(templateArgs)
+
+	t := knownTemplate()
+	if t == nil {
+		return args
+	}
+
+	checked, err := knative.CheckTemplateArguments(t, args)
+	if err != nil {
+		fatalF("Error: %s", err)
+	}
+
+	return checked
+}
+
+func knownTemplate() *build.BuildTemplate {
+	if templateFile != "" {
+		for _, o := range readManifest(templateFile) {
+			if t, ok := o.(*build.BuildTemplate); ok && t.Name == template {
+				return t
+			}
+		}
+
+		fatalF("Error: %s does not contain a build template called %s\n", templateFile, template)
+	}
+
+	if !fetchTemplate {
+		return nil
+	}
+
+	var t build.BuildTemplate
+	if err := client().Get(kube.BuildTemplates, template, &t); err != nil {
+		if kube.IsNotFound(err) {
+			// if it isn't installed from the catalog, preflight reports the missing template
+			return installFromCatalog()
+		}
+
+		fatalF("Error: %s\n", err)
+	}
+
+	return &t
+}
+
+// sourceOptions returns the options for the source of a build: the local
+// directory passed with --local-dir, or a git source
+func sourceOptions() []knative.BuildSpecOption {
+	if localDir == "" {
+		return gitSourceOptions()
+	}
+
+	if repo != "" || revision != "" || fromCwd {
+		fatalF("Error: pass either --local-dir or a git source, not both\n")
+	}
+
+	if sourceImage == "" {
+		fatalF("Error: --local-dir needs --source-image, the repository to push the directory to\n")
+	}
+
+	ref, err := source.ParseReference(sourceImage)
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	var layer bytes.Buffer
+	if err := source.Package(localDir, &layer); err != nil {
+		fatalF("Error: could not package %s: %s\n", localDir, err)
+	}
+
+	user, pass, err := source.DockerCredentials(ref.Host)
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "pushing %s to %s\n", localDir, ref)
+	registry := &source.Registry{Insecure: insecureRegistry, Username: user, Password: pass}
+	image, err := registry.PushLayer(ref, layer.Bytes())
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	return []knative.BuildSpecOption{knative.WithSourceImage(image, insecureRegistry)}
+}
+
+// gitSourceOptions returns the options for the git source passed with
+// --from-cwd or --git-repo, if any
+func gitSourceOptions() []knative.BuildSpecOption {
+	if src := cwdGitSource(); src != nil {
+		return []knative.BuildSpecOption{knative.WithGitSource(src.URL, src.Commit)}
+	}
+
+	if repo == "" && revision != "" {
+		fatalF("Error: --git-revision is a revision of --git-repo, pass that too\n")
+	}
+
+	if repo != "" {
+		if revision == "" {
+			return []knative.BuildSpecOption{knative.WithGitSource(repo, "master")}
+		}
+
+		return []knative.BuildSpecOption{knative.WithGitSource(repo, revision)}
+	}
+
+	return nil
+}
+
+var cwdSource *git.Source
+
+// cwdGitSource returns the origin and commit of the git checkout in the working
+// directory if --from-cwd or --git-repo=. was passed, or nil otherwise
+func cwdGitSource() *git.Source {
+	if !fromCwd && repo != "." {
+		return nil
+	}
+
+	// the current commit is always built, so a revision would be ignored
+	if revision != "" {
+		fatalF("Error: pass either --from-cwd or --git-revision, not both\n")
+	}
+
+	if cwdSource == nil {
+		src, err := git.Inspect(".")
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		if src.Dirty && !allowDirty {
+			fatalF("Error: working tree has uncommitted changes, commit them or pass --allow-dirty\n")
+		}
+
+		cwdSource = &src
+	}
+
+	return cwdSource
+}
+
+func annotateGitCommit(meta *metav1.ObjectMeta) {
+	if src := cwdGitSource(); src != nil {
+		knative.Annotate(meta, knative.GitCommitAnnotation, src.Commit)
+	}
+}
+
 func configurationOptions(image string, args []string) []knative.ConfigurationOption {
 	options := []knative.ConfigurationOption{
 		knative.WithRevisionTemplate(image, args, nil),
 	}
 
-	if template != "" {
+	if !hasBuild() && (cache != "" || len(buildVolumes) > 0) {
+		fatalF("Error: --cache and --build-volume need a build, pass --template or --dockerfile\n")
+	}
+
+	if !hasBuild() && (fromCwd || repo != "" || revision != "") {
+		fatalF("Error: --from-cwd, --git-repo and --git-revision are the source of a build, pass --template, --step or --dockerfile too\n")
+	}
+
+	if hasBuild() {
+		if pushImage == "" {
+			pushImage = image
+		}
+
 		options = append(options, knative.WithBuild(buildOptions()...))
 	}
 
@@ -242,9 +905,26 @@ func parseTrafficSpec(s string) (name, target string, percent int) {
 	return name, parts[0], percent
 }
 
+// toYamlDocs returns the objects as a multi-document yml stream
+func toYamlDocs(objects ...interface{}) io.Reader {
+	var readers []io.Reader
+	for _, o := range objects {
+		readers = append(readers, strings.NewReader("---\n"), toYaml(o))
+	}
+
+	return io.MultiReader(readers...)
+}
+
+// apiVersion is the serving api version to print knative objects for
+var apiVersion = knative.V1Alpha1
+
 func toYaml(o interface{}) io.Reader {
+	o, err := knative.ForVersion(o, apiVersion)
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
 	var b []byte
-	var err error
 	if b, err = yaml.Marshal(o); err != nil {
 		fatalF("Error: %s", err)
 	}
@@ -252,6 +932,19 @@ func toYaml(o interface{}) io.Reader {
 	return strings.NewReader(string(b))
 }
 
+// applyOrPrint applies the given objects to the cluster, or prints them as yml
+// if --dry-run was passed
+func applyOrPrint(objects ...interface{}) {
+	if dryRun {
+		printYaml(objects...)
+		return
+	}
+
+	if err := client().Apply(objects...); err != nil {
+		fatalF("Error: %s\n", err)
+	}
+}
+
 func toMap(args []string) map[string]string {
 	options := make(map[string]string)
 	for _, arg := range args {
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/julz/knightrider/pkg/debug"
+	"github.com/spf13/cobra"
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug [knative object]",
+	Short: "explain why a knative object isn't ready",
+}
+
+var debugService = &cobra.Command{
+	Use:   "service [name]",
+	Short: "explain why a service isn't ready",
+	Long:  "debug service walks a service's configuration, latest revision, build and pods, and lists everything that isn't healthy, most likely cause first, with suggested fixes",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		findings, err := debug.Service(client(), args[0])
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		if len(findings) == 0 {
+			fmt.Printf("service %s looks healthy\n", args[0])
+			return
+		}
+
+		for i, f := range findings {
+			fmt.Printf("%d. %s: %s\n", i+1, f.Object, f.Problem)
+			if f.Fix != "" {
+				fmt.Printf("   fix: %s\n", f.Fix)
+			}
+		}
+	},
+}
+
+func init() {
+	debugCmd.AddCommand(debugService)
+	root.AddCommand(debugCmd)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/spf13/cobra"
+)
+
+var editFile, editImage string
+var editEnv []string
+
+var edit = &cobra.Command{
+	Use:   "edit -f FILE",
+	Short: "modify existing knative yml",
+	Long: `edit reads services, configurations, routes and builds from a yml or json file (use '-f -' for stdin), modifies them, and writes the result to stdout.
+
+Flags only change the objects they make sense for, e.g. --image changes the revision template of services and configurations, --service-account changes builds and the builds of services and configurations. Everything else is written out as it was read.`,
+	Example: `  kr edit -f service.yaml --image docker.io/me/app:v2 --env LOG_LEVEL=debug
+  kr edit -f route.yaml -r my-service-00002:0:next | kubectl apply -f -`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if editFile == "" {
+			fatalF("Error: a file is required, use '-f -' for stdin\n")
+		}
+
+		objects := readManifest(editFile)
+
+		edits := knative.Edits{Route: routeOptions()}
+		if editImage != "" {
+			edits.Configuration = append(edits.Configuration, knative.WithImage(editImage))
+		}
+
+		// in the order given, so that editing the same file again doesn't
+		// reorder the env and roll out a new revision
+		for _, env := range editEnv {
+			parts := strings.SplitN(env, "=", 2)
+			if len(parts) != 2 {
+				fatalF("Error: expected --env in the form name=value but got %s\n", env)
+			}
+
+			edits.Configuration = append(edits.Configuration, knative.WithEnv(parts[0], parts[1]))
+		}
+
+		if cmd.Flags().Changed("single") {
+			if single {
+				edits.Configuration = append(edits.Configuration, knative.WithSingleConcurrency)
+			} else {
+				edits.Configuration = append(edits.Configuration, knative.WithMultiConcurrency)
+			}
+		}
+
+		if serviceAccount != "" {
+			edits.Build = append(edits.Build, knative.WithServiceAccount(serviceAccount))
+		}
+
+		edited := false
+		for _, o := range objects {
+			edited = edits.Apply(o) || edited
+		}
+
+		if !edited {
+			fmt.Fprintf(os.Stderr, "warning: none of the objects in %s were changed\n", editFile)
+		}
+
+		printYaml(objects...)
+	},
+}
+
+func init() {
+	edit.Flags().StringVarP(&editFile, "filename", "f", "", "yml or json file to edit, or - for stdin")
+	edit.Flags().StringVar(&editImage, "image", "", "set the image of services and configurations")
+	edit.Flags().StringSliceVar(&editEnv, "env", nil, "set an environment variable of services and configurations, in the form name=value")
+	edit.Flags().BoolVar(&single, "single", false, "set services and configurations to be single threaded (or multi threaded with --single=false)")
+	edit.Flags().StringVarP(&serviceAccount, "service-account", "s", "", "set the service account builds run using")
+	edit.Flags().StringSliceVarP(&revisionTraffic, "revision", "r", nil, "add traffic to a revision to routes (in format revisionName:percent or revisionName:percent:name")
+	edit.Flags().StringSliceVarP(&configurationTraffic, "configuration", "c", nil, "add traffic to a configuration to routes (in format configurationName:percent or configurationName:percent:name")
+
+	root.AddCommand(edit)
+}
+
+// readManifest decodes the objects in a yml or json file, or stdin if path is -
+func readManifest(path string) []interface{} {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		defer f.Close()
+		r = f
+	}
+
+	objects, err := knative.Decode(r)
+	if err != nil {
+		fatalF("Error: could not read %s: %s\n", path, err)
+	}
+
+	return objects
+}
+
+// printYaml prints objects to stdout as a multi-document yml stream
+func printYaml(objects ...interface{}) {
+	io.Copy(os.Stdout, toYamlDocs(objects...))
+}
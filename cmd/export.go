@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/julz/knightrider/pkg/export"
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var exportSecrets, exportOutputDir string
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export a namespace's knative objects as yml which can be applied again",
+	Long: `export fetches the services, configurations, routes, builds and build templates in a namespace, along with the service accounts and secrets they use, and writes them as yml which can be applied to recreate them, e.g. in another cluster.
+
+Status, server-set metadata (uid, resourceVersion, the namespace, ...), generations and defaulted fields are removed, and objects created by other objects (like the route of a service) are left out. Objects are written in the order they need to be applied in, to stdout or to one file per object in --output-dir.
+
+Secrets are written with each value replaced by ` + export.Placeholder + ` unless you pass --secrets include (or --secrets omit to leave them out).`,
+	Example: `  kr export -n prod > prod.yaml
+  kr export -n prod --output-dir backup/ --secrets include`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		mode := export.SecretMode(exportSecrets)
+		switch mode {
+		case export.IncludeSecrets, export.PlaceholderSecrets, export.OmitSecrets:
+		default:
+			fatalF("Error: unknown --secrets %s, expected include, placeholder or omit\n", exportSecrets)
+		}
+
+		result, err := export.Namespace(client(), mode)
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		for _, w := range result.Warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+
+		if exportOutputDir == "" {
+			printYaml(result.Objects...)
+			return
+		}
+
+		if err := os.MkdirAll(exportOutputDir, 0755); err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		for _, o := range result.Objects {
+			path := filepath.Join(exportOutputDir, exportFileName(o))
+			if err := writeYaml(path, o); err != nil {
+				fatalF("Error: %s\n", err)
+			}
+
+			fmt.Println(path)
+		}
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportSecrets, "secrets", string(export.PlaceholderSecrets), "what to do with secrets: include, placeholder (replace their values) or omit")
+	exportCmd.Flags().StringVarP(&exportOutputDir, "output-dir", "o", "", "write each object to its own file in this directory rather than to stdout")
+
+	root.AddCommand(exportCmd)
+}
+
+// exportOrder is the order kinds are applied in, used to prefix file names so
+// that applying a directory of exported files creates them in the right order
+var exportOrder = []string{"Secret", "ServiceAccount", "BuildTemplate", "Build", "Configuration", "Route", "Service"}
+
+// exportFileName returns a file name like 6-service-foo.yaml for an object
+func exportFileName(o interface{}) string {
+	kind := knative.Kind(o)
+	rank := len(exportOrder)
+	for i, k := range exportOrder {
+		if k == kind {
+			rank = i
+		}
+	}
+
+	return fmt.Sprintf("%d-%s-%s.yaml", rank, strings.ToLower(kind), o.(metav1.Object).GetName())
+}
+
+func writeYaml(path string, o interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	_, err = io.Copy(f, toYaml(o))
+	return err
+}
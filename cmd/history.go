@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/julz/knightrider/pkg/traffic"
+	"github.com/spf13/cobra"
+)
+
+var history = &cobra.Command{
+	Use:   "history [knative object]",
+	Short: "show the revision history of a knative object",
+}
+
+var historyService = &cobra.Command{
+	Use:   "service [name]",
+	Short: "show the revisions of a service",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		revisions, err := traffic.ServiceHistory(client(), args[0])
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "REVISION\tCREATED\tIMAGE\tGENERATION\tREADY\tTRAFFIC")
+		for _, r := range revisions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d%%\n", r.Name, r.Created.Format(time.RFC3339), r.Image, r.Generation, r.Ready, r.Percent)
+		}
+
+		w.Flush()
+	},
+}
+
+var rollbackTo string
+
+var rollback = &cobra.Command{
+	Use:   "rollback [knative object]",
+	Short: "roll back a knative object to a previous revision",
+	PersistentPostRun: func(_ *cobra.Command, args []string) {
+		applyOrPrint(rollbackResult)
+	},
+}
+
+var rollbackResult interface{}
+
+var rollbackService = &cobra.Command{
+	Use:   "service [name]",
+	Short: "pin a service to its previous revision, or to the revision given by --to",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := traffic.RollbackService(client(), args[0], rollbackTo)
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		rollbackResult = s
+	},
+}
+
+var rollbackRoute = &cobra.Command{
+	Use:   "route [name]",
+	Short: "send all of a route's traffic to its previous revision, or to the revision given by --to",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := traffic.RollbackRoute(client(), args[0], rollbackTo)
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		rollbackResult = r
+	},
+}
+
+func init() {
+	rollback.PersistentFlags().StringVar(&rollbackTo, "to", "", "revision to roll back to (defaults to the revision before the one currently serving)")
+	rollback.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the resulting yml instead of applying it")
+
+	history.AddCommand(historyService)
+	rollback.AddCommand(rollbackService, rollbackRoute)
+	root.AddCommand(history, rollback)
+}
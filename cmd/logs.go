@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/julz/knightrider/pkg/logs"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var follow bool
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [knative object]",
+	Short: "show the logs of a knative object",
+}
+
+var logsBuild = &cobra.Command{
+	Use:   "build [name]",
+	Short: "stream the logs of each step of a build until it completes",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := &logs.Streamer{Client: client(), Out: os.Stdout}
+		cond, err := s.Build(args[0])
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		if cond.Status != corev1.ConditionTrue {
+			fatalF("build %s failed: %s %s\n", args[0], cond.Reason, cond.Message)
+		}
+
+		fmt.Fprintf(os.Stderr, "build %s succeeded\n", args[0])
+	},
+}
+
+var logsRevision = &cobra.Command{
+	Use:   "revision [name]",
+	Short: "stream the logs of every pod of a revision",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		streamRevisionLogs(args[0])
+	},
+}
+
+var logsService = &cobra.Command{
+	Use:   "service [name]",
+	Short: "stream the logs of every pod of a service's latest revision",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		revision, err := logs.ServiceRevision(client(), args[0])
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		streamRevisionLogs(revision)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{logsRevision, logsService} {
+		cmd.Flags().BoolVarP(&follow, "follow", "f", true, "keep streaming logs, including from new pods, until interrupted")
+	}
+
+	logsCmd.AddCommand(logsBuild, logsRevision, logsService)
+	root.AddCommand(logsCmd)
+}
+
+func streamRevisionLogs(revision string) {
+	stop := make(chan struct{})
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		close(stop)
+	}()
+
+	s := &logs.Streamer{Client: client(), Out: os.Stdout}
+	if err := s.Revision(revision, follow, stop); err != nil {
+		fatalF("Error: %s\n", err)
+	}
+}
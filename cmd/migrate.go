@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/migrate"
+	"github.com/spf13/cobra"
+)
+
+var migrateFile, migrateOutputDir, migrateVersion string
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate -f FILE|DIR",
+	Short: "migrate v1alpha1 knative yml to a newer serving api version",
+	Long: `migrate rewrites the v1alpha1 serving Services, Configurations and Routes in a yml or json file, or in every .yaml, .yml and .json file under a directory, to the v1 (or v1beta1) serving api: runLatest and pinned services get a template and a traffic block, a Single concurrencyModel becomes a containerConcurrency of 1, and named traffic targets become tagged ones.
+
+Files are changed in place, or written to --output-dir. Everything else in a file is left exactly as it was, and comments at the top of each migrated object are kept. A summary of what changed in each file is printed.
+
+Services and configurations which build their image can't be migrated, since newer serving api versions can't build, so they are left as they were and reported.`,
+	Example: `  kr migrate -f config/
+  kr migrate -f service.yaml --output-dir migrated/ --api-version v1beta1`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if migrateFile == "" {
+			fatalF("Error: a file or directory is required\n")
+		}
+
+		files, err := manifestFiles(migrateFile)
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		failed := false
+		for _, file := range files {
+			if err := migrateManifest(file); err != nil {
+				fmt.Printf("%s: error: %s\n", file, err)
+				failed = true
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	migrateCmd.Flags().StringVarP(&migrateFile, "filename", "f", "", "yml or json file, or directory of them, to migrate")
+	migrateCmd.Flags().StringVarP(&migrateOutputDir, "output-dir", "o", "", "write migrated files to this directory rather than changing them in place")
+	migrateCmd.Flags().StringVar(&migrateVersion, "api-version", knative.V1, "serving api version to migrate to (v1beta1 or v1)")
+
+	root.AddCommand(migrateCmd)
+}
+
+// migrateManifest migrates a single file and prints what changed
+func migrateManifest(file string) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	result, err := migrate.Manifest(content, migrateVersion)
+	if err != nil {
+		return err
+	}
+
+	out := file
+	if migrateOutputDir != "" {
+		rel, err := filepath.Rel(migrateFile, file)
+		if err != nil || rel == "." {
+			rel = filepath.Base(file)
+		}
+
+		out = filepath.Join(migrateOutputDir, rel)
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+	}
+
+	if result.Changed() || out != file {
+		if err := ioutil.WriteFile(out, result.Output, 0644); err != nil {
+			return err
+		}
+	}
+
+	if result.Changed() {
+		fmt.Printf("%s: migrated\n", out)
+	} else {
+		fmt.Printf("%s: unchanged\n", out)
+	}
+
+	for _, change := range result.Changes {
+		fmt.Printf("  %s\n", change)
+	}
+
+	for _, skipped := range result.Skipped {
+		fmt.Printf("  skipped %s\n", skipped)
+	}
+
+	return nil
+}
+
+// manifestFiles returns path if it's a file, or the yml and json files under
+// it if it's a directory
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && file != path && (info.Name()[0] == '.' || migrateOutputDir != "" && filepath.Clean(file) == filepath.Clean(migrateOutputDir)) {
+			return filepath.SkipDir
+		}
+
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml", ".json":
+			if !info.IsDir() {
+				files = append(files, file)
+			}
+		}
+
+		return nil
+	})
+
+	return files, err
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/julz/knightrider/pkg/traffic"
+	"github.com/spf13/cobra"
+)
+
+var rolloutTo, rolloutProbe string
+var rolloutSteps []int
+var rolloutInterval, rolloutReadyTimeout time.Duration
+var rolloutProbeRequests int
+var rolloutSuccessRate float64
+
+var rollout = &cobra.Command{
+	Use:   "rollout [route name]",
+	Short: "progressively shift a route's traffic to a revision",
+	Long: `rollout shifts a route's traffic to a revision in steps, checking that the route and revision are ready (and, optionally, that an http probe succeeds) between each step.
+
+If a check fails, the route's traffic is put back to how it was before the rollout started. The state of the rollout is saved on the route, so an interrupted rollout can be resumed by running the command again (--to may be omitted when resuming).`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r := &traffic.Rollouter{
+			Client:       client(),
+			Interval:     rolloutInterval,
+			ReadyTimeout: rolloutReadyTimeout,
+			Log:          os.Stderr,
+		}
+
+		if rolloutProbe != "" {
+			if rolloutProbeRequests < 1 {
+				fatalF("Error: --probe-requests must be at least 1\n")
+			}
+
+			r.Probe = traffic.HTTPProbe(rolloutProbe, rolloutProbeRequests, rolloutSuccessRate)
+		}
+
+		if err := r.Start(args[0], rolloutTo, rolloutSteps); err != nil {
+			fatalF("Error: %s\n", err)
+		}
+	},
+}
+
+func init() {
+	rollout.Flags().StringVar(&rolloutTo, "to", "", "revision to roll out")
+	rollout.Flags().IntSliceVar(&rolloutSteps, "steps", []int{10, 25, 50, 100}, "percentages of traffic to send to the revision at each step")
+	rollout.Flags().DurationVar(&rolloutInterval, "interval", 2*time.Minute, "time to wait between steps")
+	rollout.Flags().DurationVar(&rolloutReadyTimeout, "ready-timeout", 5*time.Minute, "time to wait for the route and revision to become ready after each step")
+	rollout.Flags().StringVar(&rolloutProbe, "probe", "", "url to probe with http GET requests before each step")
+	rollout.Flags().IntVar(&rolloutProbeRequests, "probe-requests", 10, "number of probe requests to make before each step")
+	rollout.Flags().Float64Var(&rolloutSuccessRate, "success-rate", 0.95, "fraction of probe requests which must succeed (not return a 5xx) to continue")
+
+	root.AddCommand(rollout)
+}
@@ -4,18 +4,30 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/julz/knightrider/pkg/kube"
 	"github.com/spf13/cobra"
 )
 
+var namespace string
+
 var root = &cobra.Command{
 	Use:   "kr",
 	Long:  "kr  is a super simple program for working with knative yml",
 	Short: `kr is a super simple program for working with knative yml`,
 }
 
+func init() {
+	root.PersistentFlags().StringVarP(&namespace, "namespace", "n", "", "namespace to use when talking to the cluster (defaults to kubectl's current namespace)")
+}
+
 func Execute() {
 	if err := root.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+// client returns a Client for the cluster kubectl is currently targeting
+func client() kube.Client {
+	return kube.Kubectl{Namespace: namespace}
+}
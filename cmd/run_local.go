@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/local"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	"github.com/spf13/cobra"
+)
+
+var runLocalFile string
+var runLocalPort int
+
+var runLocal = &cobra.Command{
+	Use:   "run-local [name] [image] [args]",
+	Short: "run a knative service or configuration on the local machine",
+	Long: `run-local runs a service locally, either from a yml file (use '-f -' to pipe from 'kr generate') or from the same arguments as 'kr generate service'.
+
+Images which are go import paths are run with 'go run', images which are paths to local binaries are run directly.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var names local.Names
+		var spec *serving.ConfigurationSpec
+		if runLocalFile != "" {
+			names, spec = readLocalConfiguration(runLocalFile)
+			if single {
+				knative.WithSingleConcurrency(spec)
+			}
+		} else {
+			if len(args) < 2 {
+				fatalF("Error: either a file or a name and image are required\n")
+			}
+
+			names = local.NamesFor(args[0], args[0])
+			spec = &knative.NewConfiguration(args[0], configurationOptions(args[1], args[2:])...).Spec
+		}
+
+		process, err := local.Command(spec.RevisionTemplate.Spec.Container)
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		port := freePort()
+		process.Env = append(os.Environ(), local.Env(spec.RevisionTemplate.Spec.Container, names, port)...)
+		process.Stdout = os.Stdout
+		process.Stderr = os.Stderr
+
+		target, _ := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", port))
+		proxy := local.NewProxy(target, local.ConcurrencyModel(spec.RevisionTemplate.Spec))
+		go func() {
+			fatalF("Error: %s\n", http.ListenAndServe(fmt.Sprintf(":%d", runLocalPort), proxy))
+		}()
+
+		// interrupts are delivered to the whole process group, so just wait for the child to exit
+		signal.Ignore(os.Interrupt)
+
+		fmt.Fprintf(os.Stderr, "running %s as revision %s on http://localhost:%d\n", spec.RevisionTemplate.Spec.Container.Image, names.Revision, runLocalPort)
+		if err := process.Run(); err != nil {
+			if exit, ok := err.(*exec.ExitError); ok {
+				fatalF("%s\n", exit)
+			}
+
+			fatalF("Error: %s\n", err)
+		}
+	},
+}
+
+func init() {
+	runLocal.Flags().StringVarP(&runLocalFile, "filename", "f", "", "yml file containing a service or configuration to run, or - for stdin")
+	runLocal.Flags().IntVarP(&runLocalPort, "port", "p", 8080, "port to listen for requests on")
+	runLocal.Flags().BoolVar(&single, "single", false, "only allow a single request in to the container at a time")
+
+	root.AddCommand(runLocal)
+}
+
+func readLocalConfiguration(path string) (local.Names, *serving.ConfigurationSpec) {
+	objects := readManifest(path)
+	if len(objects) != 1 {
+		fatalF("Error: expected %s to contain a single Service or Configuration, found %d objects\n", path, len(objects))
+	}
+
+	switch o := objects[0].(type) {
+	case *serving.Service:
+		if c := knative.ServiceConfiguration(o); c != nil {
+			return local.NamesFor(o.Name, o.Name), c
+		}
+
+		fatalF("Error: service %s has neither runLatest nor pinned configuration\n", o.Name)
+	case *serving.Configuration:
+		return local.NamesFor("", o.Name), &o.Spec
+	}
+
+	fatalF("Error: cannot run a %s locally, expected a Service or Configuration\n", knative.Kind(objects[0]))
+	return local.Names{}, nil
+}
+
+func freePort() int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/julz/knightrider/pkg/webhooks"
+	"github.com/spf13/cobra"
+)
+
+var serveWebhooksConfig string
+var serveWebhooksAddr string
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [server]",
+	Short: "run a long-running kr server",
+}
+
+var serveWebhooks = &cobra.Command{
+	Use:   "webhooks",
+	Short: "create builds when github, gitlab or gitea repositories are pushed to",
+	Long: `serve webhooks receives push webhooks from github, gitlab and gitea, and for each push to a configured repository and branch either creates a Build of the pushed commit or updates a service's build to build it, rolling out a new revision.
+
+Pushes are verified with the secret the webhook was set up with, and builds are created with the same credentials as other kr commands, so run it as a service account which can create builds and update services.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if serveWebhooksConfig == "" {
+			fatalF("Error: --config is required\n")
+		}
+
+		f, err := os.Open(serveWebhooksConfig)
+		if err != nil {
+			fatalF("Error: %s\n", err)
+		}
+
+		config, err := webhooks.LoadConfig(f)
+		f.Close()
+		if err != nil {
+			fatalF("Error: %s: %s\n", serveWebhooksConfig, err)
+		}
+
+		server := &webhooks.Server{Config: config, Client: client(), Log: os.Stderr}
+		fmt.Fprintf(os.Stderr, "listening for webhooks for %d hooks on %s\n", len(config.Hooks), serveWebhooksAddr)
+		fatalF("Error: %s\n", http.ListenAndServe(serveWebhooksAddr, server))
+	},
+}
+
+func init() {
+	serveWebhooks.Flags().StringVarP(&serveWebhooksConfig, "config", "c", "", "yml file listing the repositories to build, see README")
+	serveWebhooks.Flags().StringVar(&serveWebhooksAddr, "addr", ":8080", "address to listen for webhooks on")
+
+	serveCmd.AddCommand(serveWebhooks)
+	root.AddCommand(serveCmd)
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/julz/knightrider/pkg/builds"
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+)
+
+// Exit codes for --wait, so CI can tell a failed build from a build which took
+// too long (or kr itself failing, which exits 1)
+const (
+	exitBuildFailed  = 2
+	exitBuildTimeout = 3
+)
+
+var wait bool
+var waitTimeout time.Duration
+var junitReport string
+
+// waitBuild is the name of the build to wait for once it has been created
+var waitBuild string
+
+// waitRevisionBuild is the name of the service or configuration whose new
+// revision's build to wait for once it has been created
+var waitRevisionBuild string
+
+// checkWait fails if --wait, --timeout or --junit were passed where there's
+// nothing to wait for. The flags are shared by every copy of the commands, but
+// there's only something to wait for once a build has been created
+func checkWait(hasBuild bool) {
+	if (wait || junitReport != "" || waitTimeout != 0) && kubectlVerb != "create" && kubectlVerb != "apply" && kubectlVerb != "replace" {
+		fatalF("Error: --wait, --timeout and --junit only work with kr build, or kr create, apply or replace\n")
+	}
+
+	if !hasBuild && (wait || buildTimeout != 0) {
+		fatalF("Error: --wait and --build-timeout need a build, pass one with --template, --step or --dockerfile\n")
+	}
+}
+
+func waitForBuild(name string) {
+	// the build's own timeout is only enforced by waiting for it
+	var b build.Build
+	if err := client().Get(kube.Builds, name, &b); err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	buildTimeout, err := knative.BuildTimeout(b.ObjectMeta)
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	waitFor(name, waitTimeout, buildTimeout)
+}
+
+// waitForRevisionBuild waits for the build of the revision a service or
+// configuration creates. The build is created by knative serving, so it
+// doesn't have the --build-timeout annotation, and --build-timeout is used
+func waitForRevisionBuild(configuration string) {
+	fmt.Fprintf(os.Stderr, "waiting for %s to create a revision\n", configuration)
+
+	started := time.Now()
+	w := &builds.Waiter{Client: client(), Timeout: waitTimeout}
+	name, err := w.RevisionBuild(configuration)
+	if err == builds.ErrTimeout {
+		fmt.Fprintf(os.Stderr, "%s did not create a revision within %s\n", configuration, waitTimeout)
+		os.Exit(exitBuildTimeout)
+	}
+
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	// --timeout covers waiting for the revision as well as its build
+	timeout := waitTimeout
+	if timeout > 0 {
+		if timeout -= time.Since(started); timeout <= 0 {
+			timeout = time.Nanosecond
+		}
+	}
+
+	waitFor(name, timeout, buildTimeout)
+}
+
+func waitFor(name string, waitTimeout, buildTimeout time.Duration) {
+	fmt.Fprintf(os.Stderr, "waiting for build %s to complete\n", name)
+
+	w := &builds.Waiter{Client: client(), Timeout: waitTimeout, BuildTimeout: buildTimeout}
+	result, err := w.Wait(name)
+	if err == builds.ErrTimeout {
+		fmt.Fprintf(os.Stderr, "build %s did not complete within %s\n", name, waitTimeout)
+		os.Exit(exitBuildTimeout)
+	}
+
+	if err == builds.ErrBuildTimeout {
+		fmt.Fprintf(os.Stderr, "build %s ran for longer than its %s timeout, and was cancelled\n", name, buildTimeout)
+		os.Exit(exitBuildTimeout)
+	}
+
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+
+	if junitReport != "" {
+		writeJUnit(result)
+	}
+
+	if result.Succeeded {
+		fmt.Fprintf(os.Stderr, "build %s succeeded\n", name)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "build %s failed: %s %s\n", name, result.Reason, result.Message)
+	if step := result.FailedStep(); step != nil {
+		fmt.Fprintf(os.Stderr, "step %s exited with code %d: %s\n", step.Name, step.State.Terminated.ExitCode, step.State.Terminated.Message)
+	}
+
+	os.Exit(exitBuildFailed)
+}
+
+func writeJUnit(result *builds.Result) {
+	f, err := os.Create(junitReport)
+	if err != nil {
+		fatalF("Error: %s\n", err)
+	}
+	defer f.Close()
+
+	if err := builds.WriteJUnit(f, result); err != nil {
+		fatalF("Error: %s\n", err)
+	}
+}
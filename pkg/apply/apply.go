@@ -0,0 +1,388 @@
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/julz/knightrider/pkg/kube"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Ref is a reference to an object by kind and name
+type Ref struct {
+	Kind string
+	Name string
+}
+
+func (r Ref) String() string {
+	return strings.ToLower(r.Kind) + "/" + r.Name
+}
+
+// order is the order kinds are applied in when nothing else decides it.
+// Kinds not listed, like ConfigMaps, go first along with Secrets
+var order = map[string]int{
+	"Secret":                0,
+	"ConfigMap":             0,
+	"PersistentVolumeClaim": 0,
+	"ServiceAccount":        1,
+	"BuildTemplate":         2,
+	"Build":                 3,
+	"Configuration":         3,
+	"Route":                 4,
+	"Service":               4,
+}
+
+// Error lists the problems which stop a set of objects being applied
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "cannot apply:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Applier applies multiple objects in the order their references to each
+// other need: the objects an object references are applied first and, where
+// they need to become ready before they can be used (like a Configuration a
+// Route sends traffic to), waited for
+type Applier struct {
+	Client kube.Client
+
+	// Timeout is how long to wait for each object to become ready, or zero to wait forever
+	Timeout time.Duration
+
+	// PollInterval is how often to check readiness, defaults to 2 seconds
+	PollInterval time.Duration
+
+	// Log receives progress messages, defaults to ioutil.Discard
+	Log io.Writer
+
+	// Sleep defaults to time.Sleep, and can be replaced in tests
+	Sleep func(time.Duration)
+}
+
+// Apply applies objects in dependency order. Cycles, and references to
+// objects which are neither in objects nor in the cluster, are reported
+// before anything is applied
+func (a *Applier) Apply(objects []interface{}) error {
+	levels, err := Plan(a.Client, objects)
+	if err != nil {
+		return err
+	}
+
+	applied := make(map[Ref]bool)
+	ready := make(map[Ref]bool)
+	for _, level := range levels {
+		for _, o := range level {
+			for _, ref := range References(o) {
+				if ref.Kind == "Configuration" && applied[ref] && !ready[ref] {
+					fmt.Fprintf(a.log(), "waiting for %s to become ready\n", ref)
+					if err := a.waitReady(ref.Name); err != nil {
+						return err
+					}
+
+					ready[ref] = true
+				}
+			}
+		}
+
+		if err := a.Client.Apply(level...); err != nil {
+			return err
+		}
+
+		for _, o := range level {
+			ref := refOf(o)
+			fmt.Fprintf(a.log(), "applied %s\n", ref)
+
+			applied[ref] = true
+			if config, ok := createdConfiguration(o); ok {
+				applied[config] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// Plan sorts objects in to levels, each of which only references objects in
+// earlier levels or already in the cluster, and within which objects are in
+// the order Secret, ConfigMap and PersistentVolumeClaim, ServiceAccount,
+// BuildTemplate, Build and Configuration, then Route and Service
+func Plan(c kube.Client, objects []interface{}) ([][]interface{}, error) {
+	byRef := index(objects)
+	problems, err := missing(c, objects, byRef)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := make([][]int, len(objects))
+	for i, o := range objects {
+		for _, ref := range References(o) {
+			if ref.Kind == "Revision" {
+				ref = Ref{Kind: "Configuration", Name: revisionConfiguration(ref.Name)}
+			}
+
+			if j, ok := byRef[ref]; ok && j != i {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+
+	depth := make([]int, len(objects))
+	state := make([]int, len(objects)) // 0 unvisited, 1 visiting, 2 done
+	var visit func(i int, path []int)
+	visit = func(i int, path []int) {
+		if state[i] == 2 {
+			return
+		}
+
+		if state[i] == 1 {
+			var cycle []string
+			for _, j := range path[indexOf(path, i):] {
+				cycle = append(cycle, refOf(objects[j]).String())
+			}
+
+			problems = append(problems, "cycle: "+strings.Join(append(cycle, refOf(objects[i]).String()), " -> "))
+			return
+		}
+
+		state[i] = 1
+		for _, j := range deps[i] {
+			visit(j, append(path, i))
+			if depth[j]+1 > depth[i] {
+				depth[i] = depth[j] + 1
+			}
+		}
+
+		state[i] = 2
+	}
+
+	for i := range objects {
+		visit(i, nil)
+	}
+
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+
+	sorted := make([]int, len(objects))
+	for i := range sorted {
+		sorted[i] = i
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if depth[a] != depth[b] {
+			return depth[a] < depth[b]
+		}
+
+		return order[refOf(objects[a]).Kind] < order[refOf(objects[b]).Kind]
+	})
+
+	var levels [][]interface{}
+	for n, i := range sorted {
+		if n == 0 || depth[i] != depth[sorted[n-1]] {
+			levels = append(levels, nil)
+		}
+
+		levels[len(levels)-1] = append(levels[len(levels)-1], objects[i])
+	}
+
+	return levels, nil
+}
+
+// References returns the objects o refers to: the Secrets, ConfigMaps,
+// PersistentVolumeClaims, ServiceAccounts and BuildTemplates used by
+// ServiceAccounts, builds and revision templates, the Configurations Routes
+// send traffic to, and the Revisions Routes and Services send traffic to. Any
+// version of any object can be passed, since references are found in its json
+// form
+func References(o interface{}) []Ref {
+	var m map[string]interface{}
+	if b, err := json.Marshal(o); err == nil {
+		json.Unmarshal(b, &m)
+	}
+
+	kind, _ := m["kind"].(string)
+
+	var refs []Ref
+	seen := make(map[Ref]bool)
+	add := func(kind string, name interface{}) {
+		ref := Ref{Kind: kind}
+		ref.Name, _ = name.(string)
+		if ref.Name != "" && !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	if kind == "ServiceAccount" {
+		for _, key := range []string{"secrets", "imagePullSecrets"} {
+			for _, s := range list(m[key]) {
+				add("Secret", s["name"])
+			}
+		}
+	}
+
+	if kind == "Build" {
+		if spec, ok := m["spec"].(map[string]interface{}); ok {
+			addTemplate(spec, add)
+		}
+	}
+
+	walk(m["spec"], "", func(key string, v map[string]interface{}) {
+		switch key {
+		case "build":
+			addTemplate(v, add)
+		case "secretKeyRef", "secretRef":
+			add("Secret", v["name"])
+		case "secret":
+			add("Secret", v["secretName"])
+		case "configMapKeyRef", "configMapRef", "configMap":
+			add("ConfigMap", v["name"])
+		case "persistentVolumeClaim":
+			add("PersistentVolumeClaim", v["claimName"])
+		}
+
+		add("ServiceAccount", v["serviceAccountName"])
+		if kind == "Route" {
+			add("Configuration", v["configurationName"])
+		}
+
+		if kind == "Route" || kind == "Service" {
+			add("Revision", v["revisionName"])
+		}
+	})
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].String() < refs[j].String() })
+	return refs
+}
+
+func addTemplate(build map[string]interface{}, add func(string, interface{})) {
+	if template, ok := build["template"].(map[string]interface{}); ok {
+		add("BuildTemplate", template["name"])
+	}
+}
+
+// walk calls fn with every map inside v, and the key it was found under
+func walk(v interface{}, key string, fn func(key string, v map[string]interface{})) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		fn(key, v)
+		for k, child := range v {
+			walk(child, k, fn)
+		}
+	case []interface{}:
+		for _, child := range v {
+			walk(child, key, fn)
+		}
+	}
+}
+
+func list(v interface{}) []map[string]interface{} {
+	var items []map[string]interface{}
+	l, _ := v.([]interface{})
+	for _, item := range l {
+		if m, ok := item.(map[string]interface{}); ok {
+			items = append(items, m)
+		}
+	}
+
+	return items
+}
+
+func refOf(o interface{}) Ref {
+	_, ref := identify(o)
+	return ref
+}
+
+// createdConfiguration returns the Configuration a knative Service creates
+func createdConfiguration(o interface{}) (Ref, bool) {
+	apiVersion, ref := identify(o)
+	if ref.Kind != "Service" || !strings.HasPrefix(apiVersion, "serving.knative.dev/") {
+		return Ref{}, false
+	}
+
+	return Ref{Kind: "Configuration", Name: ref.Name}, true
+}
+
+func identify(o interface{}) (string, Ref) {
+	var meta struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+
+	if b, err := json.Marshal(o); err == nil {
+		json.Unmarshal(b, &meta)
+	}
+
+	return meta.APIVersion, Ref{Kind: meta.Kind, Name: meta.Metadata.Name}
+}
+
+func indexOf(path []int, i int) int {
+	for n, j := range path {
+		if j == i {
+			return n
+		}
+	}
+
+	return 0
+}
+
+// waitReady waits for the named Configuration to become ready, failing if it
+// becomes not ready or Timeout passes. Until the Configuration has observed
+// the generation which was applied, its Ready condition is about an earlier
+// generation, so it isn't looked at
+func (a *Applier) waitReady(name string) error {
+	poll := a.PollInterval
+	if poll == 0 {
+		poll = 2 * time.Second
+	}
+
+	for waited := time.Duration(0); ; waited += poll {
+		var config serving.Configuration
+		if err := a.Client.Get(kube.Configurations, name, &config); err != nil {
+			return err
+		}
+
+		if config.Status.ObservedGeneration >= config.Spec.Generation {
+			if c := config.Status.GetCondition(serving.ConfigurationConditionReady); c != nil && c.Status == corev1.ConditionTrue {
+				return nil
+			} else if c != nil && c.Status == corev1.ConditionFalse {
+				return fmt.Errorf("configuration %s is not ready: %s %s", name, c.Reason, c.Message)
+			}
+		}
+
+		if a.Timeout > 0 && waited >= a.Timeout {
+			return fmt.Errorf("timed out after %s waiting for configuration %s to become ready", a.Timeout, name)
+		}
+
+		a.sleep(poll)
+	}
+}
+
+func (a *Applier) log() io.Writer {
+	if a.Log == nil {
+		return ioutil.Discard
+	}
+
+	return a.Log
+}
+
+func (a *Applier) sleep(d time.Duration) {
+	if a.Sleep == nil {
+		time.Sleep(d)
+		return
+	}
+
+	a.Sleep(d)
+}
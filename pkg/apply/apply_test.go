@@ -0,0 +1,226 @@
+package apply_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julz/knightrider/pkg/apply"
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/knative/v1"
+	"github.com/julz/knightrider/pkg/kube"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func names(objects []interface{}) []string {
+	var names []string
+	for _, o := range objects {
+		names = append(names, knative.Kind(o)+"/"+o.(metav1.Object).GetName())
+	}
+
+	return names
+}
+
+func app() []interface{} {
+	secret := knative.NewSecret("git-creds", knative.WithBasicAuth("user", "pass"))
+	sa := knative.NewServiceAccount("builder", knative.WithSecrets("git-creds"))
+
+	return []interface{}{
+		knative.NewRoute("web", knative.WithTrafficToConfiguration("", "web", 100)),
+		knative.NewConfiguration("web", knative.WithBuild(knative.WithServiceAccount("builder"), knative.WithBuildTemplate("kaniko", nil, nil))),
+		knative.NewBuildTemplate("kaniko"),
+		&sa,
+		&secret,
+	}
+}
+
+func TestPlan(t *testing.T) {
+	levels, err := apply.Plan(kube.NewFake(), app())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order [][]string
+	for _, level := range levels {
+		order = append(order, names(level))
+	}
+
+	errorIfNotEqual(t, order, [][]string{
+		{"Secret/git-creds", "BuildTemplate/kaniko"},
+		{"ServiceAccount/builder"},
+		{"Configuration/web"},
+		{"Route/web"},
+	}, "expected levels to be '%v' but were '%v'")
+}
+
+func TestPlanUsesObjectsInTheCluster(t *testing.T) {
+	sa := knative.NewServiceAccount("builder")
+	c := kube.NewFake(&sa)
+
+	levels, err := apply.Plan(c, []interface{}{
+		knative.NewRunLatestService("web", knative.WithRevisionServiceAccount("builder")),
+		knative.NewRoute("web-preview", knative.WithTrafficToConfiguration("", "web", 100)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, len(levels), 2, "expected the route to be applied after the service which creates its configuration, got %d levels, expected %d")
+}
+
+func TestPlanReportsDanglingReferences(t *testing.T) {
+	_, err := apply.Plan(kube.NewFake(), []interface{}{
+		knative.NewBuild("nightly", knative.WithServiceAccount("builder"), knative.WithBuildTemplate("kaniko", nil, nil)),
+		knative.NewRoute("web", knative.WithTrafficToConfiguration("", "web", 100)),
+	})
+
+	e, ok := err.(*apply.Error)
+	if !ok {
+		t.Fatalf("expected an *apply.Error but got %v", err)
+	}
+
+	errorIfNotEqual(t, e.Problems, []string{
+		"build/nightly references buildtemplate/kaniko, which does not exist",
+		"build/nightly references serviceaccount/builder, which does not exist",
+		"route/web references configuration/web, which does not exist",
+	}, "expected problems to be '%v' but were '%v'")
+}
+
+func TestPlanReportsCycles(t *testing.T) {
+	// real objects can't reference each other in a cycle, but unknown kinds are
+	// only known by what they reference
+	secret := map[string]interface{}{
+		"apiVersion": "example.com/v1", "kind": "Secret", "metadata": map[string]interface{}{"name": "a"},
+		"spec": map[string]interface{}{"serviceAccountName": "b"},
+	}
+
+	sa := knative.NewServiceAccount("b", knative.WithSecrets("a"))
+
+	_, err := apply.Plan(kube.NewFake(), []interface{}{secret, &sa})
+	if err == nil || !strings.Contains(err.Error(), "cycle: ") {
+		t.Errorf("expected a cycle to be reported, got %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	c := kube.NewFake()
+
+	var log bytes.Buffer
+	var slept []time.Duration
+	a := &apply.Applier{
+		Client: c,
+		Log:    &log,
+		Sleep: func(d time.Duration) {
+			slept = append(slept, d)
+
+			config := knative.NewConfiguration("web")
+			config.Status.Conditions = []serving.ConfigurationCondition{{Type: serving.ConfigurationConditionReady, Status: corev1.ConditionTrue}}
+			c.Add(config)
+		},
+	}
+
+	if err := a.Apply(app()); err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, names(c.Applied), []string{"Secret/git-creds", "BuildTemplate/kaniko", "ServiceAccount/builder", "Configuration/web", "Route/web"}, "expected applied objects to be '%v' but were '%v'")
+	errorIfNotEqual(t, len(slept), 1, "expected to wait for the configuration %d time but waited %d times")
+	if !strings.Contains(log.String(), "waiting for configuration/web to become ready\n") {
+		t.Errorf("expected the wait to be logged, but got '%s'", log.String())
+	}
+}
+
+func TestApplyWaitsForTheAppliedGeneration(t *testing.T) {
+	// the configuration is still ready from before the new generation was applied
+	stale := knative.NewConfiguration("web")
+	stale.Spec.Generation = 2
+	stale.Status.ObservedGeneration = 1
+	stale.Status.Conditions = []serving.ConfigurationCondition{{Type: serving.ConfigurationConditionReady, Status: corev1.ConditionTrue}}
+
+	c := kube.NewFake(stale)
+
+	var slept []time.Duration
+	a := &apply.Applier{
+		Client: c,
+		Sleep: func(d time.Duration) {
+			slept = append(slept, d)
+
+			config := knative.NewConfiguration("web")
+			config.Spec.Generation = 2
+			config.Status.ObservedGeneration = 2
+			config.Status.Conditions = []serving.ConfigurationCondition{{Type: serving.ConfigurationConditionReady, Status: corev1.ConditionTrue}}
+			c.Add(config)
+		},
+	}
+
+	// the fake doesn't bump the generation of applied objects like the server does
+	objects := app()
+	objects[1].(*serving.Configuration).Spec.Generation = 2
+
+	if err := a.Apply(objects); err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, len(slept), 1, "expected to wait for the configuration %d time but waited %d times")
+}
+
+func TestApplyFailsIfAConfigurationIsNotReady(t *testing.T) {
+	c := kube.NewFake()
+	a := &apply.Applier{
+		Client: c,
+		Sleep: func(d time.Duration) {
+			config := knative.NewConfiguration("web")
+			config.Status.Conditions = []serving.ConfigurationCondition{{Type: serving.ConfigurationConditionReady, Status: corev1.ConditionFalse, Reason: "RevisionFailed"}}
+			c.Add(config)
+		},
+	}
+
+	if err := a.Apply(app()); err == nil || !strings.Contains(err.Error(), "RevisionFailed") {
+		t.Errorf("expected an error saying why the configuration isn't ready, got %v", err)
+	}
+
+	for _, o := range c.Applied {
+		if knative.Kind(o) == "Route" {
+			t.Error("expected the route not to be applied")
+		}
+	}
+}
+
+func TestApplyChecksReferencesFirst(t *testing.T) {
+	c := kube.NewFake()
+	objects := append(app(), knative.NewBuild("nightly", knative.WithServiceAccount("missing")))
+
+	if err := (&apply.Applier{Client: c}).Apply(objects); err == nil {
+		t.Error("expected an error for the missing service account")
+	}
+
+	errorIfNotEqual(t, len(c.Applied), 0, "expected %d objects to be applied but got %d")
+}
+
+func TestReferences(t *testing.T) {
+	env := corev1.EnvVar{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "api-token"}}}}
+
+	v1alpha1 := knative.NewRunLatestService("web", knative.WithEnvVar(env), knative.WithRevisionServiceAccount("runner"))
+	errorIfNotEqual(t, apply.References(v1alpha1), []apply.Ref{{Kind: "Secret", Name: "api-token"}, {Kind: "ServiceAccount", Name: "runner"}}, "expected references to be '%v' but were '%v'")
+
+	service, err := knative.ForVersion(v1alpha1, knative.V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, apply.References(service.(*v1.Service)), []apply.Ref{{Kind: "Secret", Name: "api-token"}, {Kind: "ServiceAccount", Name: "runner"}}, "expected v1 references to be '%v' but were '%v'")
+
+	sa := knative.NewServiceAccount("builder", knative.WithSecrets("git-creds"))
+	sa.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+	errorIfNotEqual(t, apply.References(&sa), []apply.Ref{{Kind: "Secret", Name: "git-creds"}, {Kind: "Secret", Name: "registry"}}, "expected service account references to be '%v' but were '%v'")
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
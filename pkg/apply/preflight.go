@@ -0,0 +1,149 @@
+package apply
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/julz/knightrider/pkg/kube"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// resources are the resources referenced kinds are fetched from when
+// checking they exist in the cluster
+var resources = map[string]string{
+	"Secret":                kube.Secrets,
+	"ConfigMap":             kube.ConfigMaps,
+	"PersistentVolumeClaim": kube.PersistentVolumeClaims,
+	"ServiceAccount":        kube.ServiceAccounts,
+	"BuildTemplate":         kube.BuildTemplates,
+	"Configuration":         kube.Configurations,
+	"Revision":              kube.Revisions,
+}
+
+// Preflight checks that everything objects reference (see References) is
+// either one of objects or already exists in the cluster, as are the secrets
+// of any service accounts they use, so that objects which could never work
+// aren't applied. Anything missing is listed in an *Error
+func Preflight(c kube.Client, objects []interface{}) error {
+	problems, err := missing(c, objects, index(objects))
+	if err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+
+	return nil
+}
+
+// index returns the position of each object in objects, including the
+// Configurations created by Services
+func index(objects []interface{}) map[Ref]int {
+	byRef := make(map[Ref]int)
+	for i, o := range objects {
+		byRef[refOf(o)] = i
+	}
+
+	for i, o := range objects {
+		if config, ok := createdConfiguration(o); ok {
+			if _, exists := byRef[config]; !exists {
+				byRef[config] = i
+			}
+		}
+	}
+
+	return byRef
+}
+
+// missing describes each reference in objects to something which is neither
+// in objects nor in the cluster
+func missing(c kube.Client, objects []interface{}, byRef map[Ref]int) ([]string, error) {
+	found := make(map[Ref]bool)
+	exists := func(ref Ref) (bool, error) {
+		if ok, checked := found[ref]; checked {
+			return ok, nil
+		}
+
+		var o map[string]interface{}
+		err := c.Get(resources[ref.Kind], ref.Name, &o)
+		if err != nil && !kube.IsNotFound(err) {
+			return false, err
+		}
+
+		found[ref] = err == nil
+		return found[ref], nil
+	}
+
+	var problems []string
+	for _, o := range objects {
+		for _, ref := range References(o) {
+			if _, ok := byRef[ref]; ok {
+				continue
+			}
+
+			// revisions are created by their configuration, so can't exist
+			// until it has been applied
+			if _, ok := byRef[Ref{Kind: "Configuration", Name: revisionConfiguration(ref.Name)}]; ok && ref.Kind == "Revision" {
+				continue
+			}
+
+			ok, err := exists(ref)
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s references %s, which does not exist", refOf(o), ref))
+				continue
+			}
+
+			if ref.Kind != "ServiceAccount" {
+				continue
+			}
+
+			var sa corev1.ServiceAccount
+			if err := c.Get(kube.ServiceAccounts, ref.Name, &sa); err != nil {
+				return nil, err
+			}
+
+			var secrets []Ref
+			for _, s := range sa.Secrets {
+				secrets = append(secrets, Ref{Kind: "Secret", Name: s.Name})
+			}
+
+			for _, s := range sa.ImagePullSecrets {
+				secrets = append(secrets, Ref{Kind: "Secret", Name: s.Name})
+			}
+
+			for _, secret := range secrets {
+				if _, ok := byRef[secret]; ok {
+					continue
+				}
+
+				ok, err := exists(secret)
+				if err != nil {
+					return nil, err
+				}
+
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%s references %s, which uses %s, which does not exist", refOf(o), ref, secret))
+				}
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+var revisionName = regexp.MustCompile(`^(.*)-[0-9]{5}$`)
+
+// revisionConfiguration returns the name of the configuration which created a
+// revision, going by knative's NAME-00001 naming of revisions
+func revisionConfiguration(revision string) string {
+	if m := revisionName.FindStringSubmatch(revision); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
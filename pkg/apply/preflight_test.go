@@ -0,0 +1,105 @@
+package apply_test
+
+import (
+	"testing"
+
+	"github.com/julz/knightrider/pkg/apply"
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func problems(t *testing.T, err error) []string {
+	if err == nil {
+		return nil
+	}
+
+	e, ok := err.(*apply.Error)
+	if !ok {
+		t.Fatalf("expected an *apply.Error but got %v", err)
+	}
+
+	return e.Problems
+}
+
+func TestPreflightService(t *testing.T) {
+	sa := knative.NewServiceAccount("buildbot", knative.WithSecrets("git-creds", "docker-creds"))
+	dockerCreds := knative.NewSecret("docker-creds")
+	c := kube.NewFake(&sa, &dockerCreds)
+
+	s := knative.NewRunLatestService("web", knative.WithBuild(
+		knative.WithServiceAccount("buildbot"),
+		knative.WithBuildTemplate("kaniko", nil, nil),
+	))
+
+	errorIfNotEqual(t, problems(t, apply.Preflight(c, []interface{}{s})), []string{
+		"service/web references buildtemplate/kaniko, which does not exist",
+		"service/web references serviceaccount/buildbot, which uses secret/git-creds, which does not exist",
+	}, "expected problems to be '%v' but were '%v'")
+
+	gitCreds := knative.NewSecret("git-creds")
+	c.Add(knative.NewBuildTemplate("kaniko"), &gitCreds)
+	errorIfNotEqual(t, problems(t, apply.Preflight(c, []interface{}{s})), []string(nil), "expected no problems, '%v', but got '%v'")
+}
+
+func TestPreflightServiceAccount(t *testing.T) {
+	sa := knative.NewServiceAccount("buildbot", knative.WithSecrets("git-creds"))
+
+	errorIfNotEqual(t, problems(t, apply.Preflight(kube.NewFake(), []interface{}{&sa})), []string{
+		"serviceaccount/buildbot references secret/git-creds, which does not exist",
+	}, "expected problems to be '%v' but were '%v'")
+}
+
+func TestPreflightRoute(t *testing.T) {
+	route := knative.NewRoute("web",
+		knative.WithTrafficToRevision("", "web-00001", 90),
+		knative.WithTrafficToRevision("", "web-00002", 0),
+		knative.WithTrafficToConfiguration("next", "web", 10),
+	)
+
+	c := kube.NewFake(&serving.Revision{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "serving.knative.dev/v1alpha1", Kind: "Revision"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web-00001"},
+	})
+
+	errorIfNotEqual(t, problems(t, apply.Preflight(c, []interface{}{route})), []string{
+		"route/web references configuration/web, which does not exist",
+		"route/web references revision/web-00002, which does not exist",
+	}, "expected problems to be '%v' but were '%v'")
+
+	// revisions can't exist before the configuration which creates them
+	errorIfNotEqual(t, problems(t, apply.Preflight(c, []interface{}{route, knative.NewConfiguration("web")})), []string(nil), "expected no problems, '%v', but got '%v'")
+}
+
+func TestPreflightPinnedService(t *testing.T) {
+	s := knative.NewPinnedService("web", "web-00003")
+
+	errorIfNotEqual(t, problems(t, apply.Preflight(kube.NewFake(), []interface{}{s})), []string(nil), "expected the revision of the service itself not to be checked, '%v', but got '%v'")
+
+	errorIfNotEqual(t, problems(t, apply.Preflight(kube.NewFake(), []interface{}{knative.NewPinnedService("web", "other-00001")})), []string{
+		"service/web references revision/other-00001, which does not exist",
+	}, "expected problems to be '%v' but were '%v'")
+}
+
+func TestPreflightBuildVolumes(t *testing.T) {
+	b := knative.NewBuild("cached",
+		knative.WithStep("compile", "maven", "mvn", "package"),
+		knative.WithBuildVolume("settings", knative.ConfigMapVolume("maven-settings")),
+		knative.WithBuildVolume("cache", knative.ClaimVolume("maven-cache")),
+	)
+
+	errorIfNotEqual(t, problems(t, apply.Preflight(kube.NewFake(), []interface{}{b})), []string{
+		"build/cached references configmap/maven-settings, which does not exist",
+		"build/cached references persistentvolumeclaim/maven-cache, which does not exist",
+	}, "expected problems to be '%v' but were '%v'")
+
+	// the claim can be applied along with the build
+	claim := knative.NewPersistentVolumeClaim("maven-cache", resource.MustParse("1Gi"))
+	errorIfNotEqual(t, problems(t, apply.Preflight(kube.NewFake(&corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "maven-settings"},
+	}), []interface{}{b, &claim})), []string(nil), "expected no problems, '%v', but got '%v'")
+}
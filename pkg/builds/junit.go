@@ -0,0 +1,90 @@
+package builds
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     float64     `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes a JUnit XML report of a Build's Result, with one test case
+// per step. Steps which never ran are reported as skipped
+func WriteJUnit(w io.Writer, r *Result) error {
+	suite := junitSuite{Name: r.Build.Name}
+	if !r.Build.Status.StartTime.IsZero() && !r.Build.Status.CompletionTime.IsZero() {
+		suite.Time = r.Build.Status.CompletionTime.Sub(r.Build.Status.StartTime.Time).Seconds()
+	}
+
+	for _, s := range r.Steps {
+		tc := junitCase{Name: s.Name, ClassName: r.Build.Name}
+
+		t := s.State.Terminated
+		if t == nil {
+			tc.Skipped = &struct{}{}
+			suite.Skipped++
+			suite.Cases = append(suite.Cases, tc)
+			continue
+		}
+
+		if !t.StartedAt.IsZero() && !t.FinishedAt.IsZero() {
+			tc.Time = t.FinishedAt.Sub(t.StartedAt.Time).Seconds()
+		}
+
+		if t.ExitCode != 0 {
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("step %s exited with code %d", s.Name, t.ExitCode),
+				Body:    t.Message,
+			}
+			suite.Failures++
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	// a build can fail before any step runs, e.g. if its template doesn't exist
+	if len(r.Steps) == 0 && !r.Succeeded {
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      "build",
+			ClassName: r.Build.Name,
+			Failure:   &junitFailure{Message: r.Reason, Body: r.Message},
+		})
+		suite.Failures++
+	}
+
+	suite.Tests = len(suite.Cases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,32 @@
+package builds_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/builds"
+	"github.com/julz/knightrider/pkg/kube"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	b := failedBuild()
+	result := &builds.Result{Build: b, Reason: "BuildFailed", Steps: builds.Steps(kube.NewFake(buildPod()), b)}
+
+	var out bytes.Buffer
+	if err := builds.WriteJUnit(&out, result); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`<testsuite name="my-build" tests="3" failures="1" skipped="1"`,
+		`<testcase name="git-source" classname="my-build" time="0"></testcase>`,
+		`<failure message="step compile exited with code 1">compilation failed</failure>`,
+		`<testcase name="push" classname="my-build" time="0">`,
+		`<skipped></skipped>`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected report to contain %s but was:\n%s", expected, out.String())
+		}
+	}
+}
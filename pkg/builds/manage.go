@@ -0,0 +1,178 @@
+package builds
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/julz/knightrider/pkg/git"
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+)
+
+// RerunOfAnnotation records the name of the Build a rerun was copied from
+const RerunOfAnnotation = knative.AnnotationPrefix + "rerun-of"
+
+var rerunSuffix = regexp.MustCompile(`^(.*)-(\d+)$`)
+
+// Rerun creates a copy of the named Build, which runs it again with the same
+// spec. The copy is named after the original with an incremented suffix, e.g.
+// my-build-2, then my-build-3 (whether my-build or my-build-2 is rerun), and
+// has a RerunOfAnnotation
+func Rerun(c kube.Client, name string) (*build.Build, error) {
+	var b build.Build
+	if err := c.Get(kube.Builds, name, &b); err != nil {
+		return nil, err
+	}
+
+	// reruns of reruns are numbered along with the original's reruns
+	base := name
+	if m := rerunSuffix.FindStringSubmatch(name); m != nil && b.Annotations[RerunOfAnnotation] != "" {
+		base = m[1]
+	}
+
+	var existing build.BuildList
+	if err := c.List(kube.Builds, "", &existing); err != nil {
+		return nil, err
+	}
+
+	next := 2
+	for _, e := range existing.Items {
+		if m := rerunSuffix.FindStringSubmatch(e.Name); m != nil && m[1] == base {
+			if n, err := strconv.Atoi(m[2]); err == nil && n >= next {
+				next = n + 1
+			}
+		}
+	}
+
+	rerun := knative.NewBuild(fmt.Sprintf("%s-%d", base, next))
+	rerun.Spec = b.Spec
+	rerun.Spec.Generation = 0
+
+	rerun.Labels = b.Labels
+	for k, v := range b.Annotations {
+		if k != "kubectl.kubernetes.io/last-applied-configuration" {
+			knative.Annotate(&rerun.ObjectMeta, k, v)
+		}
+	}
+
+	knative.Annotate(&rerun.ObjectMeta, RerunOfAnnotation, name)
+	if err := c.Apply(rerun); err != nil {
+		return nil, err
+	}
+
+	return rerun, nil
+}
+
+// Cancel stops a running Build by deleting its pod, which gives the step
+// running at the time a chance to shut down cleanly, and returns the pod's name
+func Cancel(c kube.Client, name string) (string, error) {
+	var b build.Build
+	if err := c.Get(kube.Builds, name, &b); err != nil {
+		return "", err
+	}
+
+	if Completed(&b) != nil {
+		return "", fmt.Errorf("build %s has already completed", name)
+	}
+
+	if b.Status.Cluster == nil || b.Status.Cluster.PodName == "" {
+		return "", fmt.Errorf("build %s hasn't started a pod yet, try again in a moment", name)
+	}
+
+	pod := b.Status.Cluster.PodName
+	if err := c.Delete(kube.Pods, pod); err != nil {
+		return "", err
+	}
+
+	return pod, nil
+}
+
+// Source returns what a Build builds, so builds of the same thing can be
+// grouped: the url of its git source, the repository (without the tag or
+// digest) of the source image it unpacks, the location of its GCS source, or
+// "" if it doesn't have a source
+func Source(b *build.Build) string {
+	s := b.Spec.Source
+	switch {
+	case s == nil:
+		return ""
+	case s.Git != nil:
+		return git.NormalizeURL(s.Git.Url)
+	case s.GCS != nil:
+		return s.GCS.Location
+	case s.Custom != nil:
+		if image := sourceImage(s.Custom.Args); image != "" {
+			return image
+		}
+
+		return s.Custom.Image
+	}
+
+	return ""
+}
+
+var craneExport = regexp.MustCompile(`crane export (?:--insecure )?([^\s@]+?)(?::[\w][\w.-]*)?(?:@\S+)?\s`)
+
+// sourceImage returns the repository of the image a knative.WithSourceImage
+// source unpacks, or ""
+func sourceImage(args []string) string {
+	for _, arg := range args {
+		if m := craneExport.FindStringSubmatch(arg); m != nil {
+			return m[1]
+		}
+	}
+
+	return ""
+}
+
+// Prunable returns the completed Builds to delete so that only the keep most
+// recently completed builds of each Source are left, oldest first. Builds
+// which are still running, or which belong to another object (such as the
+// build of a revision), are always kept
+func Prunable(c kube.Client, keep int) ([]build.Build, error) {
+	var list build.BuildList
+	if err := c.List(kube.Builds, "", &list); err != nil {
+		return nil, err
+	}
+
+	bySource := make(map[string][]build.Build)
+	var sources []string
+	for _, b := range list.Items {
+		if Completed(&b) == nil || len(b.OwnerReferences) > 0 {
+			continue
+		}
+
+		source := Source(&b)
+		if _, ok := bySource[source]; !ok {
+			sources = append(sources, source)
+		}
+
+		bySource[source] = append(bySource[source], b)
+	}
+
+	sort.Strings(sources)
+
+	var prunable []build.Build
+	for _, source := range sources {
+		builds := bySource[source]
+		sort.Slice(builds, func(i, j int) bool { return newer(builds[i], builds[j]) })
+		for i := len(builds) - 1; i >= keep; i-- {
+			prunable = append(prunable, builds[i])
+		}
+	}
+
+	return prunable, nil
+}
+
+// newer returns true if a completed after b, or at the same time with a
+// greater name
+func newer(a, b build.Build) bool {
+	if !a.Status.CompletionTime.Equal(&b.Status.CompletionTime) {
+		return b.Status.CompletionTime.Before(&a.Status.CompletionTime)
+	}
+
+	return a.Name > b.Name
+}
@@ -0,0 +1,137 @@
+package builds_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/julz/knightrider/pkg/builds"
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRerun(t *testing.T) {
+	b := failedBuild()
+	b.Spec = knative.NewBuild("", knative.WithGitSource("https://github.com/me/app", "master"), knative.WithBuildTemplate("kaniko", nil, nil)).Spec
+	b.Labels = map[string]string{"app": "web"}
+	b.Annotations = map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}", "team": "a"}
+
+	c := kube.NewFake(b)
+	rerun, err := builds.Rerun(c, "my-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, rerun.Name, "my-build-2", "expected rerun to be called '%s' but was called '%s'")
+	errorIfNotEqual(t, rerun.Spec, b.Spec, "expected rerun to have spec '%v' but had '%v'")
+	errorIfNotEqual(t, rerun.Status, build.BuildStatus{}, "expected rerun not to copy the status, '%v', but had '%v'")
+	errorIfNotEqual(t, rerun.Labels, map[string]string{"app": "web"}, "expected rerun to have labels '%v' but had '%v'")
+	errorIfNotEqual(t, rerun.Annotations, map[string]string{"team": "a", builds.RerunOfAnnotation: "my-build"}, "expected rerun to have annotations '%v' but had '%v'")
+
+	// reruns of the rerun carry on counting from the original
+	again, err := builds.Rerun(c, "my-build-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, again.Name, "my-build-3", "expected second rerun to be called '%s' but was called '%s'")
+	errorIfNotEqual(t, again.Annotations[builds.RerunOfAnnotation], "my-build-2", "expected second rerun to be a rerun of '%s' but was a rerun of '%s'")
+
+	// builds which just happen to end in a number aren't reruns
+	c.Add(knative.NewBuild("release-2019"))
+	release, err := builds.Rerun(c, "release-2019")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, release.Name, "release-2019-2", "expected rerun to be called '%s' but was called '%s'")
+}
+
+func TestCancel(t *testing.T) {
+	running := knative.NewBuild("running")
+	running.Status.Cluster = &build.ClusterSpec{PodName: "running-pod"}
+	running.Status.Conditions = []build.BuildCondition{{Type: build.BuildSucceeded, Status: corev1.ConditionUnknown}}
+
+	pending := knative.NewBuild("pending")
+
+	c := kube.NewFake(running, pending, failedBuild(), &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "running-pod"},
+	})
+
+	pod, err := builds.Cancel(c, "running")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, pod, "running-pod", "expected pod '%s' to be deleted but deleted '%s'")
+	errorIfNotEqual(t, c.Deleted, []string{"pods/running-pod"}, "expected '%v' to be deleted but '%v' were")
+
+	if _, err := builds.Cancel(c, "my-build"); err == nil {
+		t.Error("expected cancelling a completed build to fail")
+	}
+
+	if _, err := builds.Cancel(c, "pending"); err == nil {
+		t.Error("expected cancelling a build without a pod to fail")
+	}
+}
+
+func TestSource(t *testing.T) {
+	for expected, b := range map[string]*build.Build{
+		"https://github.com/me/app":  knative.NewBuild("git", knative.WithGitSource("git@github.com:me/app.git", "master")),
+		"localhost:5000/app-source":  knative.NewBuild("local", knative.WithSourceImage("localhost:5000/app-source@sha256:abc", true)),
+		"gcr.io/me/app-source":       knative.NewBuild("tagged", knative.WithSourceImage("gcr.io/me/app-source:dev", false)),
+		"":                           knative.NewBuild("none"),
+		"docker.io/me/custom-source": knative.NewBuild("custom", knative.WithCustomSource(corev1.Container{Image: "docker.io/me/custom-source"})),
+	} {
+		errorIfNotEqual(t, builds.Source(b), expected, b.Name+": expected source to be '%s' but was '%s'")
+	}
+}
+
+func TestPrunable(t *testing.T) {
+	now := time.Now()
+	completed := func(name, repo string, ago time.Duration) *build.Build {
+		b := knative.NewBuild(name, knative.WithGitSource(repo, "master"))
+		b.Status.CompletionTime = metav1.NewTime(now.Add(-ago))
+		b.Status.Conditions = []build.BuildCondition{{Type: build.BuildSucceeded, Status: corev1.ConditionTrue}}
+		return b
+	}
+
+	running := completed("app-running", "https://github.com/me/app", 0)
+	running.Status.Conditions[0].Status = corev1.ConditionUnknown
+
+	owned := completed("app-revision", "https://github.com/me/app", 10*time.Hour)
+	owned.OwnerReferences = []metav1.OwnerReference{{Kind: "Revision", Name: "app-00001"}}
+
+	c := kube.NewFake(
+		completed("app-1", "https://github.com/me/app", 4*time.Hour),
+		completed("app-2", "https://github.com/me/app", 3*time.Hour),
+		completed("app-3", "git@github.com:me/app.git", 2*time.Hour),
+		completed("app-4", "https://github.com/me/app", time.Hour),
+		completed("lib-1", "https://github.com/me/lib", 5*time.Hour),
+		completed("lib-2", "https://github.com/me/lib", time.Hour),
+		running,
+		owned,
+	)
+
+	prunable, err := builds.Prunable(c, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, b := range prunable {
+		names = append(names, b.Name)
+	}
+
+	errorIfNotEqual(t, names, []string{"app-1", "app-2"}, "expected '%v' to be pruned but '%v' were")
+
+	all, err := builds.Prunable(c, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, len(all), 6, "expected keeping none to prune %d builds but it pruned %d")
+}
@@ -0,0 +1,215 @@
+package builds
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julz/knightrider/pkg/kube"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ErrTimeout is returned by Wait if the Build does not complete in time
+var ErrTimeout = errors.New("timed out waiting for build to complete")
+
+// ErrBuildTimeout is returned by Wait if the Build runs for longer than the
+// Waiter's BuildTimeout, and so was cancelled
+var ErrBuildTimeout = errors.New("build ran for longer than its timeout, and was cancelled")
+
+// Step is the name and final state of one step of a Build
+type Step struct {
+	Name  string
+	State corev1.ContainerState
+}
+
+// Result is the outcome of a completed Build
+type Result struct {
+	Build     *build.Build
+	Succeeded bool
+	Reason    string
+	Message   string
+	Steps     []Step
+}
+
+// Waiter waits for Builds to complete
+type Waiter struct {
+	Client kube.Client
+
+	// Timeout is how long to wait for, or zero to wait forever
+	Timeout time.Duration
+
+	// BuildTimeout, if not zero, is how long the Build may run for (since it
+	// started, or since waiting started if it hasn't) before it is cancelled
+	BuildTimeout time.Duration
+
+	// PollInterval is how often to check the Build, defaults to 2 seconds
+	PollInterval time.Duration
+
+	// Sleep defaults to time.Sleep, and can be replaced in tests
+	Sleep func(time.Duration)
+}
+
+// Wait waits for the named Build's Succeeded condition to become True or False
+// and returns the Result, or returns ErrTimeout if Timeout passes first
+func (w *Waiter) Wait(name string) (*Result, error) {
+	poll := w.PollInterval
+	if poll == 0 {
+		poll = 2 * time.Second
+	}
+
+	for waited := time.Duration(0); ; waited += poll {
+		var b build.Build
+		if err := w.Client.Get(kube.Builds, name, &b); err != nil {
+			return nil, err
+		}
+
+		if c := Completed(&b); c != nil {
+			return &Result{
+				Build:     &b,
+				Succeeded: c.Status == corev1.ConditionTrue,
+				Reason:    c.Reason,
+				Message:   c.Message,
+				Steps:     Steps(w.Client, &b),
+			}, nil
+		}
+
+		running := waited
+		if !b.Status.StartTime.IsZero() {
+			running = time.Since(b.Status.StartTime.Time)
+		}
+
+		// if the build completes or hasn't got a pod yet, cancelling fails and
+		// the next poll sees what happened
+		if w.BuildTimeout > 0 && running >= w.BuildTimeout {
+			if _, err := Cancel(w.Client, name); err == nil {
+				return nil, ErrBuildTimeout
+			}
+		}
+
+		if w.Timeout > 0 && waited >= w.Timeout {
+			return nil, ErrTimeout
+		}
+
+		if w.Sleep == nil {
+			time.Sleep(poll)
+		} else {
+			w.Sleep(poll)
+		}
+	}
+}
+
+// RevisionBuild waits for the named Configuration (or the Configuration of a
+// Service, which has the same name) to create a Revision for its latest
+// generation, and returns the name of the Build of that Revision. It returns
+// ErrTimeout if Timeout passes first
+func (w *Waiter) RevisionBuild(configuration string) (string, error) {
+	poll := w.PollInterval
+	if poll == 0 {
+		poll = 2 * time.Second
+	}
+
+	for waited := time.Duration(0); ; waited += poll {
+		name, err := revisionBuild(w.Client, configuration)
+		if name != "" || err != nil {
+			return name, err
+		}
+
+		if w.Timeout > 0 && waited >= w.Timeout {
+			return "", ErrTimeout
+		}
+
+		if w.Sleep == nil {
+			time.Sleep(poll)
+		} else {
+			w.Sleep(poll)
+		}
+	}
+}
+
+// revisionBuild returns the name of the Build of the Revision created for the
+// latest generation of a Configuration, or "" if there isn't one yet
+func revisionBuild(c kube.Client, configuration string) (string, error) {
+	var config serving.Configuration
+	if err := c.Get(kube.Configurations, configuration, &config); err != nil {
+		// a Service's Configuration is created after the Service
+		if kube.IsNotFound(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	if config.Status.ObservedGeneration < config.Spec.Generation || config.Status.LatestCreatedRevisionName == "" {
+		return "", nil
+	}
+
+	var r serving.Revision
+	if err := c.Get(kube.Revisions, config.Status.LatestCreatedRevisionName, &r); err != nil {
+		if kube.IsNotFound(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	if r.Spec.BuildName == "" {
+		return "", fmt.Errorf("revision %s of %s has no build", r.Name, configuration)
+	}
+
+	return r.Spec.BuildName, nil
+}
+
+// Completed returns the Build's Succeeded condition if it is True or False, or
+// nil if the Build is still running
+func Completed(b *build.Build) *build.BuildCondition {
+	c := b.Status.GetCondition(build.BuildSucceeded)
+	if c == nil || c.Status == corev1.ConditionUnknown {
+		return nil
+	}
+
+	return c
+}
+
+// StepPrefix is the prefix knative build gives the init container of each step
+const StepPrefix = "build-step-"
+
+// Steps returns the state of each step of a Build. Steps are named after the
+// init containers of the Build's pod if it still exists, or numbered if not
+func Steps(c kube.Client, b *build.Build) []Step {
+	var names []string
+	if b.Status.Cluster != nil && b.Status.Cluster.PodName != "" {
+		var pod corev1.Pod
+		if err := c.Get(kube.Pods, b.Status.Cluster.PodName, &pod); err == nil {
+			for _, c := range pod.Spec.InitContainers {
+				names = append(names, strings.TrimPrefix(c.Name, StepPrefix))
+			}
+		}
+	}
+
+	var steps []Step
+	for i, state := range b.Status.StepStates {
+		name := "step-" + strconv.Itoa(i)
+		if i < len(names) {
+			name = names[i]
+		}
+
+		steps = append(steps, Step{Name: name, State: state})
+	}
+
+	return steps
+}
+
+// FailedStep returns the first step which terminated with a non-zero exit code, or nil
+func (r *Result) FailedStep() *Step {
+	for i, s := range r.Steps {
+		if s.State.Terminated != nil && s.State.Terminated.ExitCode != 0 {
+			return &r.Steps[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package builds_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/julz/knightrider/pkg/builds"
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWaitForFailedBuild(t *testing.T) {
+	c := kube.NewFake(failedBuild(), buildPod())
+
+	w := &builds.Waiter{Client: c, Sleep: func(time.Duration) {}}
+	result, err := w.Wait("my-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, result.Succeeded, false, "expected build succeeded to be '%v' but was '%v'")
+
+	failed := result.FailedStep()
+	if failed == nil {
+		t.Fatal("expected a failed step")
+	}
+
+	errorIfNotEqual(t, failed.Name, "compile", "expected failed step to be '%s' but was '%s'")
+	errorIfNotEqual(t, failed.State.Terminated.Message, "compilation failed", "expected termination message to be '%s' but was '%s'")
+}
+
+func TestWaitTimesOut(t *testing.T) {
+	b := knative.NewBuild("my-build")
+	b.Status.Conditions = []build.BuildCondition{{Type: build.BuildSucceeded, Status: corev1.ConditionUnknown}}
+
+	slept := time.Duration(0)
+	w := &builds.Waiter{Client: kube.NewFake(b), Timeout: time.Minute, PollInterval: 10 * time.Second, Sleep: func(d time.Duration) { slept += d }}
+	if _, err := w.Wait("my-build"); err != builds.ErrTimeout {
+		t.Fatalf("expected a timeout but got %v", err)
+	}
+
+	errorIfNotEqual(t, slept, time.Minute, "expected to wait for '%s' but waited '%s'")
+}
+
+func TestWaitCancelsBuildAfterBuildTimeout(t *testing.T) {
+	b := knative.NewBuild("my-build")
+	b.Status.Cluster = &build.ClusterSpec{PodName: "my-build-pod"}
+	b.Status.StartTime = metav1.NewTime(time.Now().Add(-time.Hour))
+	b.Status.Conditions = []build.BuildCondition{{Type: build.BuildSucceeded, Status: corev1.ConditionUnknown}}
+
+	c := kube.NewFake(b, buildPod())
+	w := &builds.Waiter{Client: c, BuildTimeout: 20 * time.Minute, Sleep: func(time.Duration) {}}
+	if _, err := w.Wait("my-build"); err != builds.ErrBuildTimeout {
+		t.Fatalf("expected the build to time out but got %v", err)
+	}
+
+	errorIfNotEqual(t, c.Deleted, []string{"pods/my-build-pod"}, "expected '%v' to be deleted but '%v' were")
+
+	// builds which haven't started are timed from when waiting started
+	b.Status.StartTime = metav1.Time{}
+	c = kube.NewFake(b, buildPod())
+
+	slept := time.Duration(0)
+	w = &builds.Waiter{Client: c, BuildTimeout: time.Minute, PollInterval: 10 * time.Second, Sleep: func(d time.Duration) { slept += d }}
+	if _, err := w.Wait("my-build"); err != builds.ErrBuildTimeout {
+		t.Fatalf("expected the build to time out but got %v", err)
+	}
+
+	errorIfNotEqual(t, slept, time.Minute, "expected to wait for '%s' before cancelling but waited '%s'")
+}
+
+func TestRevisionBuild(t *testing.T) {
+	config := knative.NewConfiguration("svc")
+	config.Spec.Generation = 2
+	config.Status.ObservedGeneration = 1
+	config.Status.LatestCreatedRevisionName = "svc-00001"
+
+	revision := &serving.Revision{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "serving.knative.dev/v1alpha1", Kind: "Revision"},
+		ObjectMeta: metav1.ObjectMeta{Name: "svc-00002"},
+		Spec:       serving.RevisionSpec{BuildName: "svc-00002-build"},
+	}
+
+	// the revision for the latest generation hasn't been created yet
+	w := &builds.Waiter{Client: kube.NewFake(config, revision), Timeout: time.Minute, PollInterval: 10 * time.Second, Sleep: func(time.Duration) {}}
+	if _, err := w.RevisionBuild("svc"); err != builds.ErrTimeout {
+		t.Fatalf("expected a timeout but got %v", err)
+	}
+
+	config.Status.ObservedGeneration = 2
+	config.Status.LatestCreatedRevisionName = "svc-00002"
+
+	w.Client = kube.NewFake(config, revision)
+	name, err := w.RevisionBuild("svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, name, "svc-00002-build", "expected the build to be '%s' but was '%s'")
+
+	revision.Spec.BuildName = ""
+	w.Client = kube.NewFake(config, revision)
+	if _, err := w.RevisionBuild("svc"); err == nil {
+		t.Error("expected an error when the revision has no build")
+	}
+}
+
+func TestStepsWithoutPod(t *testing.T) {
+	b := failedBuild()
+	steps := builds.Steps(kube.NewFake(), b)
+
+	errorIfNotEqual(t, []string{steps[0].Name, steps[1].Name, steps[2].Name}, []string{"step-0", "step-1", "step-2"}, "expected step names to be '%s' but were '%s'")
+}
+
+func failedBuild() *build.Build {
+	b := knative.NewBuild("my-build")
+	b.Status.Cluster = &build.ClusterSpec{PodName: "my-build-pod"}
+	b.Status.Conditions = []build.BuildCondition{{Type: build.BuildSucceeded, Status: corev1.ConditionFalse, Reason: "BuildFailed"}}
+	b.Status.StepStates = []corev1.ContainerState{
+		{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}},
+		{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Message: "compilation failed"}},
+		{Waiting: &corev1.ContainerStateWaiting{}},
+	}
+
+	return b
+}
+
+func buildPod() *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-build-pod"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "build-step-git-source"}, {Name: "build-step-compile"}, {Name: "build-step-push"}},
+		},
+	}
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
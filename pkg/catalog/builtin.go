@@ -0,0 +1,55 @@
+package catalog
+
+import (
+	"github.com/julz/knightrider/pkg/knative"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// builtins returns the build templates built in to kr. Bump a template's
+// version whenever it changes, so installed copies can be told apart
+func builtins() []*build.BuildTemplate {
+	kaniko := template("kaniko", "v1", "build and push a Dockerfile with kaniko, without needing a docker daemon",
+		knative.WithParameter("IMAGE", "the image to push, e.g. docker.io/me/app"),
+		knative.WithParameterDefault("DOCKERFILE", "path to the Dockerfile", "/workspace/Dockerfile"),
+		knative.WithTemplateStep("build-and-push", knative.KanikoImage, "--dockerfile=${DOCKERFILE}", "--destination=${IMAGE}", "--context=/workspace"),
+	)
+
+	// the credentials knative build sets up are in $HOME/.docker, but kaniko
+	// looks in /kaniko/.docker by default
+	kaniko.Spec.Steps[0].Env = []corev1.EnvVar{{Name: "DOCKER_CONFIG", Value: "/builder/home/.docker"}}
+
+	buildpack := template("buildpack", "v1", "build and push an app with cloud native buildpacks, no Dockerfile needed",
+		knative.WithParameter("IMAGE", "the image to push, e.g. docker.io/me/app"),
+		knative.WithParameterDefault("DIRECTORY", "directory of the app, relative to the root of the source", "."),
+		knative.WithParameterDefault("BUILDER_IMAGE", "buildpacks builder image to build with", "cloudfoundry/cnb:bionic"),
+		knative.WithTemplateStep("build-and-push", "${BUILDER_IMAGE}", "-app=/workspace/${DIRECTORY}", "${IMAGE}"),
+	)
+
+	buildpack.Spec.Steps[0].Command = []string{"/cnb/lifecycle/creator"}
+
+	jibMaven := template("jib-maven", "v1", "build and push a java app with maven and jib, no Dockerfile needed",
+		knative.WithParameter("IMAGE", "the image to push, e.g. docker.io/me/app"),
+		knative.WithParameterDefault("DIRECTORY", "directory of the pom.xml, relative to the root of the source", "."),
+		knative.WithTemplateStep("build-and-push", "gcr.io/cloud-builders/mvn", "compile", "com.google.cloud.tools:jib-maven-plugin:build", "-Dimage=${IMAGE}"),
+	)
+
+	jibMaven.Spec.Steps[0].WorkingDir = "/workspace/${DIRECTORY}"
+
+	jibGradle := template("jib-gradle", "v1", "build and push a java app with gradle and jib (the build must apply the jib plugin)",
+		knative.WithParameter("IMAGE", "the image to push, e.g. docker.io/me/app"),
+		knative.WithParameterDefault("DIRECTORY", "directory of the build.gradle, relative to the root of the source", "."),
+		knative.WithTemplateStep("build-and-push", "gcr.io/cloud-builders/gradle", "jib", "--image=${IMAGE}"),
+	)
+
+	jibGradle.Spec.Steps[0].WorkingDir = "/workspace/${DIRECTORY}"
+
+	return []*build.BuildTemplate{kaniko, buildpack, jibMaven, jibGradle}
+}
+
+func template(name, version, description string, options ...knative.BuildTemplateOption) *build.BuildTemplate {
+	t := knative.NewBuildTemplate(name, options...)
+	knative.Annotate(&t.ObjectMeta, VersionAnnotation, version)
+	knative.Annotate(&t.ObjectMeta, DescriptionAnnotation, description)
+	return t
+}
@@ -0,0 +1,115 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/julz/knightrider/pkg/knative"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+)
+
+// Annotations recording where a build template in the catalog came from
+const (
+	VersionAnnotation     = knative.AnnotationPrefix + "catalog-version"
+	DescriptionAnnotation = knative.AnnotationPrefix + "catalog-description"
+)
+
+// Builtin is the Source of templates which are built in to kr
+const Builtin = "builtin"
+
+// Entry is a build template in the catalog
+type Entry struct {
+	Name        string
+	Version     string
+	Description string
+
+	// Source is Builtin, or the file the template was read from
+	Source string
+
+	Template *build.BuildTemplate
+}
+
+// Catalog is a set of build templates which can be installed by name
+type Catalog struct {
+	entries map[string]Entry
+}
+
+// Load returns a Catalog of the builtin templates, plus the BuildTemplates in
+// the yml and json files in dir (if dir isn't empty and exists). Templates in
+// dir replace builtin templates with the same name
+func Load(dir string) (*Catalog, error) {
+	c := &Catalog{entries: make(map[string]Entry)}
+	for _, t := range builtins() {
+		c.add(t, Builtin)
+	}
+
+	if dir == "" {
+		return c, nil
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return c, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+
+		objects, err := knative.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %s", file, err)
+		}
+
+		for _, o := range objects {
+			if t, ok := o.(*build.BuildTemplate); ok {
+				c.add(t, file)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func (c *Catalog) add(t *build.BuildTemplate, source string) {
+	c.entries[t.Name] = Entry{
+		Name:        t.Name,
+		Version:     t.Annotations[VersionAnnotation],
+		Description: t.Annotations[DescriptionAnnotation],
+		Source:      source,
+		Template:    t,
+	}
+}
+
+// List returns the entries in the catalog, sorted by name
+func (c *Catalog) List() []Entry {
+	var entries []Entry
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// Get returns the named entry
+func (c *Catalog) Get(name string) (Entry, bool) {
+	e, ok := c.entries[name]
+	return e, ok
+}
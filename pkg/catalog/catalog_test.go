@@ -0,0 +1,112 @@
+package catalog_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/catalog"
+)
+
+func TestBuiltin(t *testing.T) {
+	c, err := catalog.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, e := range c.List() {
+		names = append(names, e.Name)
+
+		errorIfNotEqual(t, e.Source, catalog.Builtin, e.Name+": expected source to be '%s' but was '%s'")
+		if e.Version == "" || e.Description == "" {
+			t.Errorf("%s: expected a version and description", e.Name)
+		}
+	}
+
+	errorIfNotEqual(t, names, []string{"buildpack", "jib-gradle", "jib-maven", "kaniko"}, "expected templates to be '%v' but were '%v'")
+}
+
+var paramRef = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// every ${PARAM} a builtin template uses should be one of its parameters
+func TestBuiltinParameters(t *testing.T) {
+	c, err := catalog.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range c.List() {
+		params := make(map[string]bool)
+		for _, p := range e.Template.Spec.Parameters {
+			params[p.Name] = true
+		}
+
+		for _, step := range e.Template.Spec.Steps {
+			fields := append([]string{step.Image, step.WorkingDir}, append(step.Command, step.Args...)...)
+			for _, m := range paramRef.FindAllStringSubmatch(strings.Join(fields, " "), -1) {
+				if !params[m[1]] {
+					t.Errorf("%s: step %s refers to undeclared parameter %s", e.Name, step.Name, m[1])
+				}
+			}
+		}
+	}
+}
+
+func TestUserCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "templates.yaml"), []byte(`apiVersion: build.knative.dev/v1alpha1
+kind: BuildTemplate
+metadata:
+  name: kaniko
+  annotations:
+    knightrider.julz.github.io/catalog-version: our-v2
+spec:
+  steps:
+  - image: our.registry/kaniko
+---
+apiVersion: build.knative.dev/v1alpha1
+kind: BuildTemplate
+metadata:
+  name: bazel
+spec:
+  steps:
+  - image: l.gcr.io/google/bazel
+`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("not a template"), 0644)
+
+	c, err := catalog.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, len(c.List()), 5, "expected %d templates but got %d")
+
+	kaniko, _ := c.Get("kaniko")
+	errorIfNotEqual(t, kaniko.Version, "our-v2", "expected the user's kaniko to replace the builtin one, with version '%s', but version was '%s'")
+	errorIfNotEqual(t, kaniko.Source, filepath.Join(dir, "templates.yaml"), "expected source to be '%s' but was '%s'")
+
+	if _, ok := c.Get("bazel"); !ok {
+		t.Error("expected the user's bazel template to be in the catalog")
+	}
+
+	if _, err := catalog.Load(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("expected a missing catalog directory to be ignored, but got %s", err)
+	}
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
@@ -0,0 +1,508 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/julz/knightrider/pkg/knative"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Compose converts docker-compose files to knative objects
+type Compose struct {
+	// Dir is the directory the compose file's relative paths (env files,
+	// secret files and build contexts) are relative to. Build contexts are
+	// assumed to be relative to the root of the build source too
+	Dir string
+
+	// Registry is where images built from build contexts are pushed, as
+	// Registry/SERVICE, unless the compose service also names an image
+	Registry string
+
+	// Source configures the source of builds, e.g. knative.WithGitSource
+	Source []knative.BuildSpecOption
+
+	// Template is the build template used for builds, it must take IMAGE and
+	// DOCKERFILE arguments like the kaniko template does. Defaults to kaniko
+	Template string
+
+	// ServiceAccount, if set, is the service account builds run as
+	ServiceAccount string
+}
+
+// reasons compose keys are dropped, where they differ from the generic ones
+var composeReasons = map[string]string{
+	"cap_add":        "knative does not allow changing container capabilities",
+	"cap_drop":       "knative does not allow changing container capabilities",
+	"configs":        "knative revisions cannot mount volumes, use env or a config map instead",
+	"container_name": "knative names revisions itself",
+	"depends_on":     "knative services start independently, and reach each other by url",
+	"deploy":         "knative scales revisions automatically",
+	"expose":         "knative only sends requests to a single port",
+	"healthcheck":    "use a readiness probe instead, e.g. by editing the generated service",
+	"links":          "knative services reach each other by url",
+	"networks":       "knative decides how revisions are networked",
+	"restart":        "knative restarts revisions itself",
+	"volumes":        reasons["volumes"],
+}
+
+func composeReason(key string) string {
+	if r, ok := composeReasons[key]; ok {
+		return r
+	}
+
+	return reason(key)
+}
+
+// Convert reads a compose file and returns a Secret for each of its file
+// secrets followed by a runLatest Knative Service for each of its services.
+// Services with a build context get a build using Template. Every key which
+// couldn't be converted is reported as a Warning
+func (c Compose) Convert(r io.Reader) ([]interface{}, []Warning, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var file map[string]interface{}
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return nil, nil, fmt.Errorf("could not read compose file: %s", err)
+	}
+
+	top := &conversion{object: "compose"}
+	for _, key := range sortedKeys(file) {
+		if key != "version" && key != "services" && key != "secrets" {
+			top.warn(key, "%s", composeReason(key))
+		}
+	}
+
+	var objects []interface{}
+	secrets := make(map[string]string)
+	for _, name := range sortedKeys(asMap(file["secrets"])) {
+		s, err := c.secret(top, name, asMap(asMap(file["secrets"])[name]))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if s != nil {
+			objects = append(objects, s)
+		}
+
+		secrets[name] = kubeName(name)
+	}
+
+	warnings := top.warnings
+	services := asMap(file["services"])
+	for _, name := range sortedKeys(services) {
+		conv := &conversion{object: "service/" + name}
+		s, err := c.service(conv, name, asMap(services[name]), secrets)
+		if err != nil {
+			return nil, nil, fmt.Errorf("service %s: %s", name, err)
+		}
+
+		objects = append(objects, s)
+		warnings = append(warnings, conv.warnings...)
+	}
+
+	return objects, warnings, nil
+}
+
+func (c Compose) secret(conv *conversion, name string, spec map[string]interface{}) (*corev1.Secret, error) {
+	if external := spec["external"]; external != nil && external != false {
+		conv.warn("secrets."+name, "external secrets are not converted, create a secret named %s", kubeName(name))
+		return nil, nil
+	}
+
+	file, ok := spec["file"].(string)
+	if !ok {
+		conv.warn("secrets."+name, "only secrets from files are converted")
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(c.path(file))
+	if err != nil {
+		return nil, fmt.Errorf("could not read secret %s: %s", name, err)
+	}
+
+	s := knative.NewSecret(kubeName(name))
+	s.Data[name] = data
+	return &s, nil
+}
+
+func (c Compose) service(conv *conversion, name string, spec map[string]interface{}, secrets map[string]string) (*serving.Service, error) {
+	handled := []string{"image", "build", "command", "entrypoint", "environment", "env_file", "ports", "secrets"}
+	for _, key := range sortedKeys(spec) {
+		if !contains(handled, key) {
+			conv.warn(key, "%s", composeReason(key))
+		}
+	}
+
+	image, _ := spec["image"].(string)
+	var build []knative.BuildSpecOption
+	if context, ok := spec["build"]; ok {
+		if image == "" {
+			if c.Registry == "" {
+				return nil, fmt.Errorf("a registry to push the built image to is needed")
+			}
+
+			image = strings.TrimSuffix(c.Registry, "/") + "/" + kubeName(name)
+		}
+
+		var err error
+		if build, err = c.build(conv, image, context); err != nil {
+			return nil, err
+		}
+	}
+
+	if image == "" {
+		return nil, fmt.Errorf("either an image or a build is needed")
+	}
+
+	args, err := commandLine(spec["command"])
+	if err != nil {
+		return nil, fmt.Errorf("could not read command: %s", err)
+	}
+
+	options := []knative.ConfigurationOption{
+		knative.WithRevisionTemplate(image, args, nil),
+	}
+
+	if build != nil {
+		options = append(options, knative.WithBuild(build...))
+	}
+
+	if spec["entrypoint"] != nil {
+		entrypoint, err := commandLine(spec["entrypoint"])
+		if err != nil {
+			return nil, fmt.Errorf("could not read entrypoint: %s", err)
+		}
+
+		options = append(options, knative.WithCommand(entrypoint...))
+	}
+
+	// compose gives environment precedence over env_file, and later env files over earlier ones
+	for _, file := range stringOrList(spec["env_file"]) {
+		env, err := readEnvFile(c.path(file))
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, envOptions(conv, "env_file", env)...)
+	}
+
+	options = append(options, envOptions(conv, "environment", environment(spec["environment"]))...)
+	options = append(options, c.secretEnv(conv, spec["secrets"], secrets)...)
+
+	c.ports(conv, spec["ports"])
+
+	return knative.NewRunLatestService(kubeName(name), options...), nil
+}
+
+// build returns the options for a build of a compose build context
+func (c Compose) build(conv *conversion, image string, spec interface{}) ([]knative.BuildSpecOption, error) {
+	if c.Source == nil {
+		return nil, fmt.Errorf("a git source is needed to build it")
+	}
+
+	context, dockerfile := "", "Dockerfile"
+	switch spec := spec.(type) {
+	case string:
+		context = spec
+	case map[string]interface{}:
+		context, _ = spec["context"].(string)
+		if d, ok := spec["dockerfile"].(string); ok {
+			dockerfile = d
+		}
+
+		for _, key := range sortedKeys(spec) {
+			if key != "context" && key != "dockerfile" {
+				conv.warn("build."+key, "the %s build template does not support it", c.template())
+			}
+		}
+	}
+
+	context = path.Clean(filepath.ToSlash(context))
+	if path.IsAbs(context) || strings.HasPrefix(context, "../") || context == ".." || strings.Contains(context, "://") {
+		return nil, fmt.Errorf("build context %s is not inside the build source", context)
+	}
+
+	if context != "." {
+		conv.warn("build.context", "the %s build template builds with the root of the source as its context, so paths in %s must be relative to the root rather than %s", c.template(), path.Join(context, dockerfile), context)
+	}
+
+	options := append([]knative.BuildSpecOption{}, c.Source...)
+	options = append(options, knative.WithBuildTemplate(c.template(), map[string]string{
+		"IMAGE":      image,
+		"DOCKERFILE": path.Join("/workspace", context, dockerfile),
+	}, nil))
+
+	if c.ServiceAccount != "" {
+		options = append(options, knative.WithServiceAccount(c.ServiceAccount))
+	}
+
+	return options, nil
+}
+
+// secretEnv exposes the secrets of a compose service as environment variables,
+// since knative can't mount them as files
+func (c Compose) secretEnv(conv *conversion, spec interface{}, secrets map[string]string) []knative.ConfigurationOption {
+	list, _ := spec.([]interface{})
+
+	var options []knative.ConfigurationOption
+	for i, s := range list {
+		source, target := "", ""
+		switch s := s.(type) {
+		case string:
+			source, target = s, s
+		case map[string]interface{}:
+			source, _ = s["source"].(string)
+			target, _ = s["target"].(string)
+			if target == "" {
+				target = source
+			}
+		}
+
+		secret, ok := secrets[source]
+		if !ok {
+			conv.warn(fmt.Sprintf("secrets[%d]", i), "secret %s is not defined at the top level of the compose file", source)
+			continue
+		}
+
+		env := envName(path.Base(target))
+		conv.warn(fmt.Sprintf("secrets[%d]", i), "knative revisions cannot mount volumes, so secret %s is in $%s instead of /run/secrets/%s", source, env, target)
+		options = append(options, knative.WithEnvVar(corev1.EnvVar{
+			Name: env,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+					Key:                  source,
+				},
+			},
+		}))
+	}
+
+	return options
+}
+
+// ports warns if the compose service listens on more than one port, or on a
+// port other than the one knative sends requests to
+func (c Compose) ports(conv *conversion, spec interface{}) {
+	list, _ := spec.([]interface{})
+	for i, p := range list {
+		var target string
+		switch p := p.(type) {
+		case map[string]interface{}:
+			target = fmt.Sprint(p["target"])
+		default:
+			parts := strings.Split(fmt.Sprint(p), ":")
+			target = strings.SplitN(parts[len(parts)-1], "/", 2)[0]
+		}
+
+		field := fmt.Sprintf("ports[%d]", i)
+		if i > 0 {
+			conv.warn(field, "knative only sends requests to a single port")
+		} else if port, err := strconv.Atoi(target); err != nil || port != ServingPort {
+			conv.warn(field, "knative sends requests to port %d, the app must listen on $PORT instead of %s", ServingPort, target)
+		}
+	}
+}
+
+func (c Compose) template() string {
+	if c.Template == "" {
+		return "kaniko"
+	}
+
+	return c.Template
+}
+
+func (c Compose) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+
+	return filepath.Join(c.Dir, p)
+}
+
+// envVar is an environment variable which may not have a value
+type envVar struct {
+	name  string
+	value *string
+}
+
+func envOptions(conv *conversion, field string, env []envVar) []knative.ConfigurationOption {
+	var options []knative.ConfigurationOption
+	for _, e := range env {
+		if e.value == nil {
+			conv.warn(field+"."+e.name, "compose takes the value from the shell, set it with 'kr edit --env %s=VALUE'", e.name)
+			continue
+		}
+
+		options = append(options, knative.WithEnv(e.name, *e.value))
+	}
+
+	return options
+}
+
+// environment reads an environment, which may be a map or a list of NAME=VALUE
+func environment(spec interface{}) []envVar {
+	var env []envVar
+	switch spec := spec.(type) {
+	case map[string]interface{}:
+		for _, name := range sortedKeys(spec) {
+			var value *string
+			if spec[name] != nil {
+				v := fmt.Sprint(spec[name])
+				value = &v
+			}
+
+			env = append(env, envVar{name: name, value: value})
+		}
+	case []interface{}:
+		for _, e := range spec {
+			env = append(env, parseEnv(fmt.Sprint(e)))
+		}
+	}
+
+	return env
+}
+
+func readEnvFile(path string) ([]envVar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read env file: %s", err)
+	}
+
+	defer f.Close()
+
+	var env []envVar
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			env = append(env, parseEnv(line))
+		}
+	}
+
+	return env, scanner.Err()
+}
+
+func parseEnv(s string) envVar {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) == 1 {
+		return envVar{name: parts[0]}
+	}
+
+	return envVar{name: parts[0], value: &parts[1]}
+}
+
+// commandLine reads a command, which may be a list or a string to be split
+// like a shell would
+func commandLine(spec interface{}) ([]string, error) {
+	if s, ok := spec.(string); ok {
+		return splitWords(s)
+	}
+
+	return stringOrList(spec), nil
+}
+
+// splitWords splits s at spaces which aren't quoted or escaped
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word bytes.Buffer
+	var quote rune
+	inWord, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+func stringOrList(spec interface{}) []string {
+	switch spec := spec.(type) {
+	case string:
+		return []string{spec}
+	case []interface{}:
+		var list []string
+		for _, s := range spec {
+			list = append(list, fmt.Sprint(s))
+		}
+
+		return list
+	}
+
+	return nil
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// kubeName turns a compose name in to a valid kubernetes name
+func kubeName(name string) string {
+	return strings.Replace(strings.ToLower(name), "_", "-", -1)
+}
+
+// envName turns a file name in to an environment variable name
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+
+		return '_'
+	}, name)
+}
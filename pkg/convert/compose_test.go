@@ -0,0 +1,160 @@
+package convert_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/convert"
+	"github.com/julz/knightrider/pkg/knative"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const composeFile = `version: "3.7"
+services:
+  api:
+    build:
+      context: ./api
+      dockerfile: Dockerfile.prod
+    entrypoint: /api
+    command: --listen ":8080" --name 'my api'
+    env_file: api.env
+    environment:
+      LOG_LEVEL: debug
+      WORKERS: 4
+      FROM_SHELL:
+    ports:
+    - "80:8080"
+    - "9000:9000"
+    secrets:
+    - db_password
+    depends_on:
+    - db
+  db:
+    image: postgres:11
+    environment:
+    - POSTGRES_DB=shop
+    ports:
+    - target: 5432
+      published: 5432
+    volumes:
+    - data:/var/lib/postgresql/data
+volumes:
+  data: {}
+secrets:
+  db_password:
+    file: ./secrets/db_password.txt
+  api_key:
+    external: true
+`
+
+func TestCompose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kr-compose")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	os.Mkdir(filepath.Join(dir, "secrets"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "secrets", "db_password.txt"), []byte("hunter2"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "api.env"), []byte("# api settings\nLOG_LEVEL=info\nCACHE=redis://cache\n"), 0644)
+
+	objects, warnings, err := convert.Compose{
+		Dir:      dir,
+		Registry: "docker.io/shop/",
+		Source:   []knative.BuildSpecOption{knative.WithGitSource("https://github.com/shop/shop", "master")},
+	}.Convert(strings.NewReader(composeFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(objects) != 3 {
+		t.Fatalf("expected a secret and two services but got %d objects", len(objects))
+	}
+
+	secret := objects[0].(*corev1.Secret)
+	errorIfNotEqual(t, secret.Name, "db-password", "expected secret to be named '%s' but was '%s'")
+	errorIfNotEqual(t, string(secret.Data["db_password"]), "hunter2", "expected secret data to be '%s' but was '%s'")
+
+	api := objects[1].(*serving.Service)
+	errorIfNotEqual(t, api.Name, "api", "expected service to be named '%s' but was '%s'")
+
+	config := api.Spec.RunLatest.Configuration
+	container := config.RevisionTemplate.Spec.Container
+	errorIfNotEqual(t, container.Image, "docker.io/shop/api", "expected image to be '%s' but was '%s'")
+	errorIfNotEqual(t, container.Command, []string{"/api"}, "expected command to be '%s' but was '%s'")
+	errorIfNotEqual(t, container.Args, []string{"--listen", ":8080", "--name", "my api"}, "expected args to be '%q' but was '%q'")
+	errorIfNotEqual(t, container.Env, []corev1.EnvVar{
+		{Name: "LOG_LEVEL", Value: "debug"},
+		{Name: "CACHE", Value: "redis://cache"},
+		{Name: "WORKERS", Value: "4"},
+		{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "db-password"},
+			Key:                  "db_password",
+		}}},
+	}, "expected env to be '%v' but was '%v'")
+
+	errorIfNotEqual(t, config.Build.Source.Git.Url, "https://github.com/shop/shop", "expected build source to be '%s' but was '%s'")
+	errorIfNotEqual(t, config.Build.Template.Name, "kaniko", "expected build template to be '%s' but was '%s'")
+	errorIfNotEqual(t, args(config.Build.Template.Arguments), map[string]string{
+		"IMAGE":      "docker.io/shop/api",
+		"DOCKERFILE": "/workspace/api/Dockerfile.prod",
+	}, "expected build template arguments to be '%v' but were '%v'")
+
+	db := objects[2].(*serving.Service)
+	errorIfNotEqual(t, db.Spec.RunLatest.Configuration.RevisionTemplate.Spec.Container.Image, "postgres:11", "expected image to be '%s' but was '%s'")
+	errorIfNotEqual(t, db.Spec.RunLatest.Configuration.Build, (*build.BuildSpec)(nil), "expected no build (%v) but got %v")
+
+	var fields []string
+	for _, w := range warnings {
+		fields = append(fields, w.Object+" "+w.Field)
+	}
+
+	errorIfNotEqual(t, fields, []string{
+		"compose volumes",
+		"compose secrets.api_key",
+		"service/api depends_on",
+		"service/api build.context",
+		"service/api environment.FROM_SHELL",
+		"service/api secrets[0]",
+		"service/api ports[1]",
+		"service/db volumes",
+		"service/db ports[0]",
+	}, "expected warnings %q but got %q")
+}
+
+func TestComposeBuildNeedsSourceAndRegistry(t *testing.T) {
+	_, _, err := convert.Compose{Registry: "docker.io/shop"}.Convert(strings.NewReader("services: {api: {build: .}}"))
+	if err == nil {
+		t.Error("expected an error converting a build without a source")
+	}
+
+	_, _, err = convert.Compose{
+		Source: []knative.BuildSpecOption{knative.WithGitSource("https://github.com/shop/shop", "master")},
+	}.Convert(strings.NewReader("services: {api: {build: .}}"))
+	if err == nil {
+		t.Error("expected an error converting a build without a registry or image")
+	}
+
+	_, _, err = convert.Compose{
+		Registry: "docker.io/shop",
+		Source:   []knative.BuildSpecOption{knative.WithGitSource("https://github.com/shop/shop", "master")},
+	}.Convert(strings.NewReader("services: {api: {build: ../elsewhere}}"))
+	if err == nil {
+		t.Error("expected an error converting a build context outside the source")
+	}
+}
+
+func args(arguments []build.ArgumentSpec) map[string]string {
+	m := make(map[string]string)
+	for _, a := range arguments {
+		m[a.Name] = a.Value
+	}
+
+	return m
+}
@@ -0,0 +1,135 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Warning describes something which couldn't be carried over by a conversion
+type Warning struct {
+	// Object is the object being converted, e.g. deployment/foo
+	Object string
+
+	// Field is the path of the field which was dropped or changed
+	Field string
+
+	Reason string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s: %s", w.Object, w.Field, w.Reason)
+}
+
+// reasons explains why fields commonly found in kubernetes objects are dropped
+var reasons = map[string]string{
+	"affinity":                "knative decides where revisions are scheduled",
+	"externalIPs":             "knative services are exposed through the knative ingress",
+	"externalTrafficPolicy":   "knative services are exposed through the knative ingress",
+	"hostNetwork":             "knative decides how revisions are networked",
+	"initContainers":          "knative revisions have a single container",
+	"lifecycle":               "knative does not allow lifecycle hooks",
+	"loadBalancerIP":          "knative services are exposed through the knative ingress",
+	"minReadySeconds":         "knative rolls out new revisions itself",
+	"nodeName":                "knative decides where revisions are scheduled",
+	"nodeSelector":            "knative decides where revisions are scheduled",
+	"paused":                  "knative rolls out new revisions itself",
+	"progressDeadlineSeconds": "knative rolls out new revisions itself",
+	"replicas":                "knative scales revisions automatically",
+	"resources":               "knative serving v1alpha1 does not allow container resources",
+	"revisionHistoryLimit":    "knative rolls out new revisions itself",
+	"strategy":                "knative rolls out new revisions itself",
+	"tolerations":             "knative decides where revisions are scheduled",
+	"type":                    "knative services are exposed through the knative ingress",
+	"volumeMounts":            "knative revisions cannot mount volumes",
+	"volumes":                 "knative revisions cannot mount volumes",
+}
+
+func reason(field string) string {
+	if r, ok := reasons[field]; ok {
+		return r
+	}
+
+	return "not supported by knative services"
+}
+
+// conversion collects the warnings for converting a single object
+type conversion struct {
+	object   string
+	warnings []Warning
+}
+
+func (c *conversion) warn(field, reason string, args ...interface{}) {
+	c.warnings = append(c.warnings, Warning{Object: c.object, Field: field, Reason: fmt.Sprintf(reason, args...)})
+}
+
+// dropUnconverted warns about each field of v which is set, other than the
+// handled ones and those which are set to their kubernetes default value
+func (c *conversion) dropUnconverted(path string, v interface{}, defaults map[string]interface{}, handled ...string) {
+	for _, field := range unconverted(v, defaults, handled...) {
+		c.warn(path+"."+field, "%s", reason(field))
+	}
+}
+
+// unconverted returns the sorted names of the json fields of v which are set
+// to something other than an empty or default value, and aren't handled
+func unconverted(v interface{}, defaults map[string]interface{}, handled ...string) []string {
+	fields := toMap(v)
+	for _, h := range handled {
+		delete(fields, h)
+	}
+
+	var names []string
+	for name, value := range fields {
+		if empty(value) || reflect.DeepEqual(toJSONValue(defaults[name]), value) {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func toMap(v interface{}) map[string]interface{} {
+	m := make(map[string]interface{})
+	if b, err := json.Marshal(v); err == nil {
+		json.Unmarshal(b, &m)
+	}
+
+	return m
+}
+
+// toJSONValue returns v as it would be after a json round-trip, e.g. with
+// numbers as float64s
+func toJSONValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	var out interface{}
+	if b, err := json.Marshal(v); err == nil {
+		json.Unmarshal(b, &out)
+	}
+
+	return out
+}
+
+func empty(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	}
+
+	return false
+}
@@ -0,0 +1,340 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/julz/knightrider/pkg/knative"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ServingPort is the port knative sends requests to, which it passes to the
+// container as $PORT
+const ServingPort = 8080
+
+// deployment is the part of a Deployment needed to convert it. The apps types
+// aren't vendored, so this works for any of apps/v1, apps/v1beta1,
+// apps/v1beta2 and extensions/v1beta1
+type deployment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec struct {
+		Template corev1.PodTemplateSpec `json:"template"`
+	} `json:"spec"`
+}
+
+// annotations which are set by kubernetes or kubectl and shouldn't be copied
+var managedAnnotations = []string{
+	"deployment.kubernetes.io/revision",
+	"kubectl.kubernetes.io/last-applied-configuration",
+}
+
+// defaults of pod and container fields, which don't need a warning when dropped
+var (
+	podDefaults = map[string]interface{}{
+		"dnsPolicy":                     "ClusterFirst",
+		"restartPolicy":                 "Always",
+		"schedulerName":                 "default-scheduler",
+		"terminationGracePeriodSeconds": 30,
+	}
+
+	containerDefaults = map[string]interface{}{
+		"terminationMessagePath":   "/dev/termination-log",
+		"terminationMessagePolicy": "File",
+	}
+
+	serviceDefaults = map[string]interface{}{
+		"sessionAffinity": "None",
+		"type":            "ClusterIP",
+	}
+)
+
+// Deployments converts each Deployment in objects to a runLatest Knative
+// Service, taking its name and port from the core Service selecting its pods if
+// there is one. Objects other than Deployments and the core Services selecting
+// them are returned unchanged. Every field which couldn't be converted is
+// reported as a Warning
+func Deployments(objects []interface{}) ([]interface{}, []Warning, error) {
+	var services []*corev1.Service
+	for _, o := range objects {
+		if s, ok := o.(*corev1.Service); ok {
+			services = append(services, s)
+		}
+	}
+
+	var converted []interface{}
+	var warnings []Warning
+	consumed := make(map[*corev1.Service]bool)
+	for _, o := range objects {
+		if !isDeployment(o) {
+			converted = append(converted, o)
+			continue
+		}
+
+		var d deployment
+		if err := json.Unmarshal(mustMarshal(o), &d); err != nil {
+			return nil, nil, fmt.Errorf("could not read deployment: %s", err)
+		}
+
+		selecting := selectingServices(services, d.Spec.Template.Labels)
+		for _, s := range selecting {
+			consumed[s] = true
+		}
+
+		c := &conversion{object: "deployment/" + d.Name}
+		if s := c.deployment(d, o.(map[string]interface{}), selecting); s != nil {
+			converted = append(converted, s)
+		}
+
+		warnings = append(warnings, c.warnings...)
+	}
+
+	var result []interface{}
+	for _, o := range converted {
+		if s, ok := o.(*corev1.Service); !ok || !consumed[s] {
+			result = append(result, o)
+		}
+	}
+
+	return result, warnings, nil
+}
+
+func isDeployment(o interface{}) bool {
+	m, ok := o.(map[string]interface{})
+	if !ok || m["kind"] != "Deployment" {
+		return false
+	}
+
+	switch m["apiVersion"] {
+	case "apps/v1", "apps/v1beta1", "apps/v1beta2", "extensions/v1beta1":
+		return true
+	}
+
+	return false
+}
+
+// selectingServices returns the services whose selector matches the labels
+func selectingServices(services []*corev1.Service, labels map[string]string) []*corev1.Service {
+	var selecting []*corev1.Service
+	for _, s := range services {
+		if len(s.Spec.Selector) == 0 {
+			continue
+		}
+
+		matches := true
+		for k, v := range s.Spec.Selector {
+			if labels[k] != v {
+				matches = false
+			}
+		}
+
+		if matches {
+			selecting = append(selecting, s)
+		}
+	}
+
+	return selecting
+}
+
+func (c *conversion) deployment(d deployment, raw map[string]interface{}, selecting []*corev1.Service) *serving.Service {
+	spec, _ := raw["spec"].(map[string]interface{})
+	c.dropUnconverted("spec", spec, nil, "selector", "template")
+
+	pod := d.Spec.Template.Spec
+	c.dropUnconverted("spec.template.spec", pod, podDefaults, "containers", "serviceAccountName", "serviceAccount", "volumes")
+
+	for _, v := range pod.Volumes {
+		c.warn(fmt.Sprintf("spec.template.spec.volumes[%s]", v.Name), "%s volume: %s", volumeType(v), reason("volumes"))
+	}
+
+	if len(pod.Containers) == 0 {
+		c.warn("spec.template.spec.containers", "the deployment has no containers")
+		return nil
+	}
+
+	for _, extra := range pod.Containers[1:] {
+		c.warn(fmt.Sprintf("spec.template.spec.containers[%s]", extra.Name), "knative revisions have a single container, only %s was converted", pod.Containers[0].Name)
+	}
+
+	name := d.Name
+	if len(selecting) == 1 {
+		name = selecting[0].Name
+	}
+
+	servingPort := c.services(selecting)
+	options := c.container(pod.Containers[0], servingPort)
+
+	if pod.ServiceAccountName != "" {
+		options = append(options, knative.WithRevisionServiceAccount(pod.ServiceAccountName))
+	} else if pod.DeprecatedServiceAccount != "" {
+		options = append(options, knative.WithRevisionServiceAccount(pod.DeprecatedServiceAccount))
+	}
+
+	options = append(options, knative.WithRevisionMetadata(d.Spec.Template.Labels, d.Spec.Template.Annotations))
+
+	s := knative.NewRunLatestService(name, options...)
+	s.Namespace = d.Namespace
+	s.Labels = d.Labels
+	for k, v := range d.Annotations {
+		if !contains(managedAnnotations, k) {
+			knative.Annotate(&s.ObjectMeta, k, v)
+		}
+	}
+
+	return s
+}
+
+// services warns about anything in the core Services selecting the deployment
+// which knative can't do, and returns the port they send traffic to, if any
+func (c *conversion) services(selecting []*corev1.Service) intstr.IntOrString {
+	var target intstr.IntOrString
+	for _, s := range selecting {
+		if len(selecting) > 1 {
+			c.warn("service/"+s.Name, "more than one service selects the deployment's pods, the knative service is named after the deployment")
+		}
+
+		c.dropUnconverted("service/"+s.Name+".spec", s.Spec, serviceDefaults, "selector", "ports", "clusterIP")
+		for _, p := range s.Spec.Ports {
+			if p.Port != 80 && p.Port != 443 {
+				c.warn(fmt.Sprintf("service/%s.spec.ports[%d]", s.Name, p.Port), "knative services are served on port 80 (and 443 with tls), not %d", p.Port)
+			}
+
+			if target.IntVal == 0 && target.StrVal == "" {
+				target = p.TargetPort
+				if target.IntVal == 0 && target.StrVal == "" {
+					target = intstr.FromInt(int(p.Port))
+				}
+			}
+		}
+	}
+
+	return target
+}
+
+// container returns the options for configuring a revision like container
+func (c *conversion) container(container corev1.Container, servingPort intstr.IntOrString) []knative.ConfigurationOption {
+	path := fmt.Sprintf("spec.template.spec.containers[%s]", container.Name)
+	c.dropUnconverted(path, container, containerDefaults, "name", "image", "imagePullPolicy", "args", "command", "env", "envFrom", "ports", "readinessProbe", "livenessProbe")
+
+	options := []knative.ConfigurationOption{
+		knative.WithRevisionTemplate(container.Image, container.Args, nil),
+	}
+
+	if len(container.Command) > 0 {
+		options = append(options, knative.WithCommand(container.Command...))
+	}
+
+	for _, e := range container.Env {
+		options = append(options, knative.WithEnvVar(e))
+	}
+
+	if len(container.EnvFrom) > 0 {
+		options = append(options, knative.WithEnvFrom(container.EnvFrom...))
+	}
+
+	switch container.ImagePullPolicy {
+	case corev1.PullAlways:
+		options = append(options, knative.WithImagePullPolicyAlways)
+	case corev1.PullNever:
+		c.warn(path+".imagePullPolicy", "only Always can be set on knative services")
+	}
+
+	port := c.ports(path, container.Ports, servingPort)
+
+	if container.ReadinessProbe != nil {
+		options = append(options, knative.WithReadinessProbe(c.probe(path+".readinessProbe", container.ReadinessProbe, port)))
+	}
+
+	if container.LivenessProbe != nil {
+		options = append(options, knative.WithLivenessProbe(c.probe(path+".livenessProbe", container.LivenessProbe, port)))
+	}
+
+	return options
+}
+
+// ports works out which of the container's ports it serves requests on, and
+// warns about the rest, and if it isn't the port knative will send requests to
+func (c *conversion) ports(path string, ports []corev1.ContainerPort, servingPort intstr.IntOrString) *corev1.ContainerPort {
+	if len(ports) == 0 {
+		return nil
+	}
+
+	served := &ports[0]
+	for i, p := range ports {
+		if matchesPort(servingPort, p) {
+			served = &ports[i]
+		}
+	}
+
+	for _, p := range ports {
+		if p.ContainerPort != served.ContainerPort {
+			c.warn(fmt.Sprintf("%s.ports[%d]", path, p.ContainerPort), "knative only sends requests to a single port")
+		}
+	}
+
+	if served.ContainerPort != ServingPort {
+		c.warn(fmt.Sprintf("%s.ports[%d]", path, served.ContainerPort), "knative sends requests to port %d, the app must listen on $PORT instead", ServingPort)
+	}
+
+	return served
+}
+
+// probe returns a copy of the probe without the port, which knative doesn't
+// allow, warning if the probe was of a port other than the serving one
+func (c *conversion) probe(path string, probe *corev1.Probe, served *corev1.ContainerPort) *corev1.Probe {
+	p := probe.DeepCopy()
+
+	var port *intstr.IntOrString
+	if p.HTTPGet != nil {
+		port = &p.HTTPGet.Port
+	} else if p.TCPSocket != nil {
+		port = &p.TCPSocket.Port
+	}
+
+	if port == nil || (port.IntVal == 0 && port.StrVal == "") {
+		return p
+	}
+
+	if served == nil || !matchesPort(*port, *served) {
+		c.warn(path, "probed port %s, knative probes the port it sends requests to", port.String())
+	}
+
+	*port = intstr.IntOrString{}
+	return p
+}
+
+// matchesPort returns true if port refers to p, by number or name
+func matchesPort(port intstr.IntOrString, p corev1.ContainerPort) bool {
+	if port.Type == intstr.String {
+		return port.StrVal == p.Name
+	}
+
+	return port.IntVal == p.ContainerPort
+}
+
+func volumeType(v corev1.Volume) string {
+	for field := range toMap(v.VolumeSource) {
+		return field
+	}
+
+	return "unknown"
+}
+
+func mustMarshal(o interface{}) []byte {
+	b, _ := json.Marshal(o)
+	return b
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,195 @@
+package convert_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/convert"
+	"github.com/julz/knightrider/pkg/knative"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+  labels:
+    team: checkout
+  annotations:
+    deployment.kubernetes.io/revision: "3"
+spec:
+  replicas: 3
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      serviceAccountName: web-runner
+      nodeSelector:
+        disk: ssd
+      restartPolicy: Always
+      volumes:
+      - name: logs
+        hostPath:
+          path: /var/log
+      containers:
+      - name: web
+        image: docker.io/shop/web:v3
+        command: ["/web"]
+        args: ["--verbose"]
+        env:
+        - name: MODE
+          value: production
+        - name: POD
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.name
+        ports:
+        - name: http
+          containerPort: 3000
+        - containerPort: 9090
+        readinessProbe:
+          httpGet:
+            path: /ready
+            port: http
+        livenessProbe:
+          tcpSocket:
+            port: 9090
+        resources:
+          limits:
+            cpu: 500m
+        volumeMounts:
+        - name: logs
+          mountPath: /logs
+      - name: sidecar
+        image: docker.io/shop/proxy
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web-svc
+  namespace: shop
+spec:
+  type: LoadBalancer
+  selector:
+    app: web
+  ports:
+  - port: 80
+    targetPort: http
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+`
+
+func TestDeployments(t *testing.T) {
+	objects, err := knative.Decode(strings.NewReader(deployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converted, warnings, err := convert.Deployments(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(converted) != 2 {
+		t.Fatalf("expected the knative service and the config map but got %d objects", len(converted))
+	}
+
+	s, ok := converted[0].(*serving.Service)
+	if !ok {
+		t.Fatalf("expected a *serving.Service but got %T", converted[0])
+	}
+
+	if _, ok := converted[1].(*corev1.ConfigMap); !ok {
+		t.Errorf("expected the config map to be passed through but got %T", converted[1])
+	}
+
+	errorIfNotEqual(t, s.Name, "web-svc", "expected service to be named after the core service, '%s', but was '%s'")
+	errorIfNotEqual(t, s.Namespace, "shop", "expected namespace to be '%s' but was '%s'")
+	errorIfNotEqual(t, s.Labels, map[string]string{"team": "checkout"}, "expected labels to be '%v' but was '%v'")
+	errorIfNotEqual(t, len(s.Annotations), 0, "expected %d annotations but got %d")
+
+	revision := s.Spec.RunLatest.Configuration.RevisionTemplate
+	container := revision.Spec.Container
+	errorIfNotEqual(t, container.Image, "docker.io/shop/web:v3", "expected image to be '%s' but was '%s'")
+	errorIfNotEqual(t, container.Command, []string{"/web"}, "expected command to be '%s' but was '%s'")
+	errorIfNotEqual(t, container.Args, []string{"--verbose"}, "expected args to be '%s' but was '%s'")
+	errorIfNotEqual(t, len(container.Env), 2, "expected %d env vars but got %d")
+	errorIfNotEqual(t, container.Env[1].ValueFrom.FieldRef.FieldPath, "metadata.name", "expected env var from field '%s' but was '%s'")
+	errorIfNotEqual(t, container.ReadinessProbe.HTTPGet.Path, "/ready", "expected readiness probe path '%s' but was '%s'")
+	errorIfNotEqual(t, container.ReadinessProbe.HTTPGet.Port.String(), "0", "expected readiness probe port to be removed ('%s') but was '%s'")
+	errorIfNotEqual(t, container.LivenessProbe.TCPSocket.Port.String(), "0", "expected liveness probe port to be removed ('%s') but was '%s'")
+	errorIfNotEqual(t, len(container.Ports), 0, "expected %d ports but got %d")
+	errorIfNotEqual(t, len(container.VolumeMounts), 0, "expected %d volume mounts but got %d")
+	errorIfNotEqual(t, container.Name, "", "expected container name to be '%s' but was '%s'")
+	errorIfNotEqual(t, revision.Spec.ServiceAccountName, "web-runner", "expected service account to be '%s' but was '%s'")
+	errorIfNotEqual(t, revision.Labels, map[string]string{"app": "web"}, "expected revision labels to be '%v' but was '%v'")
+
+	var fields []string
+	for _, w := range warnings {
+		fields = append(fields, w.Field)
+	}
+
+	errorIfNotEqual(t, fields, []string{
+		"spec.replicas",
+		"spec.template.spec.nodeSelector",
+		"spec.template.spec.volumes[logs]",
+		"spec.template.spec.containers[sidecar]",
+		"service/web-svc.spec.type",
+		"spec.template.spec.containers[web].resources",
+		"spec.template.spec.containers[web].volumeMounts",
+		"spec.template.spec.containers[web].ports[9090]",
+		"spec.template.spec.containers[web].ports[3000]",
+		"spec.template.spec.containers[web].livenessProbe",
+	}, "expected warnings for fields %v but got %v")
+
+	for _, w := range warnings {
+		errorIfNotEqual(t, w.Object, "deployment/web", "expected warning to be about '%s' but was about '%s'")
+	}
+}
+
+func TestDeploymentWithoutService(t *testing.T) {
+	objects, err := knative.Decode(strings.NewReader(`{"apiVersion": "extensions/v1beta1", "kind": "Deployment", "metadata": {"name": "worker"},
+		"spec": {"template": {"spec": {"containers": [{"name": "worker", "image": "worker", "ports": [{"containerPort": 8080}]}]}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converted, warnings, err := convert.Deployments(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, converted[0].(*serving.Service).Name, "worker", "expected service to be named after the deployment, '%s', but was '%s'")
+	errorIfNotEqual(t, len(warnings), 0, "expected %d warnings but got %d")
+}
+
+func TestDeploymentWithoutContainers(t *testing.T) {
+	objects, err := knative.Decode(strings.NewReader(`{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "empty"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converted, warnings, err := convert.Deployments(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, len(converted), 0, "expected %d objects but got %d")
+	errorIfNotEqual(t, len(warnings), 1, "expected %d warning but got %d")
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
@@ -0,0 +1,264 @@
+package debug
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/julz/knightrider/pkg/kube"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	knativeserving "github.com/knative/serving/pkg/apis/serving"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Finding is something which may explain why an object isn't ready
+type Finding struct {
+	// Rank orders findings by how likely they are to be the root cause,
+	// higher first. Problems found further down the chain of objects (e.g.
+	// in pods) outrank the conditions they cause further up (e.g. on the service)
+	Rank    int
+	Object  string
+	Problem string
+	Fix     string
+}
+
+// Ranks for the different kinds of finding
+const (
+	RankContainer              = 100
+	RankMissingRef             = 95
+	RankBuild                  = 90
+	RankEvent                  = 70
+	RankRevision               = 60
+	RankUnknown                = 30
+	RankConfigurationOrService = 20
+)
+
+// Service walks the named Service's Configuration, latest Revision, Build and
+// Pods, and returns everything which isn't healthy, most likely cause first
+func Service(c kube.Client, name string) ([]Finding, error) {
+	d := &diagnosis{client: c}
+	if err := d.service(name); err != nil {
+		return nil, err
+	}
+
+	if err := d.events(); err != nil {
+		return nil, err
+	}
+
+	return d.ranked(), nil
+}
+
+type diagnosis struct {
+	client   kube.Client
+	findings []Finding
+	involved map[string]bool
+}
+
+func (d *diagnosis) service(name string) error {
+	d.involve(name)
+
+	var s serving.Service
+	if err := d.client.Get(kube.Services, name, &s); err != nil {
+		return err
+	}
+
+	for _, cond := range s.Status.Conditions {
+		d.condition("service/"+name, string(cond.Type), cond.Status, cond.Reason, cond.Message, RankConfigurationOrService)
+	}
+
+	var config serving.Configuration
+	if err := d.client.Get(kube.Configurations, name, &config); kube.IsNotFound(err) {
+		d.add(RankMissingRef, "service/"+name, "the service has no configuration", "check the knative serving controller is running")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, cond := range config.Status.Conditions {
+		d.condition("configuration/"+name, string(cond.Type), cond.Status, cond.Reason, cond.Message, RankConfigurationOrService)
+	}
+
+	if config.Spec.Build != nil {
+		d.serviceAccount(config.Spec.Build.ServiceAccountName)
+	}
+
+	revision := config.Status.LatestCreatedRevisionName
+	if revision == "" {
+		d.add(RankConfigurationOrService, "configuration/"+name, "no revision has been created yet", "")
+		return nil
+	}
+
+	return d.revision(revision)
+}
+
+func (d *diagnosis) add(rank int, object, problem, fix string) {
+	d.findings = append(d.findings, Finding{Rank: rank, Object: object, Problem: problem, Fix: fix})
+}
+
+// condition adds a finding for a condition which isn't True
+func (d *diagnosis) condition(object, t string, status corev1.ConditionStatus, reason, message string, rank int) {
+	if status == corev1.ConditionTrue {
+		return
+	}
+
+	if status != corev1.ConditionFalse && rank > RankUnknown {
+		rank = RankUnknown
+	}
+
+	d.add(rank, object, fmt.Sprintf("%s is %s: %s %s", t, status, reason, message), fixForReason(reason))
+}
+
+func (d *diagnosis) revision(name string) error {
+	d.involve(name)
+
+	var rev serving.Revision
+	if err := d.client.Get(kube.Revisions, name, &rev); err != nil {
+		return err
+	}
+
+	for _, t := range []serving.RevisionConditionType{
+		serving.RevisionConditionContainerHealthy,
+		serving.RevisionConditionResourcesAvailable,
+		serving.RevisionConditionBuildSucceeded,
+	} {
+		if cond := rev.Status.GetCondition(t); cond != nil {
+			d.condition("revision/"+name, string(cond.Type), cond.Status, cond.Reason, cond.Message, RankRevision)
+		}
+	}
+
+	d.serviceAccount(rev.Spec.ServiceAccountName)
+
+	if rev.Spec.BuildName != "" {
+		if err := d.build(rev.Spec.BuildName); err != nil {
+			return err
+		}
+	}
+
+	var pods corev1.PodList
+	if err := d.client.List(kube.Pods, fmt.Sprintf("%s=%s", knativeserving.RevisionLabelKey, name), &pods); err != nil {
+		return err
+	}
+
+	for _, p := range pods.Items {
+		d.pod(p)
+	}
+
+	return nil
+}
+
+func (d *diagnosis) build(name string) error {
+	d.involve(name)
+
+	var b build.Build
+	if err := d.client.Get(kube.Builds, name, &b); err != nil {
+		if kube.IsNotFound(err) {
+			d.add(RankMissingRef, "build/"+name, "the revision's build does not exist", "")
+			return nil
+		}
+
+		return err
+	}
+
+	if cond := b.Status.GetCondition(build.BuildSucceeded); cond != nil && cond.Status == corev1.ConditionFalse {
+		d.add(RankBuild, "build/"+name, fmt.Sprintf("build failed: %s %s", cond.Reason, cond.Message), fmt.Sprintf("check the build's logs with 'kr logs build %s'", name))
+	}
+
+	d.serviceAccount(b.Spec.ServiceAccountName)
+
+	if b.Spec.Template != nil {
+		if err := d.client.Get(kube.BuildTemplates, b.Spec.Template.Name, &build.BuildTemplate{}); kube.IsNotFound(err) {
+			d.add(RankMissingRef, "build/"+name, fmt.Sprintf("build template %s does not exist", b.Spec.Template.Name), "install the build template in this namespace")
+		}
+	}
+
+	if b.Status.Cluster != nil && b.Status.Cluster.PodName != "" {
+		var p corev1.Pod
+		if err := d.client.Get(kube.Pods, b.Status.Cluster.PodName, &p); err == nil {
+			d.pod(p)
+		}
+	}
+
+	return nil
+}
+
+// serviceAccount checks a service account and the secrets it references exist
+func (d *diagnosis) serviceAccount(name string) {
+	if name == "" || d.involved["serviceaccount/"+name] {
+		return
+	}
+
+	d.involve(name)
+	d.involve("serviceaccount/" + name)
+
+	var sa corev1.ServiceAccount
+	if err := d.client.Get(kube.ServiceAccounts, name, &sa); kube.IsNotFound(err) {
+		d.add(RankMissingRef, "serviceaccount/"+name, "service account does not exist", fmt.Sprintf("create it with 'kr create service-account %s -s SECRET'", name))
+		return
+	} else if err != nil {
+		return
+	}
+
+	for _, s := range sa.Secrets {
+		if err := d.client.Get(kube.Secrets, s.Name, &corev1.Secret{}); kube.IsNotFound(err) {
+			d.add(RankMissingRef, "serviceaccount/"+name, fmt.Sprintf("secret %s referenced by the service account does not exist", s.Name), fmt.Sprintf("create it with 'kr create secret %s -t git:HOST' or 'kr create secret %s -t docker:HOST'", s.Name, s.Name))
+		}
+	}
+}
+
+func (d *diagnosis) pod(p corev1.Pod) {
+	d.involve(p.Name)
+
+	statuses := append(append([]corev1.ContainerStatus{}, p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		if w := s.State.Waiting; w != nil && w.Reason != "" && w.Reason != "PodInitializing" && w.Reason != "ContainerCreating" {
+			d.add(RankContainer, "pod/"+p.Name, fmt.Sprintf("container %s is waiting: %s %s", s.Name, w.Reason, w.Message), fixForReason(w.Reason))
+		}
+
+		if t := s.State.Terminated; t != nil && t.ExitCode != 0 {
+			d.add(RankContainer, "pod/"+p.Name, fmt.Sprintf("container %s exited with code %d: %s %s", s.Name, t.ExitCode, t.Reason, t.Message), "check the container's logs")
+		}
+	}
+}
+
+func (d *diagnosis) involve(name string) {
+	if d.involved == nil {
+		d.involved = make(map[string]bool)
+	}
+
+	d.involved[name] = true
+}
+
+// events adds a finding for each distinct recent warning event about an
+// object the diagnosis looked at
+func (d *diagnosis) events() error {
+	var events corev1.EventList
+	if err := d.client.List(kube.Events, "", &events); err != nil {
+		return err
+	}
+
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return events.Items[j].LastTimestamp.Before(&events.Items[i].LastTimestamp)
+	})
+
+	seen := make(map[string]bool)
+	for _, e := range events.Items {
+		key := e.InvolvedObject.Name + e.Reason + e.Message
+		if e.Type != corev1.EventTypeWarning || !d.involved[e.InvolvedObject.Name] || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		d.add(RankEvent, strings.ToLower(e.InvolvedObject.Kind)+"/"+e.InvolvedObject.Name, fmt.Sprintf("warning event: %s %s", e.Reason, e.Message), fixForReason(e.Reason))
+	}
+
+	return nil
+}
+
+func (d *diagnosis) ranked() []Finding {
+	sort.SliceStable(d.findings, func(i, j int) bool {
+		return d.findings[i].Rank > d.findings[j].Rank
+	})
+
+	return d.findings
+}
@@ -0,0 +1,86 @@
+package debug_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/debug"
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	knativeserving "github.com/knative/serving/pkg/apis/serving"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDebugService(t *testing.T) {
+	s := knative.NewRunLatestService("svc")
+	s.Status.Conditions = []serving.ServiceCondition{{Type: serving.ServiceConditionReady, Status: corev1.ConditionUnknown}}
+
+	config := knative.NewConfiguration("svc")
+	config.Status.LatestCreatedRevisionName = "svc-00001"
+
+	rev := &serving.Revision{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "serving.knative.dev/v1alpha1", Kind: "Revision"},
+		ObjectMeta: metav1.ObjectMeta{Name: "svc-00001"},
+		Spec:       serving.RevisionSpec{ServiceAccountName: "builder"},
+		Status: serving.RevisionStatus{Conditions: []serving.RevisionCondition{
+			{Type: serving.RevisionConditionReady, Status: corev1.ConditionUnknown},
+			{Type: serving.RevisionConditionContainerHealthy, Status: corev1.ConditionUnknown, Reason: "Deploying"},
+			{Type: serving.RevisionConditionResourcesAvailable, Status: corev1.ConditionTrue},
+		}},
+	}
+
+	pod := &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "svc-00001-pod", Labels: map[string]string{knativeserving.RevisionLabelKey: "svc-00001"}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "user-container", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+		}},
+	}
+
+	sa := knative.NewServiceAccount("builder", knative.WithSecrets("missing-secret"))
+
+	events := []interface{}{
+		event("a", "svc-00001-pod", corev1.EventTypeWarning, "Failed", "Failed to pull image"),
+		event("b", "svc-00001-pod", corev1.EventTypeNormal, "Pulling", "pulling image"),
+		event("c", "unrelated-pod", corev1.EventTypeWarning, "Failed", "something else"),
+	}
+
+	findings, err := debug.Service(kube.NewFake(append([]interface{}{s, config, rev, pod, &sa}, events...)...), "svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var summary []string
+	for _, f := range findings {
+		summary = append(summary, f.Object+": "+strings.TrimSpace(f.Problem))
+	}
+
+	expected := []string{
+		"pod/svc-00001-pod: container user-container is waiting: ImagePullBackOff",
+		"serviceaccount/builder: secret missing-secret referenced by the service account does not exist",
+		"pod/svc-00001-pod: warning event: Failed Failed to pull image",
+		"revision/svc-00001: ContainerHealthy is Unknown: Deploying",
+		"service/svc: Ready is Unknown:",
+	}
+
+	if strings.Join(summary, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected findings:\n%s\n\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(summary, "\n"))
+	}
+
+	if !strings.Contains(findings[0].Fix, "image") {
+		t.Errorf("expected a fix for the image pull failure but got %q", findings[0].Fix)
+	}
+}
+
+func event(name, involved, eventType, reason, message string) *corev1.Event {
+	return &corev1.Event{
+		TypeMeta:       metav1.TypeMeta{APIVersion: "v1", Kind: "Event"},
+		ObjectMeta:     metav1.ObjectMeta{Name: name},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: involved},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+	}
+}
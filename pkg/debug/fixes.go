@@ -0,0 +1,23 @@
+package debug
+
+// fixes are suggested fixes for well-known condition, event and container
+// waiting reasons
+var fixes = map[string]string{
+	"ErrImagePull":               "the image could not be pulled: check the image name and tag exist, and for a private registry that the service account has a docker secret ('kr generate secret NAME -t docker:HOST')",
+	"ImagePullBackOff":           "the image could not be pulled: check the image name and tag exist, and for a private registry that the service account has a docker secret ('kr generate secret NAME -t docker:HOST')",
+	"InvalidImageName":           "the image name is not a valid image reference",
+	"ContainerMissing":           "the image could not be found: check the image name and tag exist",
+	"CrashLoopBackOff":           "the container keeps crashing: check its logs with 'kr logs revision NAME'",
+	"CreateContainerConfigError": "a secret or config map the container references is missing",
+	"FailedMount":                "a volume could not be mounted: check the secrets and config maps it references exist",
+	"FailedScheduling":           "no node can run the pod: check the cluster has enough capacity for the container's resource requests",
+	"ProgressDeadlineExceeded":   "the revision's pods did not become ready in time: check the container starts and listens on $PORT",
+	"ServiceTimeout":             "the revision's pods did not become ready in time: check the container starts and listens on $PORT",
+	"BuildFailed":                "the build failed: check its logs with 'kr logs build NAME'",
+	"RevisionFailed":             "the latest revision failed: see the revision's problems",
+	"RevisionMissing":            "the route is waiting for a ready revision: see the revision's problems",
+}
+
+func fixForReason(reason string) string {
+	return fixes[reason]
+}
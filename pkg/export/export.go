@@ -0,0 +1,344 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SecretMode says what to do with the Secrets an export references
+type SecretMode string
+
+const (
+	// IncludeSecrets exports secrets as they are
+	IncludeSecrets SecretMode = "include"
+
+	// PlaceholderSecrets exports secrets with each value replaced by Placeholder
+	PlaceholderSecrets SecretMode = "placeholder"
+
+	// OmitSecrets leaves secrets out of the export
+	OmitSecrets SecretMode = "omit"
+)
+
+// Placeholder replaces the values of secrets exported with PlaceholderSecrets
+const Placeholder = "CHANGE_ME"
+
+// serverSetAnnotations are added by kubectl and the knative webhooks rather
+// than by whoever created an object
+var serverSetAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"serving.knative.dev/creator",
+	"serving.knative.dev/lastModifier",
+}
+
+// Result is an exported namespace
+type Result struct {
+	// Objects are in the order they should be applied: Secrets,
+	// ServiceAccounts, BuildTemplates, Builds, Configurations, Routes then
+	// Services, sorted by name within each kind
+	Objects []interface{}
+
+	// Warnings are references to objects which don't exist
+	Warnings []string
+}
+
+// Namespace fetches the Services, Configurations, Routes, Builds and
+// BuildTemplates in the namespace c talks to, along with the ServiceAccounts
+// and Secrets they reference, and strips them down to what is needed to
+// create them again: status, server-set metadata (including the namespace),
+// spec generations and defaulted fields are removed.
+//
+// Objects created by other objects, like the Configuration and Route of a
+// Service, are left out since applying their owner creates them again
+func Namespace(c kube.Client, secrets SecretMode) (Result, error) {
+	e := exporter{client: c, secrets: secrets, serviceAccounts: make(map[string]bool), secretNames: make(map[string]bool)}
+
+	var templates build.BuildTemplateList
+	if err := c.List(kube.BuildTemplates, "", &templates); err != nil {
+		return Result{}, err
+	}
+
+	var builds build.BuildList
+	if err := c.List(kube.Builds, "", &builds); err != nil {
+		return Result{}, err
+	}
+
+	var configurations serving.ConfigurationList
+	if err := c.List(kube.Configurations, "", &configurations); err != nil {
+		return Result{}, err
+	}
+
+	var routes serving.RouteList
+	if err := c.List(kube.Routes, "", &routes); err != nil {
+		return Result{}, err
+	}
+
+	var services serving.ServiceList
+	if err := c.List(kube.Services, "", &services); err != nil {
+		return Result{}, err
+	}
+
+	sort.Slice(templates.Items, func(i, j int) bool { return templates.Items[i].Name < templates.Items[j].Name })
+	sort.Slice(builds.Items, func(i, j int) bool { return builds.Items[i].Name < builds.Items[j].Name })
+	sort.Slice(configurations.Items, func(i, j int) bool { return configurations.Items[i].Name < configurations.Items[j].Name })
+	sort.Slice(routes.Items, func(i, j int) bool { return routes.Items[i].Name < routes.Items[j].Name })
+	sort.Slice(services.Items, func(i, j int) bool { return services.Items[i].Name < services.Items[j].Name })
+
+	var exported []interface{}
+	for i := range templates.Items {
+		t := &templates.Items[i]
+		if !owned(t.ObjectMeta) {
+			t.ObjectMeta, t.Spec.Generation = clean(t.ObjectMeta), 0
+			e.referencedByVolumes(t.Spec.Volumes)
+			e.referencedByContainers(t.Spec.Steps...)
+			exported = append(exported, t)
+		}
+	}
+
+	for i := range builds.Items {
+		b := &builds.Items[i]
+		if !owned(b.ObjectMeta) {
+			b.ObjectMeta, b.Status = clean(b.ObjectMeta), build.BuildStatus{}
+			e.referencedByBuild(&b.Spec)
+			exported = append(exported, b)
+		}
+	}
+
+	for i := range configurations.Items {
+		cfg := &configurations.Items[i]
+		if !owned(cfg.ObjectMeta) {
+			cfg.ObjectMeta, cfg.Status = clean(cfg.ObjectMeta), serving.ConfigurationStatus{}
+			e.referencedByConfiguration(&cfg.Spec)
+			exported = append(exported, cfg)
+		}
+	}
+
+	for i := range routes.Items {
+		r := &routes.Items[i]
+		if !owned(r.ObjectMeta) {
+			r.ObjectMeta, r.Spec.Generation, r.Status = clean(r.ObjectMeta), 0, serving.RouteStatus{}
+			exported = append(exported, r)
+		}
+	}
+
+	for i := range services.Items {
+		s := &services.Items[i]
+		if !owned(s.ObjectMeta) {
+			s.ObjectMeta, s.Spec.Generation, s.Status = clean(s.ObjectMeta), 0, serving.ServiceStatus{}
+			if config := knative.ServiceConfiguration(s); config != nil {
+				e.referencedByConfiguration(config)
+			}
+
+			exported = append(exported, s)
+		}
+	}
+
+	accounts, err := e.exportServiceAccounts()
+	if err != nil {
+		return Result{}, err
+	}
+
+	secretObjects, err := e.exportSecrets()
+	if err != nil {
+		return Result{}, err
+	}
+
+	var objects []interface{}
+	objects = append(objects, secretObjects...)
+	objects = append(objects, accounts...)
+	objects = append(objects, exported...)
+
+	return Result{Objects: objects, Warnings: e.warnings}, nil
+}
+
+type exporter struct {
+	client          kube.Client
+	secrets         SecretMode
+	serviceAccounts map[string]bool
+	secretNames     map[string]bool
+	warnings        []string
+}
+
+func (e *exporter) exportServiceAccounts() ([]interface{}, error) {
+	var objects []interface{}
+	for _, name := range sortedKeys(e.serviceAccounts) {
+		var sa corev1.ServiceAccount
+		if err := e.client.Get(kube.ServiceAccounts, name, &sa); err != nil {
+			if kube.IsNotFound(err) {
+				e.warnings = append(e.warnings, fmt.Sprintf("service account %s is referenced but does not exist", name))
+				continue
+			}
+
+			return nil, err
+		}
+
+		sa.ObjectMeta = clean(sa.ObjectMeta)
+
+		// the token secrets of a service account are created along with it, so
+		// they're left out
+		var secrets []corev1.ObjectReference
+		for _, ref := range sa.Secrets {
+			var secret corev1.Secret
+			err := e.client.Get(kube.Secrets, ref.Name, &secret)
+			if err == nil && secret.Type == corev1.SecretTypeServiceAccountToken {
+				continue
+			}
+
+			if err != nil && !kube.IsNotFound(err) {
+				return nil, err
+			}
+
+			secrets = append(secrets, corev1.ObjectReference{Name: ref.Name})
+			e.secretNames[ref.Name] = true
+		}
+
+		sa.Secrets = secrets
+		for _, ref := range sa.ImagePullSecrets {
+			e.secretNames[ref.Name] = true
+		}
+
+		objects = append(objects, &sa)
+	}
+
+	return objects, nil
+}
+
+func (e *exporter) exportSecrets() ([]interface{}, error) {
+	var objects []interface{}
+	for _, name := range sortedKeys(e.secretNames) {
+		var secret corev1.Secret
+		if err := e.client.Get(kube.Secrets, name, &secret); err != nil {
+			if kube.IsNotFound(err) {
+				e.warnings = append(e.warnings, fmt.Sprintf("secret %s is referenced but does not exist", name))
+				continue
+			}
+
+			return nil, err
+		}
+
+		switch e.secrets {
+		case OmitSecrets:
+			continue
+		case PlaceholderSecrets:
+			placeholders := make(map[string]string)
+			for key := range secret.Data {
+				placeholders[key] = Placeholder
+			}
+
+			for key := range secret.StringData {
+				placeholders[key] = Placeholder
+			}
+
+			secret.Data, secret.StringData = nil, placeholders
+		}
+
+		secret.ObjectMeta = clean(secret.ObjectMeta)
+		objects = append(objects, &secret)
+	}
+
+	return objects, nil
+}
+
+func (e *exporter) referencedByConfiguration(c *serving.ConfigurationSpec) {
+	c.Generation = 0
+	if c.Build != nil {
+		e.referencedByBuild(c.Build)
+	}
+
+	// Multi is the default concurrency model
+	spec := &c.RevisionTemplate.Spec
+	if spec.ConcurrencyModel == serving.RevisionRequestConcurrencyModelMulti {
+		spec.ConcurrencyModel = ""
+	}
+
+	if spec.ServiceAccountName != "" {
+		e.serviceAccounts[spec.ServiceAccountName] = true
+	}
+
+	e.referencedByContainers(spec.Container)
+}
+
+func (e *exporter) referencedByBuild(b *build.BuildSpec) {
+	b.Generation = 0
+	if b.ServiceAccountName != "" {
+		e.serviceAccounts[b.ServiceAccountName] = true
+	}
+
+	if b.Template != nil {
+		e.referencedByContainers(corev1.Container{Env: b.Template.Env})
+	}
+
+	if b.Source != nil && b.Source.Custom != nil {
+		e.referencedByContainers(*b.Source.Custom)
+	}
+
+	e.referencedByVolumes(b.Volumes)
+	e.referencedByContainers(b.Steps...)
+}
+
+func (e *exporter) referencedByContainers(containers ...corev1.Container) {
+	for _, c := range containers {
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				e.secretNames[env.ValueFrom.SecretKeyRef.Name] = true
+			}
+		}
+
+		for _, from := range c.EnvFrom {
+			if from.SecretRef != nil {
+				e.secretNames[from.SecretRef.Name] = true
+			}
+		}
+	}
+}
+
+func (e *exporter) referencedByVolumes(volumes []corev1.Volume) {
+	for _, v := range volumes {
+		if v.Secret != nil {
+			e.secretNames[v.Secret.SecretName] = true
+		}
+	}
+}
+
+// clean returns the parts of an object's metadata which were set by whoever
+// created it
+func clean(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	annotations := make(map[string]string)
+	for k, v := range meta.Annotations {
+		annotations[k] = v
+	}
+
+	for _, k := range serverSetAnnotations {
+		delete(annotations, k)
+	}
+
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+
+	return metav1.ObjectMeta{
+		Name:        meta.Name,
+		Labels:      meta.Labels,
+		Annotations: annotations,
+	}
+}
+
+func owned(meta metav1.ObjectMeta) bool {
+	return len(meta.OwnerReferences) > 0
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
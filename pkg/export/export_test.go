@@ -0,0 +1,116 @@
+package export_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/export"
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func cluster() *kube.Fake {
+	s := knative.NewRunLatestService("web",
+		knative.WithRevisionTemplate("docker.io/foo/web", nil, nil),
+		knative.WithEnvVar(corev1.EnvVar{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "api-token"}, Key: "token",
+		}}}),
+		knative.WithBuild(knative.WithServiceAccount("builder")),
+	)
+	s.Namespace = "prod"
+	s.UID = "1234"
+	s.ResourceVersion = "99"
+	s.Spec.Generation = 3
+	s.Annotations = map[string]string{"serving.knative.dev/creator": "someone", "team": "web"}
+	s.Status.LatestReadyRevisionName = "web-00003"
+
+	owned := metav1.OwnerReference{APIVersion: "serving.knative.dev/v1alpha1", Kind: "Service", Name: "web"}
+	config := knative.NewConfiguration("web")
+	config.OwnerReferences = []metav1.OwnerReference{owned}
+	route := knative.NewRoute("web")
+	route.OwnerReferences = []metav1.OwnerReference{owned}
+
+	b := knative.NewBuild("nightly", knative.WithStep("test", "golang", "go", "test"), knative.WithServiceAccount("missing"))
+	b.Status.Conditions = []build.BuildCondition{{Type: build.BuildSucceeded, Status: corev1.ConditionTrue}}
+
+	sa := knative.NewServiceAccount("builder", knative.WithSecrets("git-creds", "builder-token-abcde"))
+	token := knative.NewSecret("builder-token-abcde")
+	token.Type = corev1.SecretTypeServiceAccountToken
+	creds := knative.NewSecret("git-creds", knative.WithBasicAuth("user", "pass"), knative.WithGitTarget("github.com"))
+	apiToken := knative.NewSecret("api-token")
+	apiToken.Data["token"] = []byte("s3cret")
+	unreferenced := knative.NewSecret("unreferenced")
+
+	return kube.NewFake(s, config, route, b, knative.NewBuildTemplate("kaniko"), &sa, &token, &creds, &apiToken, &unreferenced)
+}
+
+func TestNamespace(t *testing.T) {
+	result, err := export.Namespace(cluster(), export.IncludeSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, o := range result.Objects {
+		names = append(names, knative.Kind(o)+"/"+o.(metav1.Object).GetName())
+	}
+
+	errorIfNotEqual(t, names, []string{
+		"Secret/api-token",
+		"Secret/git-creds",
+		"ServiceAccount/builder",
+		"BuildTemplate/kaniko",
+		"Build/nightly",
+		"Service/web",
+	}, "expected objects to be '%v' but were '%v'")
+
+	errorIfNotEqual(t, result.Warnings, []string{"service account missing is referenced but does not exist"}, "expected warnings to be '%v' but were '%v'")
+
+	s := result.Objects[5].(*serving.Service)
+	errorIfNotEqual(t, s.ObjectMeta, metav1.ObjectMeta{Name: "web", Annotations: map[string]string{"team": "web"}}, "expected metadata to be '%v' but was '%v'")
+	errorIfNotEqual(t, s.Spec.Generation, int64(0), "expected generation to be %d but was %d")
+	errorIfNotEqual(t, s.Status, serving.ServiceStatus{}, "expected status to be '%v' but was '%v'")
+	errorIfNotEqual(t, s.Spec.RunLatest.Configuration.RevisionTemplate.Spec.ConcurrencyModel, serving.RevisionRequestConcurrencyModelType(""), "expected the default concurrency model to be removed, '%s', but was '%s'")
+
+	errorIfNotEqual(t, result.Objects[4].(*build.Build).Status, build.BuildStatus{}, "expected build status to be '%v' but was '%v'")
+	errorIfNotEqual(t, result.Objects[2].(*corev1.ServiceAccount).Secrets, []corev1.ObjectReference{{Name: "git-creds"}}, "expected service account secrets to be '%v' but were '%v'")
+	errorIfNotEqual(t, result.Objects[0].(*corev1.Secret).Data, map[string][]byte{"token": []byte("s3cret")}, "expected secret data to be '%v' but was '%v'")
+}
+
+func TestNamespaceSecretPlaceholders(t *testing.T) {
+	result, err := export.Namespace(cluster(), export.PlaceholderSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := result.Objects[1].(*corev1.Secret)
+	errorIfNotEqual(t, secret.Name, "git-creds", "expected secret to be '%s' but was '%s'")
+	errorIfNotEqual(t, len(secret.Data), 0, "expected %d data values but got %d")
+	errorIfNotEqual(t, secret.StringData, map[string]string{"username": export.Placeholder, "password": export.Placeholder}, "expected string data to be '%v' but was '%v'")
+	errorIfNotEqual(t, secret.Annotations, map[string]string{"build.knative.dev/git-0": "github.com"}, "expected annotations to be kept, '%v', but were '%v'")
+}
+
+func TestNamespaceOmitSecrets(t *testing.T) {
+	result, err := export.Namespace(cluster(), export.OmitSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range result.Objects {
+		if _, ok := o.(*corev1.Secret); ok {
+			t.Errorf("expected no secrets, but got %v", o)
+		}
+	}
+
+	errorIfNotEqual(t, len(result.Objects), 4, "expected %d objects but got %d")
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
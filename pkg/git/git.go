@@ -0,0 +1,78 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Source describes the origin and current commit of a local git checkout
+type Source struct {
+	URL    string
+	Commit string
+	Dirty  bool
+}
+
+// Inspect returns the Source of the git checkout containing dir
+func Inspect(dir string) (Source, error) {
+	var src Source
+
+	origin, err := run(dir, "config", "--get", "remote.origin.url")
+	if err != nil {
+		return src, fmt.Errorf("could not find origin remote: %s", err)
+	}
+
+	if src.Commit, err = run(dir, "rev-parse", "HEAD"); err != nil {
+		return src, fmt.Errorf("could not find current commit: %s", err)
+	}
+
+	status, err := run(dir, "status", "--porcelain")
+	if err != nil {
+		return src, fmt.Errorf("could not check working tree status: %s", err)
+	}
+
+	src.URL = NormalizeURL(origin)
+	src.Dirty = status != ""
+	return src, nil
+}
+
+var scpLike = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.*)$`)
+
+// NormalizeURL converts ssh, scp-style and git:// remote urls in to the
+// equivalent https url, stripping any credentials and '.git' suffix, so that
+// every form of a remote produces the same url
+func NormalizeURL(remote string) string {
+	remote = strings.TrimSpace(remote)
+
+	var host, path string
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+		if u.Scheme == "http" || u.Scheme == "https" {
+			host = u.Host
+		}
+	} else if m := scpLike.FindStringSubmatch(remote); m != nil {
+		host, path = m[1], m[2]
+	} else {
+		return remote
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return "https://" + host + "/" + path
+}
+
+func run(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,84 @@
+package knative
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnnotationPrefix is the prefix of the annotations knightrider adds to objects
+const AnnotationPrefix = "knightrider.julz.github.io/"
+
+// GitCommitAnnotation records the git commit an object was generated from
+const GitCommitAnnotation = AnnotationPrefix + "git-commit"
+
+// BuildTimeoutAnnotation records how long a build may run for. The version of
+// knative build kr generates objects for has no timeout in the build spec, so
+// this is enforced by kr when it waits for the build (see builds.Waiter)
+const BuildTimeoutAnnotation = AnnotationPrefix + "build-timeout"
+
+// AnnotateBuildTimeout records the timeout of a Build, or of the build of a
+// Service or Configuration, in a BuildTimeoutAnnotation
+func AnnotateBuildTimeout(meta *metav1.ObjectMeta, timeout time.Duration) {
+	Annotate(meta, BuildTimeoutAnnotation, timeout.String())
+}
+
+// BuildTimeout returns the timeout recorded by AnnotateBuildTimeout, or zero
+// if there isn't one
+func BuildTimeout(meta metav1.ObjectMeta) (time.Duration, error) {
+	s, ok := meta.Annotations[BuildTimeoutAnnotation]
+	if !ok {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s has a bad %s annotation, expected a positive duration like 20m but got %q", meta.Name, BuildTimeoutAnnotation, s)
+	}
+
+	return d, nil
+}
+
+// Annotate sets an annotation on an object, creating the annotations map if needed
+func Annotate(meta *metav1.ObjectMeta, key, value string) {
+	if meta.Annotations == nil {
+		meta.Annotations = make(map[string]string)
+	}
+
+	meta.Annotations[key] = value
+}
+
+// serverSetAnnotations are added by kubectl and the knative webhooks rather
+// than by whoever created an object
+var serverSetAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"serving.knative.dev/creator",
+	"serving.knative.dev/lastModifier",
+}
+
+// UserMeta returns the name, namespace, labels and annotations of an object,
+// without the fields and annotations the server sets, so that an object
+// fetched from the cluster can be changed and applied again without removing
+// anything else
+func UserMeta(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	annotations := make(map[string]string)
+	for k, v := range meta.Annotations {
+		annotations[k] = v
+	}
+
+	for _, k := range serverSetAnnotations {
+		delete(annotations, k)
+	}
+
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+
+	return metav1.ObjectMeta{
+		Name:        meta.Name,
+		Namespace:   meta.Namespace,
+		Labels:      meta.Labels,
+		Annotations: annotations,
+	}
+}
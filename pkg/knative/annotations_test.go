@@ -0,0 +1,39 @@
+package knative_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/julz/knightrider/pkg/knative"
+)
+
+func TestAnnotate(t *testing.T) {
+	b := knative.NewBuild("foo")
+	knative.Annotate(&b.ObjectMeta, knative.GitCommitAnnotation, "abc123")
+
+	errorIfNotEqual(t, b.Annotations, map[string]string{
+		"knightrider.julz.github.io/git-commit": "abc123",
+	}, "expected build to have annotations '%s' but was '%s'")
+}
+
+func TestBuildTimeout(t *testing.T) {
+	b := knative.NewBuild("foo")
+	if d, err := knative.BuildTimeout(b.ObjectMeta); d != 0 || err != nil {
+		t.Errorf("expected no timeout but got %s, %v", d, err)
+	}
+
+	knative.AnnotateBuildTimeout(&b.ObjectMeta, 90*time.Minute)
+	errorIfNotEqual(t, b.Annotations[knative.BuildTimeoutAnnotation], "1h30m0s", "expected timeout annotation to be '%s' but was '%s'")
+
+	d, err := knative.BuildTimeout(b.ObjectMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, d, 90*time.Minute, "expected timeout to be '%s' but was '%s'")
+
+	knative.Annotate(&b.ObjectMeta, knative.BuildTimeoutAnnotation, "forever")
+	if _, err := knative.BuildTimeout(b.ObjectMeta); err == nil {
+		t.Error("expected a bad timeout annotation to be an error")
+	}
+}
@@ -1,6 +1,9 @@
 package knative
 
 import (
+	"path"
+	"strings"
+
 	build "github.com/knative/build/pkg/apis/build/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -40,6 +43,36 @@ func WithGitSource(url, revision string) BuildSpecOption {
 	}
 }
 
+// WithCustomSource configures a Build with a container which fetches the
+// source in to /workspace
+func WithCustomSource(container corev1.Container) BuildSpecOption {
+	return func(b *build.BuildSpec) {
+		b.Source = &build.SourceSpec{Custom: &container}
+	}
+}
+
+// CraneImage has crane, which can fetch the files of an image, along with a
+// shell and tar
+const CraneImage = "gcr.io/go-containerregistry/crane:debug"
+
+// WithSourceImage configures a Build with a custom source which unpacks the
+// files of image, such as one pushed by source.Registry.PushLayer, in to
+// /workspace. If insecure is true, the image is pulled over http
+func WithSourceImage(image string, insecure bool) BuildSpecOption {
+	export := "crane export "
+	if insecure {
+		export += "--insecure "
+	}
+
+	return WithCustomSource(corev1.Container{
+		Name:    "source",
+		Image:   CraneImage,
+		Command: []string{"/busybox/sh", "-c"},
+		Args:    []string{export + image + " - | tar -x -C /workspace"},
+		Env:     []corev1.EnvVar{{Name: "PATH", Value: "/ko-app:/busybox:/usr/local/bin:/usr/bin:/bin"}},
+	})
+}
+
 // WithBuildTemplate configures a BuildTemplate for a Build
 func WithBuildTemplate(name string, args map[string]string, env map[string]string) BuildSpecOption {
 	return func(b *build.BuildSpec) {
@@ -74,9 +107,207 @@ func WithStep(name, image string, args ...string) BuildSpecOption {
 	}
 }
 
+// StepOption is an option that can configure a step of a Build
+type StepOption func(*corev1.Container)
+
+// WithStepOptions configures the named step of a Build, or every step added
+// before it if step is ""
+func WithStepOptions(step string, options ...StepOption) BuildSpecOption {
+	return func(b *build.BuildSpec) {
+		for i := range b.Steps {
+			if step != "" && b.Steps[i].Name != step {
+				continue
+			}
+
+			for _, o := range options {
+				o(&b.Steps[i])
+			}
+		}
+	}
+}
+
+// WithStepEnv sets an environment variable of a step
+func WithStepEnv(name, value string) StepOption {
+	return func(c *corev1.Container) {
+		c.Env = append(removeEnv(c.Env, name), corev1.EnvVar{Name: name, Value: value})
+	}
+}
+
+// WithStepWorkingDir sets the directory a step runs in
+func WithStepWorkingDir(dir string) StepOption {
+	return func(c *corev1.Container) {
+		c.WorkingDir = dir
+	}
+}
+
+// WithStepCommand replaces the entrypoint of a step's image, which is run with
+// the step's args
+func WithStepCommand(command ...string) StepOption {
+	return func(c *corev1.Container) {
+		c.Command = command
+	}
+}
+
+// WithStepRequests sets the cpu, memory and other resources a step requests
+func WithStepRequests(requests corev1.ResourceList) StepOption {
+	return func(c *corev1.Container) {
+		if c.Resources.Requests == nil {
+			c.Resources.Requests = make(corev1.ResourceList)
+		}
+
+		for name, quantity := range requests {
+			c.Resources.Requests[name] = quantity
+		}
+	}
+}
+
 // WithServiceAccount adds a ServiceAccount to the Build
 func WithServiceAccount(name string) BuildSpecOption {
 	return func(b *build.BuildSpec) {
 		b.ServiceAccountName = name
 	}
 }
+
+// KanikoImage is the kaniko executor, which builds Dockerfiles and pushes the
+// result without needing a docker daemon
+const KanikoImage = "gcr.io/kaniko-project/executor"
+
+// paths the kaniko options mount their volumes at
+const (
+	kanikoDockerConfig = "/kaniko/.docker"
+	kanikoCache        = "/kaniko/cache"
+)
+
+// WithKanikoStep adds a step which builds dockerfile with kaniko, using
+// context as the build context, and pushes the result to image. Relative paths
+// are relative to the root of the source. The step pushes with the docker
+// credentials of the build's service account, unless WithDockerConfigSecret is
+// used
+func WithKanikoStep(dockerfile, context, image string) BuildSpecOption {
+	return func(b *build.BuildSpec) {
+		b.Steps = append(b.Steps, corev1.Container{
+			Name:  "build-and-push",
+			Image: KanikoImage,
+			Args: []string{
+				"--dockerfile=" + workspacePath(dockerfile),
+				"--context=" + workspacePath(context),
+				"--destination=" + image,
+			},
+			// knative build writes the service account's credentials to
+			// $HOME/.docker, but kaniko looks in /kaniko/.docker by default
+			Env: []corev1.EnvVar{{Name: "DOCKER_CONFIG", Value: "/builder/home/.docker"}},
+		})
+	}
+}
+
+// WithDockerConfigSecret mounts the config.json of a kubernetes.io/dockerconfigjson
+// secret in to the kaniko steps of a Build, so they push with its credentials
+func WithDockerConfigSecret(secret string) BuildSpecOption {
+	return func(b *build.BuildSpec) {
+		source := SecretVolume(secret)
+		source.Secret.Items = []corev1.KeyToPath{{Key: corev1.DockerConfigJsonKey, Path: "config.json"}}
+		WithBuildVolume("docker-config", source)(b)
+
+		for i := range kanikoSteps(b) {
+			step := &b.Steps[i]
+			step.VolumeMounts = append(step.VolumeMounts, corev1.VolumeMount{Name: "docker-config", MountPath: kanikoDockerConfig})
+			step.Env = removeEnv(step.Env, "DOCKER_CONFIG")
+		}
+	}
+}
+
+// WithKanikoCache turns on the caches of the kaniko steps of a Build. Kaniko
+// looks for base images in a kaniko directory of the named build volume (see
+// WithBuildVolume), and caches layers in the registry, by default in a cache
+// repository next to the image it pushes
+func WithKanikoCache(volume string) BuildSpecOption {
+	return func(b *build.BuildSpec) {
+		for i := range kanikoSteps(b) {
+			step := &b.Steps[i]
+			step.Args = append(step.Args, "--cache=true", "--cache-dir="+kanikoCache)
+			step.VolumeMounts = append(step.VolumeMounts, corev1.VolumeMount{Name: volume, MountPath: kanikoCache, SubPath: "kaniko"})
+		}
+	}
+}
+
+// WithBuildVolume adds a volume to a Build, which steps can mount with
+// WithVolumeMount
+func WithBuildVolume(name string, source corev1.VolumeSource) BuildSpecOption {
+	return func(b *build.BuildSpec) {
+		b.Volumes = append(b.Volumes, corev1.Volume{
+			Name:         name,
+			VolumeSource: source,
+		})
+	}
+}
+
+// WithVolumeMount mounts a volume of a Build at path in every step added
+// before it. If subPath isn't empty, only that directory of the volume is
+// mounted
+func WithVolumeMount(volume, path, subPath string) BuildSpecOption {
+	return func(b *build.BuildSpec) {
+		for i := range b.Steps {
+			b.Steps[i].VolumeMounts = append(b.Steps[i].VolumeMounts, corev1.VolumeMount{
+				Name:      volume,
+				MountPath: path,
+				SubPath:   subPath,
+			})
+		}
+	}
+}
+
+// EmptyDirVolume is a volume which starts empty and lasts as long as the build
+func EmptyDirVolume() corev1.VolumeSource {
+	return corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+}
+
+// SecretVolume is a volume with a file for each key of a secret
+func SecretVolume(secret string) corev1.VolumeSource {
+	return corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secret}}
+}
+
+// ConfigMapVolume is a volume with a file for each key of a config map
+func ConfigMapVolume(configMap string) corev1.VolumeSource {
+	return corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+		LocalObjectReference: corev1.LocalObjectReference{Name: configMap},
+	}}
+}
+
+// ClaimVolume is a volume backed by a persistent volume claim, so its contents
+// outlive the build
+func ClaimVolume(claim string) corev1.VolumeSource {
+	return corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim}}
+}
+
+// kanikoSteps returns the indexes of the steps of b which run kaniko
+func kanikoSteps(b *build.BuildSpec) map[int]bool {
+	steps := make(map[int]bool)
+	for i, step := range b.Steps {
+		if step.Image == KanikoImage || strings.HasPrefix(step.Image, KanikoImage+":") || strings.HasPrefix(step.Image, KanikoImage+"@") {
+			steps[i] = true
+		}
+	}
+
+	return steps
+}
+
+func removeEnv(env []corev1.EnvVar, name string) []corev1.EnvVar {
+	var kept []corev1.EnvVar
+	for _, e := range env {
+		if e.Name != name {
+			kept = append(kept, e)
+		}
+	}
+
+	return kept
+}
+
+// workspacePath returns p relative to /workspace, where builds check out their
+// source, unless it's already absolute
+func workspacePath(p string) string {
+	if path.IsAbs(p) {
+		return p
+	}
+
+	return path.Join("/workspace", p)
+}
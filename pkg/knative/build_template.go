@@ -0,0 +1,213 @@
+package knative
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewBuildTemplate creates a new BuildTemplate object
+func NewBuildTemplate(name string, options ...BuildTemplateOption) *build.BuildTemplate {
+	t := &build.BuildTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "build.knative.dev/v1alpha1",
+			Kind:       "BuildTemplate",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+
+	for _, o := range options {
+		o(&t.Spec)
+	}
+
+	return t
+}
+
+// BuildTemplateOption is an option that can configure a BuildTemplateSpec
+type BuildTemplateOption func(*build.BuildTemplateSpec)
+
+// WithParameter adds a required parameter to a BuildTemplate, which steps can
+// refer to as ${NAME}
+func WithParameter(name, description string) BuildTemplateOption {
+	return func(t *build.BuildTemplateSpec) {
+		t.Parameters = append(t.Parameters, build.ParameterSpec{
+			Name:        name,
+			Description: description,
+		})
+	}
+}
+
+// WithParameterDefault adds an optional parameter to a BuildTemplate
+func WithParameterDefault(name, description, def string) BuildTemplateOption {
+	return func(t *build.BuildTemplateSpec) {
+		t.Parameters = append(t.Parameters, build.ParameterSpec{
+			Name:        name,
+			Description: description,
+			Default:     &def,
+		})
+	}
+}
+
+// WithTemplateStep adds a Step to a BuildTemplate
+func WithTemplateStep(name, image string, args ...string) BuildTemplateOption {
+	return func(t *build.BuildTemplateSpec) {
+		t.Steps = append(t.Steps, corev1.Container{
+			Name:  name,
+			Image: image,
+			Args:  args,
+		})
+	}
+}
+
+// TemplateArgumentsError lists the problems with the arguments given to a
+// build template, along with the parameters the template does take
+type TemplateArgumentsError struct {
+	Template *build.BuildTemplate
+	Problems []string
+}
+
+func (e *TemplateArgumentsError) Error() string {
+	return fmt.Sprintf("%s\n\nParameters of build template %s:\n%s", strings.Join(e.Problems, "\n"), e.Template.Name, ParameterUsage(e.Template))
+}
+
+// CheckTemplateArguments checks args against the parameters declared by a
+// build template, returning a *TemplateArgumentsError if any of them aren't
+// parameters of the template or any required parameters are missing.
+// Otherwise args are returned with the defaults of any optional parameters
+// which weren't given filled in
+func CheckTemplateArguments(t *build.BuildTemplate, args map[string]string) (map[string]string, error) {
+	var problems []string
+	params := make(map[string]bool)
+	for _, p := range t.Spec.Parameters {
+		params[p.Name] = true
+	}
+
+	var names []string
+	for name := range args {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		if params[name] {
+			continue
+		}
+
+		problem := fmt.Sprintf("build template %s has no parameter %s", t.Name, name)
+		if suggestion := closest(name, t.Spec.Parameters); suggestion != "" {
+			problem += fmt.Sprintf(", did you mean %s?", suggestion)
+		}
+
+		problems = append(problems, problem)
+	}
+
+	checked := make(map[string]string)
+	for k, v := range args {
+		checked[k] = v
+	}
+
+	for _, p := range t.Spec.Parameters {
+		if _, ok := args[p.Name]; ok {
+			continue
+		}
+
+		if p.Default == nil {
+			problems = append(problems, fmt.Sprintf("build template %s needs a value for %s (-a %s=...)", t.Name, p.Name, p.Name))
+			continue
+		}
+
+		checked[p.Name] = *p.Default
+	}
+
+	if len(problems) > 0 {
+		return nil, &TemplateArgumentsError{Template: t, Problems: problems}
+	}
+
+	return checked, nil
+}
+
+// ParameterUsage describes the parameters of a build template, one per line,
+// in the style of --help output
+func ParameterUsage(t *build.BuildTemplate) string {
+	width := 0
+	for _, p := range t.Spec.Parameters {
+		if len(p.Name) > width {
+			width = len(p.Name)
+		}
+	}
+
+	var b bytes.Buffer
+	for _, p := range t.Spec.Parameters {
+		usage := p.Description
+		if p.Default != nil {
+			usage = strings.TrimSpace(fmt.Sprintf("%s (default %q)", usage, *p.Default))
+		} else {
+			usage = strings.TrimSpace(usage + " (required)")
+		}
+
+		fmt.Fprintf(&b, "  %-*s   %s\n", width, p.Name, usage)
+	}
+
+	return b.String()
+}
+
+// closest returns the parameter with the name most like name, if any are
+// close enough to be a likely typo
+func closest(name string, params []build.ParameterSpec) string {
+	best, bestDistance := "", 3
+	for _, p := range params {
+		if d := distance(strings.ToUpper(name), strings.ToUpper(p.Name)); d < bestDistance {
+			best, bestDistance = p.Name, d
+		}
+	}
+
+	return best
+}
+
+// distance is the number of single character edits, or swaps of adjacent
+// characters, needed to turn a in to b
+func distance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = minimum(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minimum(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
+
+func minimum(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,75 @@
+package knative_test
+
+import (
+	"testing"
+
+	"github.com/julz/knightrider/pkg/knative"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestBuildTemplate(t *testing.T) {
+	bt := knative.NewBuildTemplate("kaniko",
+		knative.WithParameter("IMAGE", "where to push the image"),
+		knative.WithParameterDefault("DOCKERFILE", "path to the Dockerfile", "/workspace/Dockerfile"),
+		knative.WithTemplateStep("build", "gcr.io/kaniko-project/executor", "--dockerfile=${DOCKERFILE}", "--destination=${IMAGE}"),
+	)
+
+	errorIfNotEqual(t, bt.Name, "kaniko", "expected build template to have name '%s' but was '%s'")
+	errorIfNotEqual(t, bt.Kind, "BuildTemplate", "expected build template to have kind '%s' but was '%s'")
+
+	def := "/workspace/Dockerfile"
+	errorIfNotEqual(t, bt.Spec.Parameters, []build.ParameterSpec{
+		{Name: "IMAGE", Description: "where to push the image"},
+		{Name: "DOCKERFILE", Description: "path to the Dockerfile", Default: &def},
+	}, "expected build template to have parameters '%v' but was '%v'")
+
+	errorIfNotEqual(t, bt.Spec.Steps, []corev1.Container{
+		{Name: "build", Image: "gcr.io/kaniko-project/executor", Args: []string{"--dockerfile=${DOCKERFILE}", "--destination=${IMAGE}"}},
+	}, "expected build template to have steps '%v' but was '%v'")
+}
+
+func kaniko() *build.BuildTemplate {
+	return knative.NewBuildTemplate("kaniko",
+		knative.WithParameter("IMAGE", "where to push the image"),
+		knative.WithParameterDefault("DOCKERFILE", "path to the Dockerfile", "/workspace/Dockerfile"),
+		knative.WithParameter("CACHE", ""),
+	)
+}
+
+func TestCheckTemplateArguments(t *testing.T) {
+	args, err := knative.CheckTemplateArguments(kaniko(), map[string]string{"IMAGE": "docker.io/foo/bar", "CACHE": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, args, map[string]string{
+		"IMAGE":      "docker.io/foo/bar",
+		"CACHE":      "true",
+		"DOCKERFILE": "/workspace/Dockerfile",
+	}, "expected args with defaults filled in to be '%v' but were '%v'")
+}
+
+func TestCheckTemplateArgumentsErrors(t *testing.T) {
+	_, err := knative.CheckTemplateArguments(kaniko(), map[string]string{"IMGAE": "docker.io/foo/bar", "DOCKERFILE": "Dockerfile", "VERBOSE": "true"})
+
+	e, ok := err.(*knative.TemplateArgumentsError)
+	if !ok {
+		t.Fatalf("expected a *TemplateArgumentsError but got %v", err)
+	}
+
+	errorIfNotEqual(t, e.Problems, []string{
+		"build template kaniko has no parameter IMGAE, did you mean IMAGE?",
+		"build template kaniko has no parameter VERBOSE",
+		"build template kaniko needs a value for IMAGE (-a IMAGE=...)",
+		"build template kaniko needs a value for CACHE (-a CACHE=...)",
+	}, "expected problems to be '%v' but were '%v'")
+}
+
+func TestParameterUsage(t *testing.T) {
+	errorIfNotEqual(t, knative.ParameterUsage(kaniko()), ""+
+		"  IMAGE        where to push the image (required)\n"+
+		"  DOCKERFILE   path to the Dockerfile (default \"/workspace/Dockerfile\")\n"+
+		"  CACHE        (required)\n",
+		"expected usage to be:\n%s\nbut was:\n%s")
+}
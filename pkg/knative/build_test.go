@@ -8,6 +8,7 @@ import (
 	build "github.com/knative/build/pkg/apis/build/v1alpha1"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func TestSimpleBuild(t *testing.T) {
@@ -42,6 +43,17 @@ func TestBuildWithGitSource(t *testing.T) {
 	errorIfNotEqual(t, b.Spec.Source.Git.Revision, "master", "expected build spec to have source revision '%s' but was '%s'")
 }
 
+func TestBuildWithSourceImage(t *testing.T) {
+	b := knative.NewBuild("local", knative.WithSourceImage("localhost:5000/app-source@sha256:abc", true))
+
+	if b.Spec.Source == nil || b.Spec.Source.Custom == nil {
+		t.Fatalf("expected build spec to have a custom source")
+	}
+
+	errorIfNotEqual(t, b.Spec.Source.Custom.Image, knative.CraneImage, "expected the source to be fetched with '%s' but was fetched with '%s'")
+	errorIfNotEqual(t, b.Spec.Source.Custom.Args, []string{"crane export --insecure localhost:5000/app-source@sha256:abc - | tar -x -C /workspace"}, "expected source args to be '%v' but were '%v'")
+}
+
 func TestBuildWithBuildTemplate(t *testing.T) {
 	b := knative.NewBuild("with-build-template", knative.WithBuildTemplate("buildpack", map[string]string{"a": "b"}, map[string]string{"k": "v"}))
 
@@ -64,6 +76,110 @@ func TestBuildWithSteps(t *testing.T) {
 	}, "expected build template to have steps '%s' but was '%s'")
 }
 
+func TestBuildWithStepOptions(t *testing.T) {
+	b := knative.NewBuild("with-step-options",
+		knative.WithStep("compile", "golang", "build", "./..."),
+		knative.WithStep("test", "golang", "test", "./..."),
+		knative.WithStepOptions("", knative.WithStepEnv("CGO_ENABLED", "0"), knative.WithStepWorkingDir("/workspace/src")),
+		knative.WithStepOptions("compile",
+			knative.WithStepCommand("go"),
+			knative.WithStepEnv("CGO_ENABLED", "1"),
+			knative.WithStepRequests(corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")}),
+		),
+	)
+
+	errorIfNotEqual(t, b.Spec.Steps, []corev1.Container{
+		{
+			Name:       "compile",
+			Image:      "golang",
+			Command:    []string{"go"},
+			Args:       []string{"build", "./..."},
+			WorkingDir: "/workspace/src",
+			Env:        []corev1.EnvVar{{Name: "CGO_ENABLED", Value: "1"}},
+			Resources:  corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")}},
+		},
+		{
+			Name:       "test",
+			Image:      "golang",
+			Args:       []string{"test", "./..."},
+			WorkingDir: "/workspace/src",
+			Env:        []corev1.EnvVar{{Name: "CGO_ENABLED", Value: "0"}},
+		},
+	}, "expected build to have steps '%v' but was '%v'")
+}
+
+func TestBuildWithKanikoStep(t *testing.T) {
+	b := knative.NewBuild("kaniko", knative.WithKanikoStep("docker/Dockerfile", ".", "docker.io/me/app"))
+
+	errorIfNotEqual(t, b.Spec.Steps, []corev1.Container{{
+		Name:  "build-and-push",
+		Image: knative.KanikoImage,
+		Args:  []string{"--dockerfile=/workspace/docker/Dockerfile", "--context=/workspace", "--destination=docker.io/me/app"},
+		Env:   []corev1.EnvVar{{Name: "DOCKER_CONFIG", Value: "/builder/home/.docker"}},
+	}}, "expected build to have steps '%v' but was '%v'")
+}
+
+func TestBuildWithKanikoCredentialsAndCache(t *testing.T) {
+	b := knative.NewBuild("kaniko",
+		knative.WithStep("test", "golang", "go", "test"),
+		knative.WithKanikoStep("/src/Dockerfile", "/src", "docker.io/me/app"),
+		knative.WithDockerConfigSecret("regcred"),
+		knative.WithBuildVolume("cache", knative.ClaimVolume("kaniko-cache")),
+		knative.WithKanikoCache("cache"),
+	)
+
+	errorIfNotEqual(t, b.Spec.Steps[0].VolumeMounts, []corev1.VolumeMount(nil), "expected steps which don't run kaniko not to mount anything, '%v', but mounted '%v'")
+
+	kaniko := b.Spec.Steps[1]
+	errorIfNotEqual(t, kaniko.Args, []string{"--dockerfile=/src/Dockerfile", "--context=/src", "--destination=docker.io/me/app", "--cache=true", "--cache-dir=/kaniko/cache"}, "expected kaniko args to be '%v' but were '%v'")
+	errorIfNotEqual(t, kaniko.Env, []corev1.EnvVar(nil), "expected DOCKER_CONFIG to be left to its default, '%v', but env was '%v'")
+	errorIfNotEqual(t, kaniko.VolumeMounts, []corev1.VolumeMount{
+		{Name: "docker-config", MountPath: "/kaniko/.docker"},
+		{Name: "cache", MountPath: "/kaniko/cache", SubPath: "kaniko"},
+	}, "expected kaniko to mount '%v' but mounted '%v'")
+
+	errorIfNotEqual(t, b.Spec.Volumes, []corev1.Volume{
+		{Name: "docker-config", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+			SecretName: "regcred",
+			Items:      []corev1.KeyToPath{{Key: ".dockerconfigjson", Path: "config.json"}},
+		}}},
+		{Name: "cache", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "kaniko-cache"}}},
+	}, "expected build volumes to be '%v' but were '%v'")
+}
+
+func TestBuildWithVolumes(t *testing.T) {
+	b := knative.NewBuild("with-volumes",
+		knative.WithStep("compile", "maven", "mvn", "package"),
+		knative.WithStep("test", "maven", "mvn", "test"),
+		knative.WithBuildVolume("scratch", knative.EmptyDirVolume()),
+		knative.WithBuildVolume("settings", knative.ConfigMapVolume("maven-settings")),
+		knative.WithBuildVolume("token", knative.SecretVolume("api-token")),
+		knative.WithBuildVolume("cache", knative.ClaimVolume("maven-cache")),
+		knative.WithVolumeMount("cache", "/root/.m2", "m2"),
+	)
+
+	errorIfNotEqual(t, b.Spec.Volumes, []corev1.Volume{
+		{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		{Name: "settings", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "maven-settings"}}}},
+		{Name: "token", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "api-token"}}},
+		{Name: "cache", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "maven-cache"}}},
+	}, "expected build volumes to be '%v' but were '%v'")
+
+	for _, step := range b.Spec.Steps {
+		errorIfNotEqual(t, step.VolumeMounts, []corev1.VolumeMount{{Name: "cache", MountPath: "/root/.m2", SubPath: "m2"}}, step.Name+": expected volume mounts to be '%v' but were '%v'")
+	}
+}
+
+func TestPersistentVolumeClaim(t *testing.T) {
+	pvc := knative.NewPersistentVolumeClaim("cache", resource.MustParse("10Gi"))
+
+	errorIfNotEqual(t, pvc.Kind, "PersistentVolumeClaim", "expected kind to be '%s' but was '%s'")
+	errorIfNotEqual(t, pvc.Spec.AccessModes, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}, "expected access modes to be '%v' but were '%v'")
+
+	size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	errorIfNotEqual(t, size.String(), "10Gi", "expected the claim to request '%s' of storage but it requested '%s'")
+}
+
 func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
 	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf(msg, expected, actual)
@@ -0,0 +1,207 @@
+package knative
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/julz/knightrider/pkg/knative/v1"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Decode reads a stream of yml documents separated by '---', or of json
+// objects, and returns a typed object for each of them (e.g. a *serving.Service
+// or a *build.Build). Items of a List are returned as separate objects.
+// Documents of kinds which aren't known are returned as map[string]interface{}
+// so that they can be written back out unchanged
+func Decode(r io.Reader) ([]interface{}, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := split(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []interface{}
+	for _, doc := range docs {
+		o, err := decodeDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, o...)
+	}
+
+	return objects, nil
+}
+
+// split splits b in to json documents, skipping any which are empty
+func split(b []byte) ([]json.RawMessage, error) {
+	var docs []json.RawMessage
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		d := json.NewDecoder(bytes.NewReader(trimmed))
+		for d.More() {
+			var doc json.RawMessage
+			if err := d.Decode(&doc); err != nil {
+				return nil, err
+			}
+
+			docs = append(docs, doc)
+		}
+
+		return docs, nil
+	}
+
+	var current bytes.Buffer
+	flush := func() error {
+		j, err := yaml.YAMLToJSON(current.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if string(j) != "null" {
+			docs = append(docs, j)
+		}
+
+		current.Reset()
+		return nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 64*1024), len(b)+1)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "---") && strings.TrimSpace(line[3:]) == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		current.Write(scanner.Bytes())
+		current.WriteByte('\n')
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return docs, flush()
+}
+
+func decodeDocument(doc json.RawMessage) ([]interface{}, error) {
+	if len(doc) > 0 && doc[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(doc, &items); err != nil {
+			return nil, err
+		}
+
+		return decodeItems(items)
+	}
+
+	var meta metav1.TypeMeta
+	if err := json.Unmarshal(doc, &meta); err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(meta.Kind, "List") {
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+
+		if err := json.Unmarshal(doc, &list); err != nil {
+			return nil, err
+		}
+
+		return decodeItems(list.Items)
+	}
+
+	o := newObject(meta)
+	if err := json.Unmarshal(doc, o); err != nil {
+		return nil, fmt.Errorf("could not decode %s: %s", meta.Kind, err)
+	}
+
+	if m, ok := o.(*map[string]interface{}); ok {
+		return []interface{}{*m}, nil
+	}
+
+	return []interface{}{o}, nil
+}
+
+func decodeItems(items []json.RawMessage) ([]interface{}, error) {
+	var objects []interface{}
+	for _, item := range items {
+		o, err := decodeDocument(item)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, o...)
+	}
+
+	return objects, nil
+}
+
+// newObject returns a pointer to a new, empty object of the given type, or to
+// a map if the type isn't known
+func newObject(meta metav1.TypeMeta) interface{} {
+	switch meta.APIVersion + "/" + meta.Kind {
+	case "serving.knative.dev/v1alpha1/Service":
+		return &serving.Service{}
+	case "serving.knative.dev/v1alpha1/Configuration":
+		return &serving.Configuration{}
+	case "serving.knative.dev/v1alpha1/Route":
+		return &serving.Route{}
+	case "serving.knative.dev/v1alpha1/Revision":
+		return &serving.Revision{}
+	case "serving.knative.dev/v1/Service", "serving.knative.dev/v1beta1/Service":
+		return &v1.Service{}
+	case "serving.knative.dev/v1/Configuration", "serving.knative.dev/v1beta1/Configuration":
+		return &v1.Configuration{}
+	case "serving.knative.dev/v1/Route", "serving.knative.dev/v1beta1/Route":
+		return &v1.Route{}
+	case "build.knative.dev/v1alpha1/Build":
+		return &build.Build{}
+	case "build.knative.dev/v1alpha1/BuildTemplate":
+		return &build.BuildTemplate{}
+	case "v1/Secret":
+		return &corev1.Secret{}
+	case "v1/ServiceAccount":
+		return &corev1.ServiceAccount{}
+	case "v1/ConfigMap":
+		return &corev1.ConfigMap{}
+	case "v1/PersistentVolumeClaim":
+		return &corev1.PersistentVolumeClaim{}
+	case "v1/Service":
+		return &corev1.Service{}
+	case "v1/Pod":
+		return &corev1.Pod{}
+	}
+
+	return &map[string]interface{}{}
+}
+
+// Kind returns the kind of a decoded object, or "" if it doesn't have one
+func Kind(o interface{}) string {
+	switch o := o.(type) {
+	case map[string]interface{}:
+		kind, _ := o["kind"].(string)
+		return kind
+	case runtime.Object:
+		return o.GetObjectKind().GroupVersionKind().Kind
+	}
+
+	return ""
+}
@@ -0,0 +1,207 @@
+package knative_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/knative/v1"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const manifest = `# a service and its build
+apiVersion: serving.knative.dev/v1alpha1
+kind: Service
+metadata:
+  name: my-service
+spec:
+  runLatest:
+    configuration:
+      build:
+        serviceAccountName: buildbot
+      revisionTemplate:
+        spec:
+          container:
+            image: docker.io/foo/bar
+            env:
+            - name: FOO
+              value: foo
+---
+apiVersion: build.knative.dev/v1alpha1
+kind: Build
+metadata:
+  name: my-build
+---
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: my-secret
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+`
+
+func TestDecodeYaml(t *testing.T) {
+	objects, err := knative.Decode(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(objects) != 4 {
+		t.Fatalf("expected 4 objects but got %d", len(objects))
+	}
+
+	s, ok := objects[0].(*serving.Service)
+	if !ok {
+		t.Fatalf("expected a *serving.Service but got %T", objects[0])
+	}
+
+	errorIfNotEqual(t, s.Spec.RunLatest.Configuration.RevisionTemplate.Spec.Container.Image, "docker.io/foo/bar", "expected image to be '%s' but was '%s'")
+
+	b, ok := objects[1].(*build.Build)
+	if !ok {
+		t.Fatalf("expected a *build.Build but got %T", objects[1])
+	}
+
+	errorIfNotEqual(t, b.Name, "my-build", "expected build name to be '%s' but was '%s'")
+
+	if _, ok := objects[2].(*corev1.Secret); !ok {
+		t.Errorf("expected a *corev1.Secret but got %T", objects[2])
+	}
+
+	widget, ok := objects[3].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected unknown kinds to decode to a map but got %T", objects[3])
+	}
+
+	errorIfNotEqual(t, widget["kind"], "Widget", "expected kind to be '%s' but was '%s'")
+}
+
+func TestDecodeJsonList(t *testing.T) {
+	objects, err := knative.Decode(strings.NewReader(`{
+		"apiVersion": "v1",
+		"kind": "List",
+		"items": [
+			{"apiVersion": "serving.knative.dev/v1alpha1", "kind": "Route", "metadata": {"name": "a"}},
+			{"apiVersion": "serving.knative.dev/v1alpha1", "kind": "Configuration", "metadata": {"name": "b"}}
+		]
+	}
+	{"apiVersion": "v1", "kind": "ServiceAccount", "metadata": {"name": "c"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(objects) != 3 {
+		t.Fatalf("expected 3 objects but got %d", len(objects))
+	}
+
+	if _, ok := objects[0].(*serving.Route); !ok {
+		t.Errorf("expected a *serving.Route but got %T", objects[0])
+	}
+
+	if _, ok := objects[1].(*serving.Configuration); !ok {
+		t.Errorf("expected a *serving.Configuration but got %T", objects[1])
+	}
+
+	if _, ok := objects[2].(*corev1.ServiceAccount); !ok {
+		t.Errorf("expected a *corev1.ServiceAccount but got %T", objects[2])
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := knative.Decode(strings.NewReader("kind: [Service")); err == nil {
+		t.Error("expected an error decoding invalid yml")
+	}
+}
+
+func TestEditService(t *testing.T) {
+	objects, err := knative.Decode(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edits := knative.Edits{
+		Configuration: []knative.ConfigurationOption{
+			knative.WithImage("docker.io/foo/baz"),
+			knative.WithEnv("FOO", "bar"),
+			knative.WithEnv("BAZ", "qux"),
+		},
+		Build: []knative.BuildSpecOption{
+			knative.WithServiceAccount("other"),
+		},
+	}
+
+	for _, o := range objects {
+		edits.Apply(o)
+	}
+
+	config := objects[0].(*serving.Service).Spec.RunLatest.Configuration
+	errorIfNotEqual(t, config.RevisionTemplate.Spec.Container.Image, "docker.io/foo/baz", "expected image to be '%s' but was '%s'")
+	errorIfNotEqual(t, config.RevisionTemplate.Spec.Container.Env, []corev1.EnvVar{
+		{Name: "FOO", Value: "bar"},
+		{Name: "BAZ", Value: "qux"},
+	}, "expected env to be '%v' but was '%v'")
+	errorIfNotEqual(t, config.Build.ServiceAccountName, "other", "expected service build's service account to be '%s' but was '%s'")
+	errorIfNotEqual(t, objects[1].(*build.Build).Spec.ServiceAccountName, "other", "expected build's service account to be '%s' but was '%s'")
+}
+
+func TestEditIgnoresObjectsOptionsDontApplyTo(t *testing.T) {
+	edits := knative.Edits{
+		Configuration: []knative.ConfigurationOption{knative.WithImage("image")},
+	}
+
+	if edits.Apply(knative.NewBuild("foo")) {
+		t.Error("expected configuration options not to apply to a build")
+	}
+
+	if edits.Apply(map[string]interface{}{}) {
+		t.Error("expected configuration options not to apply to an unknown object")
+	}
+
+	if !edits.Apply(knative.NewConfiguration("foo")) {
+		t.Error("expected configuration options to apply to a configuration")
+	}
+}
+
+func TestEditRoute(t *testing.T) {
+	r := knative.NewRoute("foo", knative.WithTrafficToRevision("", "rev-1", 100))
+	knative.Edits{
+		Route: []knative.RouteOption{knative.WithTrafficToRevision("next", "rev-2", 0)},
+	}.Apply(r)
+
+	errorIfNotEqual(t, len(r.Spec.Traffic), 2, "expected %d traffic targets but got %d")
+}
+
+func TestKind(t *testing.T) {
+	errorIfNotEqual(t, knative.Kind(knative.NewRoute("foo")), "Route", "expected kind to be '%s' but was '%s'")
+	errorIfNotEqual(t, knative.Kind(map[string]interface{}{"kind": "Widget"}), "Widget", "expected kind to be '%s' but was '%s'")
+	errorIfNotEqual(t, knative.Kind("nope"), "", "expected kind to be '%s' but was '%s'")
+}
+
+func TestDecodeV1(t *testing.T) {
+	objects, err := knative.Decode(strings.NewReader(`apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: foo
+spec:
+  template:
+    spec:
+      containers:
+      - image: docker.io/foo/bar
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, ok := objects[0].(*v1.Service)
+	if !ok {
+		t.Fatalf("expected a *v1.Service but got %T", objects[0])
+	}
+
+	errorIfNotEqual(t, s.Spec.Template.Spec.Containers[0].Image, "docker.io/foo/bar", "expected image to be '%s' but was '%s'")
+}
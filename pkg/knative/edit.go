@@ -0,0 +1,63 @@
+package knative
+
+import (
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+)
+
+// Edits are options to apply to existing (e.g. decoded) objects
+type Edits struct {
+	// Configuration options apply to Configurations and runLatest or pinned Services
+	Configuration []ConfigurationOption
+
+	// Build options apply to Builds, and to the build of Configurations and
+	// Services which have one
+	Build []BuildSpecOption
+
+	// Route options apply to Routes
+	Route []RouteOption
+}
+
+// Apply applies the edits which make sense for o, which should be a pointer
+// to an object, and returns true if any were applied. Objects which none of
+// the edits apply to are left alone
+func (e Edits) Apply(o interface{}) bool {
+	switch o := o.(type) {
+	case *serving.Service:
+		if c := ServiceConfiguration(o); c != nil {
+			return e.configure(c)
+		}
+	case *serving.Configuration:
+		return e.configure(&o.Spec)
+	case *serving.Route:
+		for _, option := range e.Route {
+			option(&o.Spec)
+		}
+
+		return len(e.Route) > 0
+	case *build.Build:
+		for _, option := range e.Build {
+			option(&o.Spec)
+		}
+
+		return len(e.Build) > 0
+	}
+
+	return false
+}
+
+func (e Edits) configure(spec *serving.ConfigurationSpec) bool {
+	for _, option := range e.Configuration {
+		option(spec)
+	}
+
+	if spec.Build == nil {
+		return len(e.Configuration) > 0
+	}
+
+	for _, option := range e.Build {
+		option(spec.Build)
+	}
+
+	return len(e.Configuration) > 0 || len(e.Build) > 0
+}
@@ -31,6 +31,20 @@ func NewPinnedService(name, revisionName string, options ...ConfigurationOption)
 	return s
 }
 
+// ServiceConfiguration returns the ConfigurationSpec of a runLatest or pinned
+// Service, or nil if the Service is neither
+func ServiceConfiguration(s *serving.Service) *serving.ConfigurationSpec {
+	if s.Spec.RunLatest != nil {
+		return &s.Spec.RunLatest.Configuration
+	}
+
+	if s.Spec.Pinned != nil {
+		return &s.Spec.Pinned.Configuration
+	}
+
+	return nil
+}
+
 // NewConfiguration generates a new configuration with the given name and options
 func NewConfiguration(name string, options ...ConfigurationOption) *serving.Configuration {
 	c := &serving.Configuration{
@@ -78,6 +92,89 @@ func WithRevisionTemplate(image string, args []string, env map[string]string) Co
 	}
 }
 
+// WithImage sets the image of the RevisionTemplate, leaving its args and env alone
+func WithImage(image string) ConfigurationOption {
+	return func(t *serving.ConfigurationSpec) {
+		t.RevisionTemplate.Spec.Container.Image = image
+	}
+}
+
+// WithEnv sets an environment variable in the RevisionTemplate, replacing
+// any existing variable with the same name
+func WithEnv(name, value string) ConfigurationOption {
+	return WithEnvVar(corev1.EnvVar{Name: name, Value: value})
+}
+
+// WithEnvVar is like WithEnv, but allows the value to come from a secret,
+// config map or field
+func WithEnvVar(env corev1.EnvVar) ConfigurationOption {
+	return func(t *serving.ConfigurationSpec) {
+		container := &t.RevisionTemplate.Spec.Container
+		for i, e := range container.Env {
+			if e.Name == env.Name {
+				container.Env[i] = env
+				return
+			}
+		}
+
+		container.Env = append(container.Env, env)
+	}
+}
+
+// WithEnvFrom adds sources (e.g. config maps) to populate the RevisionTemplate's environment from
+func WithEnvFrom(sources ...corev1.EnvFromSource) ConfigurationOption {
+	return func(t *serving.ConfigurationSpec) {
+		t.RevisionTemplate.Spec.Container.EnvFrom = append(t.RevisionTemplate.Spec.Container.EnvFrom, sources...)
+	}
+}
+
+// WithCommand sets the command of the RevisionTemplate, overriding the image's entrypoint
+func WithCommand(command ...string) ConfigurationOption {
+	return func(t *serving.ConfigurationSpec) {
+		t.RevisionTemplate.Spec.Container.Command = command
+	}
+}
+
+// WithReadinessProbe sets the readiness probe of the RevisionTemplate. Probes
+// must not have a port, knative probes the port it serves the revision on
+func WithReadinessProbe(probe *corev1.Probe) ConfigurationOption {
+	return func(t *serving.ConfigurationSpec) {
+		t.RevisionTemplate.Spec.Container.ReadinessProbe = probe
+	}
+}
+
+// WithLivenessProbe sets the liveness probe of the RevisionTemplate, see WithReadinessProbe
+func WithLivenessProbe(probe *corev1.Probe) ConfigurationOption {
+	return func(t *serving.ConfigurationSpec) {
+		t.RevisionTemplate.Spec.Container.LivenessProbe = probe
+	}
+}
+
+// WithRevisionServiceAccount sets the service account revisions run as
+func WithRevisionServiceAccount(name string) ConfigurationOption {
+	return func(t *serving.ConfigurationSpec) {
+		t.RevisionTemplate.Spec.ServiceAccountName = name
+	}
+}
+
+// WithRevisionMetadata adds labels and annotations to the RevisionTemplate
+func WithRevisionMetadata(labels, annotations map[string]string) ConfigurationOption {
+	return func(t *serving.ConfigurationSpec) {
+		meta := &t.RevisionTemplate.ObjectMeta
+		for k, v := range labels {
+			if meta.Labels == nil {
+				meta.Labels = make(map[string]string)
+			}
+
+			meta.Labels[k] = v
+		}
+
+		for k, v := range annotations {
+			Annotate(meta, k, v)
+		}
+	}
+}
+
 // WithSingleConcurrency sets the RevisionRequestConcurrencyModel to Single
 func WithSingleConcurrency(s *serving.ConfigurationSpec) {
 	s.RevisionTemplate.Spec.ConcurrencyModel = serving.RevisionRequestConcurrencyModelSingle
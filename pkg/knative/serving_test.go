@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/julz/knightrider/pkg/knative"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func TestSimpleService(t *testing.T) {
@@ -35,3 +36,38 @@ func TestPinnedService(t *testing.T) {
 
 	errorIfNotEqual(t, s.Spec.Pinned.Configuration.Build.Template.Name, "buildpack", "expected service to have runLatest type with build template '%s' but was '%s'")
 }
+
+func TestServiceConfiguration(t *testing.T) {
+	runLatest := knative.NewRunLatestService("foo", knative.WithRevisionTemplate("image", nil, nil))
+	errorIfNotEqual(t, knative.ServiceConfiguration(runLatest), &runLatest.Spec.RunLatest.Configuration, "expected configuration to be '%v' but was '%v'")
+
+	pinned := knative.NewPinnedService("foo", "rev", knative.WithRevisionTemplate("image", nil, nil))
+	errorIfNotEqual(t, knative.ServiceConfiguration(pinned), &pinned.Spec.Pinned.Configuration, "expected configuration to be '%v' but was '%v'")
+}
+
+func TestContainerOptions(t *testing.T) {
+	probe := &corev1.Probe{Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz"}}}
+	c := knative.NewConfiguration("foo",
+		knative.WithRevisionTemplate("image", []string{"arg"}, nil),
+		knative.WithCommand("/bin/app"),
+		knative.WithEnv("FOO", "foo"),
+		knative.WithEnvVar(corev1.EnvVar{Name: "FOO", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}}),
+		knative.WithEnvFrom(corev1.EnvFromSource{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}}}),
+		knative.WithReadinessProbe(probe),
+		knative.WithLivenessProbe(probe),
+		knative.WithRevisionServiceAccount("runner"),
+		knative.WithRevisionMetadata(map[string]string{"app": "foo"}, map[string]string{"note": "hi"}),
+	)
+
+	container := c.Spec.RevisionTemplate.Spec.Container
+	errorIfNotEqual(t, container.Command, []string{"/bin/app"}, "expected command to be '%s' but was '%s'")
+	errorIfNotEqual(t, container.Args, []string{"arg"}, "expected args to be '%s' but was '%s'")
+	errorIfNotEqual(t, len(container.Env), 1, "expected %d env var but got %d")
+	errorIfNotEqual(t, container.Env[0].ValueFrom.FieldRef.FieldPath, "metadata.name", "expected env var to come from '%s' but was '%s'")
+	errorIfNotEqual(t, container.EnvFrom[0].ConfigMapRef.Name, "config", "expected env from config map '%s' but was '%s'")
+	errorIfNotEqual(t, container.ReadinessProbe, probe, "expected readiness probe to be '%v' but was '%v'")
+	errorIfNotEqual(t, container.LivenessProbe, probe, "expected liveness probe to be '%v' but was '%v'")
+	errorIfNotEqual(t, c.Spec.RevisionTemplate.Spec.ServiceAccountName, "runner", "expected service account to be '%s' but was '%s'")
+	errorIfNotEqual(t, c.Spec.RevisionTemplate.Labels, map[string]string{"app": "foo"}, "expected labels to be '%v' but was '%v'")
+	errorIfNotEqual(t, c.Spec.RevisionTemplate.Annotations, map[string]string{"note": "hi"}, "expected annotations to be '%v' but was '%v'")
+}
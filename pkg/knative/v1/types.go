@@ -0,0 +1,78 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The serving.knative.dev/v1 types aren't vendored, so these are the parts of
+// them (and of v1beta1, which has the same shape) needed to generate objects
+
+// Service manages a Configuration and a Route, like a v1alpha1 Service, but
+// with the revision template and traffic in its spec rather than in
+// runLatest, pinned etc.
+type Service struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceSpec `json:"spec,omitempty"`
+}
+
+// ServiceSpec is a ConfigurationSpec and a RouteSpec together
+type ServiceSpec struct {
+	ConfigurationSpec `json:",inline"`
+	RouteSpec         `json:",inline"`
+}
+
+// Configuration stamps out Revisions from its template
+type Configuration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ConfigurationSpec `json:"spec,omitempty"`
+}
+
+// ConfigurationSpec is the template Revisions are created from
+type ConfigurationSpec struct {
+	Template RevisionTemplateSpec `json:"template"`
+}
+
+// RevisionTemplateSpec is the metadata and spec of Revisions
+type RevisionTemplateSpec struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RevisionSpec `json:"spec,omitempty"`
+}
+
+// RevisionSpec is a pod spec, with a single container, plus how many
+// requests may be in the container at once
+type RevisionSpec struct {
+	corev1.PodSpec `json:",inline"`
+
+	// ContainerConcurrency is the maximum number of requests in the container
+	// at once, 0 means no limit
+	ContainerConcurrency int64 `json:"containerConcurrency,omitempty"`
+}
+
+// Route splits traffic between Revisions
+type Route struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RouteSpec `json:"spec,omitempty"`
+}
+
+// RouteSpec is how traffic is split
+type RouteSpec struct {
+	Traffic []TrafficTarget `json:"traffic,omitempty"`
+}
+
+// TrafficTarget sends a percentage of traffic to a Revision, or to the latest
+// Revision of a Configuration. Tagged targets are also reachable at their own url
+type TrafficTarget struct {
+	Tag               string `json:"tag,omitempty"`
+	RevisionName      string `json:"revisionName,omitempty"`
+	ConfigurationName string `json:"configurationName,omitempty"`
+	LatestRevision    *bool  `json:"latestRevision,omitempty"`
+	Percent           int    `json:"percent"`
+}
@@ -0,0 +1,128 @@
+package knative
+
+import (
+	"fmt"
+
+	"github.com/julz/knightrider/pkg/knative/v1"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Serving API versions objects can be generated for. The New* functions
+// generate V1Alpha1 objects, ForVersion converts them to the others
+const (
+	V1Alpha1 = "v1alpha1"
+	V1Beta1  = "v1beta1"
+	V1       = "v1"
+)
+
+// ForVersion returns a Service, Configuration or Route generated by this
+// package in the shape of the given serving api version, so the same options
+// can be used to generate objects for newer knative releases. Other objects
+// are returned unchanged
+func ForVersion(o interface{}, version string) (interface{}, error) {
+	switch version {
+	case V1Alpha1:
+		return o, nil
+	case V1Beta1, V1:
+	default:
+		return nil, fmt.Errorf("unknown serving api version %s, expected %s, %s or %s", version, V1Alpha1, V1Beta1, V1)
+	}
+
+	typeMeta := func(kind string) metav1.TypeMeta {
+		return metav1.TypeMeta{APIVersion: "serving.knative.dev/" + version, Kind: kind}
+	}
+
+	switch o := o.(type) {
+	case *serving.Service:
+		spec, err := serviceSpecV1(o)
+		if err != nil {
+			return nil, err
+		}
+
+		return &v1.Service{TypeMeta: typeMeta("Service"), ObjectMeta: o.ObjectMeta, Spec: spec}, nil
+	case *serving.Configuration:
+		spec, err := configurationSpecV1(&o.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("configuration %s: %s", o.Name, err)
+		}
+
+		return &v1.Configuration{TypeMeta: typeMeta("Configuration"), ObjectMeta: o.ObjectMeta, Spec: spec}, nil
+	case *serving.Route:
+		return &v1.Route{TypeMeta: typeMeta("Route"), ObjectMeta: o.ObjectMeta, Spec: routeSpecV1(&o.Spec)}, nil
+	}
+
+	return o, nil
+}
+
+// serviceSpecV1 converts a runLatest or pinned Service to a template which
+// sends all traffic to the latest revision, or to the pinned revision
+func serviceSpecV1(s *serving.Service) (v1.ServiceSpec, error) {
+	var spec v1.ServiceSpec
+	config := ServiceConfiguration(s)
+	if config == nil {
+		return spec, fmt.Errorf("service %s: only runLatest and pinned services can be converted", s.Name)
+	}
+
+	var err error
+	if spec.ConfigurationSpec, err = configurationSpecV1(config); err != nil {
+		return spec, fmt.Errorf("service %s: %s", s.Name, err)
+	}
+
+	latest := true
+	target := v1.TrafficTarget{LatestRevision: &latest, Percent: 100}
+	if s.Spec.Pinned != nil {
+		target = v1.TrafficTarget{RevisionName: s.Spec.Pinned.RevisionName, Percent: 100}
+	}
+
+	spec.Traffic = []v1.TrafficTarget{target}
+	return spec, nil
+}
+
+func configurationSpecV1(c *serving.ConfigurationSpec) (v1.ConfigurationSpec, error) {
+	if c.Build != nil {
+		return v1.ConfigurationSpec{}, fmt.Errorf("newer serving api versions cannot build images, generate the build separately")
+	}
+
+	rev := c.RevisionTemplate
+	spec := v1.ConfigurationSpec{
+		Template: v1.RevisionTemplateSpec{
+			ObjectMeta: rev.ObjectMeta,
+			Spec: v1.RevisionSpec{
+				PodSpec: corev1.PodSpec{
+					Containers:         []corev1.Container{rev.Spec.Container},
+					ServiceAccountName: rev.Spec.ServiceAccountName,
+				},
+			},
+		},
+	}
+
+	if rev.Spec.ConcurrencyModel == serving.RevisionRequestConcurrencyModelSingle {
+		spec.Template.Spec.ContainerConcurrency = 1
+	}
+
+	return spec, nil
+}
+
+// routeSpecV1 converts named traffic targets to tagged ones
+func routeSpecV1(r *serving.RouteSpec) v1.RouteSpec {
+	var spec v1.RouteSpec
+	for _, t := range r.Traffic {
+		target := v1.TrafficTarget{
+			Tag:               t.Name,
+			RevisionName:      t.RevisionName,
+			ConfigurationName: t.ConfigurationName,
+			Percent:           t.Percent,
+		}
+
+		if t.ConfigurationName != "" {
+			latest := true
+			target.LatestRevision = &latest
+		}
+
+		spec.Traffic = append(spec.Traffic, target)
+	}
+
+	return spec
+}
@@ -0,0 +1,151 @@
+package knative_test
+
+import (
+	"testing"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/knative/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// every ConfigurationOption, with what it should look like in a v1 revision template
+var configurationOptionTests = []struct {
+	name   string
+	option knative.ConfigurationOption
+	check  func(t *testing.T, template v1.RevisionTemplateSpec)
+}{
+	{"WithRevisionTemplate", knative.WithRevisionTemplate("image", []string{"arg"}, map[string]string{"K": "V"}), func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.Containers, []corev1.Container{{
+			Image: "image",
+			Args:  []string{"arg"},
+			Env:   []corev1.EnvVar{{Name: "K", Value: "V"}},
+		}}, "expected containers to be '%v' but were '%v'")
+	}},
+	{"WithImage", knative.WithImage("image"), func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.Containers[0].Image, "image", "expected image to be '%s' but was '%s'")
+	}},
+	{"WithEnv", knative.WithEnv("K", "V"), func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.Containers[0].Env, []corev1.EnvVar{{Name: "K", Value: "V"}}, "expected env to be '%v' but was '%v'")
+	}},
+	{"WithEnvVar", knative.WithEnvVar(corev1.EnvVar{Name: "K", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}}), func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.Containers[0].Env[0].ValueFrom.FieldRef.FieldPath, "metadata.name", "expected env from field '%s' but was '%s'")
+	}},
+	{"WithEnvFrom", knative.WithEnvFrom(corev1.EnvFromSource{Prefix: "P_"}), func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.Containers[0].EnvFrom, []corev1.EnvFromSource{{Prefix: "P_"}}, "expected env from to be '%v' but was '%v'")
+	}},
+	{"WithCommand", knative.WithCommand("/bin/app", "serve"), func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.Containers[0].Command, []string{"/bin/app", "serve"}, "expected command to be '%v' but was '%v'")
+	}},
+	{"WithReadinessProbe", knative.WithReadinessProbe(&corev1.Probe{PeriodSeconds: 3}), func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.Containers[0].ReadinessProbe, &corev1.Probe{PeriodSeconds: 3}, "expected readiness probe to be '%v' but was '%v'")
+	}},
+	{"WithLivenessProbe", knative.WithLivenessProbe(&corev1.Probe{PeriodSeconds: 3}), func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.Containers[0].LivenessProbe, &corev1.Probe{PeriodSeconds: 3}, "expected liveness probe to be '%v' but was '%v'")
+	}},
+	{"WithRevisionServiceAccount", knative.WithRevisionServiceAccount("runner"), func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.ServiceAccountName, "runner", "expected service account to be '%s' but was '%s'")
+	}},
+	{"WithRevisionMetadata", knative.WithRevisionMetadata(map[string]string{"l": "1"}, map[string]string{"a": "2"}), func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Labels, map[string]string{"l": "1"}, "expected labels to be '%v' but were '%v'")
+		errorIfNotEqual(t, template.Annotations, map[string]string{"a": "2"}, "expected annotations to be '%v' but were '%v'")
+	}},
+	{"WithSingleConcurrency", knative.WithSingleConcurrency, func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.ContainerConcurrency, int64(1), "expected container concurrency to be %d but was %d")
+	}},
+	{"WithMultiConcurrency", knative.WithMultiConcurrency, func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.ContainerConcurrency, int64(0), "expected container concurrency to be %d but was %d")
+	}},
+	{"WithImagePullPolicyAlways", knative.WithImagePullPolicyAlways, func(t *testing.T, template v1.RevisionTemplateSpec) {
+		errorIfNotEqual(t, template.Spec.Containers[0].ImagePullPolicy, corev1.PullAlways, "expected image pull policy to be '%s' but was '%s'")
+	}},
+}
+
+func TestConfigurationOptionsForV1(t *testing.T) {
+	for _, test := range configurationOptionTests {
+		for _, version := range []string{knative.V1Beta1, knative.V1} {
+			o, err := knative.ForVersion(knative.NewRunLatestService("foo", test.option), version)
+			if err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+
+			s := o.(*v1.Service)
+			errorIfNotEqual(t, s.APIVersion, "serving.knative.dev/"+version, "expected api version to be '%s' but was '%s'")
+			errorIfNotEqual(t, len(s.Spec.Template.Spec.Containers), 1, test.name+": expected %d container but got %d")
+			test.check(t, s.Spec.Template)
+
+			o, err = knative.ForVersion(knative.NewConfiguration("foo", test.option), version)
+			if err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+
+			test.check(t, o.(*v1.Configuration).Spec.Template)
+		}
+	}
+}
+
+func TestBuildCannotBeConvertedToV1(t *testing.T) {
+	if _, err := knative.ForVersion(knative.NewRunLatestService("foo", knative.WithBuild()), knative.V1); err == nil {
+		t.Error("expected an error converting a service with a build to v1")
+	}
+
+	if _, err := knative.ForVersion(knative.NewConfiguration("foo", knative.WithBuild()), knative.V1); err == nil {
+		t.Error("expected an error converting a configuration with a build to v1")
+	}
+}
+
+func TestServiceTrafficForV1(t *testing.T) {
+	latest := true
+
+	o, err := knative.ForVersion(knative.NewRunLatestService("foo", knative.WithImage("image")), knative.V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, o.(*v1.Service).Spec.Traffic, []v1.TrafficTarget{{LatestRevision: &latest, Percent: 100}}, "expected runLatest traffic to be '%v' but was '%v'")
+
+	o, err = knative.ForVersion(knative.NewPinnedService("foo", "foo-00001", knative.WithImage("image")), knative.V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, o.(*v1.Service).Spec.Traffic, []v1.TrafficTarget{{RevisionName: "foo-00001", Percent: 100}}, "expected pinned traffic to be '%v' but was '%v'")
+}
+
+// every RouteOption
+func TestRouteOptionsForV1(t *testing.T) {
+	latest := true
+
+	o, err := knative.ForVersion(knative.NewRoute("foo",
+		knative.WithTrafficToRevision("current", "foo-00001", 90),
+		knative.WithTrafficToConfiguration("next", "foo", 10),
+		knative.WithTrafficToRevision("", "foo-00002", 0),
+	), knative.V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := o.(*v1.Route)
+	errorIfNotEqual(t, r.Kind, "Route", "expected kind to be '%s' but was '%s'")
+	errorIfNotEqual(t, r.Name, "foo", "expected name to be '%s' but was '%s'")
+	errorIfNotEqual(t, r.Spec.Traffic, []v1.TrafficTarget{
+		{Tag: "current", RevisionName: "foo-00001", Percent: 90},
+		{Tag: "next", ConfigurationName: "foo", LatestRevision: &latest, Percent: 10},
+		{RevisionName: "foo-00002", Percent: 0},
+	}, "expected traffic to be '%v' but was '%v'")
+}
+
+func TestForVersion(t *testing.T) {
+	s := knative.NewRunLatestService("foo")
+	if o, err := knative.ForVersion(s, knative.V1Alpha1); err != nil || o != s {
+		t.Errorf("expected v1alpha1 objects to be returned unchanged, got %v, %v", o, err)
+	}
+
+	b := knative.NewBuild("foo")
+	if o, err := knative.ForVersion(b, knative.V1); err != nil || o != b {
+		t.Errorf("expected non-serving objects to be returned unchanged, got %v, %v", o, err)
+	}
+
+	if _, err := knative.ForVersion(s, "v2"); err == nil {
+		t.Error("expected an error for an unknown version")
+	}
+}
@@ -0,0 +1,77 @@
+package kube
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Resource names for the kinds of object knightrider works with. Knative kinds
+// are fully qualified so that, for example, knative Services are not confused
+// with kubernetes Services
+const (
+	Services        = "services.serving.knative.dev"
+	Configurations  = "configurations.serving.knative.dev"
+	Revisions       = "revisions.serving.knative.dev"
+	Routes          = "routes.serving.knative.dev"
+	Builds          = "builds.build.knative.dev"
+	BuildTemplates  = "buildtemplates.build.knative.dev"
+	Pods            = "pods"
+	Events          = "events"
+	Secrets         = "secrets"
+	ServiceAccounts = "serviceaccounts"
+	ConfigMaps      = "configmaps"
+
+	PersistentVolumeClaims = "persistentvolumeclaims"
+)
+
+// Client is the set of cluster operations knightrider needs. Objects are
+// exchanged as the typed knative and kubernetes structs, e.g. Get(Services,
+// "foo", &serving.Service{}) or List(Revisions, "", &serving.RevisionList{})
+type Client interface {
+	// Get fetches the named object of the given resource in to into
+	Get(resource, name string, into interface{}) error
+
+	// List fetches the objects of the given resource which match the label
+	// selector (which may be empty) in to into, which should be a List type
+	List(resource, selector string, into interface{}) error
+
+	// Apply creates or updates each of the given objects
+	Apply(objects ...interface{}) error
+
+	// Delete deletes the named object of the given resource
+	Delete(resource, name string) error
+
+	// Logs returns the logs of a container in a pod. If follow is true the
+	// logs are streamed until the container exits or the reader is closed
+	Logs(pod, container string, follow bool) (io.ReadCloser, error)
+}
+
+// NotFoundError is returned by a Client when an object does not exist
+type NotFoundError struct {
+	Resource string
+	Name     string
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("%s %q not found", e.Resource, e.Name)
+}
+
+// IsNotFound returns true if err is a NotFoundError
+func IsNotFound(err error) bool {
+	_, ok := err.(NotFoundError)
+	return ok
+}
+
+// ResourceFor returns the resource name for objects with the given apiVersion
+// and kind, e.g. services.serving.knative.dev for a serving.knative.dev/v1alpha1 Service
+func ResourceFor(apiVersion, kind string) string {
+	plural := strings.ToLower(kind) + "s"
+
+	parts := strings.SplitN(apiVersion, "/", 2)
+	if len(parts) == 1 {
+		return plural
+	}
+
+	return plural + "." + parts[0]
+}
@@ -0,0 +1,217 @@
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Fake is an in-memory Client for use in tests. Objects must have their
+// TypeMeta set so that the Fake can tell which resource they belong to
+type Fake struct {
+	mu      sync.Mutex
+	objects []fakeObject
+	logs    map[string]string
+
+	// Applied records every object passed to Apply, in order
+	Applied []interface{}
+
+	// Deleted records every resource/name passed to Delete, in order
+	Deleted []string
+}
+
+type fakeObject struct {
+	resource string
+	name     string
+	labels   labels.Set
+	raw      []byte
+}
+
+// NewFake returns a Fake containing the given objects
+func NewFake(objects ...interface{}) *Fake {
+	f := &Fake{}
+	f.Add(objects...)
+	return f
+}
+
+// Add adds or replaces objects in the Fake without recording them as Applied
+func (f *Fake) Add(objects ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, o := range objects {
+		if err := f.put(o, false); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Get fetches the named object of the given resource in to into
+func (f *Fake) Get(resource, name string, into interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, o := range f.objects {
+		if o.resource == resource && o.name == name {
+			return json.Unmarshal(o.raw, into)
+		}
+	}
+
+	return NotFoundError{Resource: resource, Name: name}
+}
+
+// List fetches the objects of the given resource which match the label selector
+func (f *Fake) List(resource, selector string, into interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return err
+	}
+
+	items := []json.RawMessage{}
+	for _, o := range f.objects {
+		if o.resource == resource && sel.Matches(o.labels) {
+			items = append(items, o.raw)
+		}
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"items": items})
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, into)
+}
+
+// Apply records and stores each of the given objects. As with a real cluster,
+// the status of any existing object is preserved
+func (f *Fake) Apply(objects ...interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, o := range objects {
+		if err := f.put(o, true); err != nil {
+			return err
+		}
+
+		f.Applied = append(f.Applied, o)
+	}
+
+	return nil
+}
+
+// Delete records and removes the named object of the given resource
+func (f *Fake) Delete(resource, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, o := range f.objects {
+		if o.resource == resource && o.name == name {
+			f.objects = append(f.objects[:i], f.objects[i+1:]...)
+			f.Deleted = append(f.Deleted, resource+"/"+name)
+			return nil
+		}
+	}
+
+	return NotFoundError{Resource: resource, Name: name}
+}
+
+// SetLogs sets the logs returned for a container in a pod
+func (f *Fake) SetLogs(pod, container, logs string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.logs == nil {
+		f.logs = make(map[string]string)
+	}
+
+	f.logs[pod+"/"+container] = logs
+}
+
+// Logs returns the logs set by SetLogs for a container in a pod. The logs are
+// returned in full whether or not follow is true
+func (f *Fake) Logs(pod, container string, follow bool) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	logs, ok := f.logs[pod+"/"+container]
+	if !ok {
+		return nil, fmt.Errorf("fake: no logs for container %s in pod %s", container, pod)
+	}
+
+	return ioutil.NopCloser(strings.NewReader(logs)), nil
+}
+
+func (f *Fake) put(o interface{}, preserveStatus bool) error {
+	raw, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+
+	var meta struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return err
+	}
+
+	if meta.Kind == "" {
+		return fmt.Errorf("fake: object %q has no kind", meta.Metadata.Name)
+	}
+
+	obj := fakeObject{
+		resource: ResourceFor(meta.APIVersion, meta.Kind),
+		name:     meta.Metadata.Name,
+		labels:   labels.Set(meta.Metadata.Labels),
+		raw:      raw,
+	}
+
+	for i, existing := range f.objects {
+		if existing.resource == obj.resource && existing.name == obj.name {
+			if preserveStatus {
+				if obj.raw, err = withStatusOf(obj.raw, existing.raw); err != nil {
+					return err
+				}
+			}
+
+			f.objects[i] = obj
+			return nil
+		}
+	}
+
+	f.objects = append(f.objects, obj)
+	return nil
+}
+
+// withStatusOf returns raw with its status replaced by the status of existing
+func withStatusOf(raw, existing []byte) ([]byte, error) {
+	var o, e map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(existing, &e); err != nil {
+		return nil, err
+	}
+
+	if status, ok := e["status"]; ok {
+		o["status"] = status
+	} else {
+		delete(o, "status")
+	}
+
+	return json.Marshal(o)
+}
@@ -0,0 +1,101 @@
+package kube_test
+
+import (
+	"testing"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourceFor(t *testing.T) {
+	for _, tc := range []struct{ apiVersion, kind, resource string }{
+		{"serving.knative.dev/v1alpha1", "Service", kube.Services},
+		{"serving.knative.dev/v1alpha1", "Revision", kube.Revisions},
+		{"build.knative.dev/v1alpha1", "BuildTemplate", kube.BuildTemplates},
+		{"v1", "ServiceAccount", kube.ServiceAccounts},
+		{"v1", "Pod", kube.Pods},
+	} {
+		if r := kube.ResourceFor(tc.apiVersion, tc.kind); r != tc.resource {
+			t.Errorf("expected resource for %s %s to be %s but was %s", tc.apiVersion, tc.kind, tc.resource, r)
+		}
+	}
+}
+
+func TestFakeGet(t *testing.T) {
+	f := kube.NewFake(knative.NewRunLatestService("foo"), knative.NewSecret("foo"))
+
+	var s serving.Service
+	if err := f.Get(kube.Services, "foo", &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Kind != "Service" || s.Spec.RunLatest == nil {
+		t.Errorf("expected to get back the runLatest service but got %#v", s)
+	}
+
+	if err := f.Get(kube.Services, "bar", &s); !kube.IsNotFound(err) {
+		t.Errorf("expected a not found error but got %v", err)
+	}
+}
+
+func TestFakeListWithSelector(t *testing.T) {
+	f := kube.NewFake(pod("a", "x"), pod("b", "y"), pod("c", "x"))
+
+	var pods corev1.PodList
+	if err := f.List(kube.Pods, "app=x", &pods); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pods.Items) != 2 || pods.Items[0].Name != "a" || pods.Items[1].Name != "c" {
+		t.Errorf("expected pods a and c but got %#v", pods.Items)
+	}
+}
+
+func TestFakeApplyAndDelete(t *testing.T) {
+	f := kube.NewFake()
+
+	if err := f.Apply(knative.NewRoute("r")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.Applied) != 1 {
+		t.Errorf("expected the route to be recorded as applied")
+	}
+
+	if err := f.Delete(kube.Routes, "r"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Delete(kube.Routes, "r"); !kube.IsNotFound(err) {
+		t.Errorf("expected deleting twice to return not found but got %v", err)
+	}
+}
+
+func TestFakeApplyPreservesStatus(t *testing.T) {
+	existing := knative.NewRoute("r")
+	existing.Status.Domain = "r.example.com"
+	f := kube.NewFake(existing)
+
+	if err := f.Apply(knative.NewRoute("r", knative.WithTrafficToRevision("", "rev", 100))); err != nil {
+		t.Fatal(err)
+	}
+
+	var r serving.Route
+	if err := f.Get(kube.Routes, "r", &r); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Status.Domain != "r.example.com" || len(r.Spec.Traffic) != 1 {
+		t.Errorf("expected the new spec with the existing status but got %#v", r)
+	}
+}
+
+func pod(name, app string) *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"app": app}},
+	}
+}
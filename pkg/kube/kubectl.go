@@ -0,0 +1,173 @@
+package kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Kubectl is a Client which shells out to kubectl
+type Kubectl struct {
+	// Namespace is the namespace to use, or empty to use kubectl's current namespace
+	Namespace string
+}
+
+// Get fetches the named object of the given resource in to into
+func (k Kubectl) Get(resource, name string, into interface{}) error {
+	out, err := k.run(nil, "get", resource, name, "-o", "json")
+	if err != nil {
+		if strings.Contains(err.Error(), "NotFound") {
+			return NotFoundError{Resource: resource, Name: name}
+		}
+
+		return err
+	}
+
+	return json.Unmarshal(out, into)
+}
+
+// List fetches the objects of the given resource which match the label selector
+func (k Kubectl) List(resource, selector string, into interface{}) error {
+	args := []string{"get", resource, "-o", "json"}
+	if selector != "" {
+		args = append(args, "-l", selector)
+	}
+
+	out, err := k.run(nil, args...)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(out, into)
+}
+
+// Apply creates or updates each of the given objects with 'kubectl apply'
+func (k Kubectl) Apply(objects ...interface{}) error {
+	list := struct {
+		APIVersion string        `json:"apiVersion"`
+		Kind       string        `json:"kind"`
+		Items      []interface{} `json:"items"`
+	}{"v1", "List", objects}
+
+	b, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	_, err = k.run(bytes.NewReader(b), "apply", "-f", "-")
+	return err
+}
+
+// Delete deletes the named object of the given resource
+func (k Kubectl) Delete(resource, name string) error {
+	_, err := k.run(nil, "delete", resource, name)
+	if err != nil && strings.Contains(err.Error(), "NotFound") {
+		return NotFoundError{Resource: resource, Name: name}
+	}
+
+	return err
+}
+
+// Logs returns the logs of a container in a pod using 'kubectl logs'. If
+// kubectl fails, for example because the pod doesn't exist, reading the logs
+// returns its error once its output runs out
+func (k Kubectl) Logs(pod, container string, follow bool) (io.ReadCloser, error) {
+	args := []string{"logs", pod, "-c", container}
+	if follow {
+		args = append(args, "-f")
+	}
+
+	l := &logReader{cmd: k.command(args...)}
+	l.cmd.Stderr = &l.stderr
+
+	out, err := l.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	l.ReadCloser = out
+	return l, nil
+}
+
+// logReader reaps the kubectl process when its output ends, returning its
+// error in place of io.EOF, and kills it if it's closed before then
+type logReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+	done   bool
+	err    error
+}
+
+func (l *logReader) Read(p []byte) (int, error) {
+	n, err := l.ReadCloser.Read(p)
+	if err == io.EOF {
+		if waitErr := l.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
+
+func (l *logReader) Close() error {
+	if l.done {
+		return l.err
+	}
+
+	// kubectl was stopped early on purpose, so how it exits doesn't matter
+	l.cmd.Process.Kill()
+	l.ReadCloser.Close()
+	l.cmd.Wait()
+	l.done = true
+	return nil
+}
+
+func (l *logReader) wait() error {
+	if l.done {
+		return l.err
+	}
+
+	l.done = true
+	if err := l.cmd.Wait(); err != nil {
+		msg := strings.TrimSpace(l.stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+
+		l.err = fmt.Errorf("%s: %s", strings.Join(l.cmd.Args, " "), msg)
+	}
+
+	return l.err
+}
+
+func (k Kubectl) command(args ...string) *exec.Cmd {
+	if k.Namespace != "" {
+		args = append(args, "-n", k.Namespace)
+	}
+
+	return exec.Command("kubectl", args...)
+}
+
+func (k Kubectl) run(stdin io.Reader, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := k.command(args...)
+	cmd.Stderr = &stderr
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", strings.Join(cmd.Args, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
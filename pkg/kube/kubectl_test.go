@@ -0,0 +1,66 @@
+package kube_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/kube"
+)
+
+func TestKubectlLogs(t *testing.T) {
+	defer fakeKubectl(t, `echo "line 1"; echo 'Error from server (NotFound): pods "bad" not found' >&2; exit 1`)()
+
+	r, err := kube.Kubectl{}.Logs("bad", "user-container", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadAll(r)
+	if err == nil || !strings.Contains(err.Error(), `pods "bad" not found`) {
+		t.Errorf("expected reading the logs to fail with kubectl's error, but got %v", err)
+	}
+
+	if string(out) != "line 1\n" {
+		t.Errorf("expected the logs before the failure, 'line 1', but got '%s'", out)
+	}
+
+	if err := r.Close(); err == nil {
+		t.Error("expected close to return kubectl's error")
+	}
+}
+
+func TestKubectlLogsClosedEarly(t *testing.T) {
+	defer fakeKubectl(t, `echo "line 1"; exec sleep 10`)()
+
+	r, err := kube.Kubectl{}.Logs("pod", "user-container", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Errorf("expected closing the logs early to succeed, but got %s", err)
+	}
+}
+
+// fakeKubectl puts a kubectl which runs script first in the PATH, and returns
+// a func to put things back
+func fakeKubectl(t *testing.T, script string) func() {
+	dir, err := ioutil.TempDir("", "kubectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "kubectl"), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
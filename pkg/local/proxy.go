@@ -0,0 +1,33 @@
+package local
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+)
+
+// NewProxy returns a handler which proxies requests to target, emulating the
+// queue proxy Knative places in front of every revision. With the Single
+// concurrency model only one request is forwarded at a time and the rest queue
+// until it completes
+func NewProxy(target *url.URL, model serving.RevisionRequestConcurrencyModelType) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	if model != serving.RevisionRequestConcurrencyModelSingle {
+		return proxy
+	}
+
+	slot := make(chan struct{}, 1)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slot <- struct{}{}:
+		case <-r.Context().Done():
+			http.Error(w, "request cancelled while queued", http.StatusServiceUnavailable)
+			return
+		}
+
+		defer func() { <-slot }()
+		proxy.ServeHTTP(w, r)
+	})
+}
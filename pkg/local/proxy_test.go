@@ -0,0 +1,65 @@
+package local_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/julz/knightrider/pkg/local"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+)
+
+func TestProxySingleConcurrency(t *testing.T) {
+	if max := maxConcurrency(t, serving.RevisionRequestConcurrencyModelSingle); max != 1 {
+		t.Errorf("expected at most 1 concurrent request but saw %d", max)
+	}
+}
+
+func TestProxyMultiConcurrency(t *testing.T) {
+	if max := maxConcurrency(t, serving.RevisionRequestConcurrencyModelMulti); max < 2 {
+		t.Errorf("expected requests to run concurrently but saw at most %d", max)
+	}
+}
+
+func maxConcurrency(t *testing.T, model serving.RevisionRequestConcurrencyModelType) int32 {
+	var inflight, max int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer backend.Close()
+
+	target, _ := url.Parse(backend.URL)
+	proxy := httptest.NewServer(local.NewProxy(target, model))
+	defer proxy.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(proxy.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+
+	wg.Wait()
+	return atomic.LoadInt32(&max)
+}
@@ -0,0 +1,113 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Names are the names Knative would give the objects backing a running container
+type Names struct {
+	Service       string
+	Configuration string
+	Revision      string
+}
+
+// NamesFor returns the Names Knative would use for a Configuration with the
+// given name, optionally owned by a Service
+func NamesFor(service, configuration string) Names {
+	return Names{
+		Service:       service,
+		Configuration: configuration,
+		Revision:      configuration + "-local",
+	}
+}
+
+// Env returns the environment for the container, including the variables Knative
+// injects in to every user container
+func Env(container corev1.Container, names Names, port int) []string {
+	var env []string
+	for _, e := range container.Env {
+		env = append(env, e.Name+"="+e.Value)
+	}
+
+	env = append(env, fmt.Sprintf("PORT=%d", port))
+	env = append(env, "K_CONFIGURATION="+names.Configuration)
+	env = append(env, "K_REVISION="+names.Revision)
+	if names.Service != "" {
+		env = append(env, "K_SERVICE="+names.Service)
+	}
+
+	return env
+}
+
+// Command returns a command which will run the container's image locally. If the
+// image is a path to a local binary, the binary is run directly, otherwise if it
+// looks like a Go import path (as used by ko) it is run via `go run`
+func Command(container corev1.Container) (*exec.Cmd, error) {
+	image := container.Image
+	if image == "" {
+		return nil, fmt.Errorf("container has no image")
+	}
+
+	if info, err := os.Stat(image); err == nil && !info.IsDir() {
+		// exec looks bare names like app up in the PATH, rather than in the
+		// working directory
+		path, err := filepath.Abs(image)
+		if err != nil {
+			return nil, err
+		}
+
+		return exec.Command(path, append(container.Command, container.Args...)...), nil
+	}
+
+	if !isImportPath(image) {
+		return nil, fmt.Errorf("image %q is neither a local binary nor a go import path", image)
+	}
+
+	if len(container.Command) > 0 {
+		return nil, fmt.Errorf("container command cannot be used with a go import path image")
+	}
+
+	return exec.Command("go", append([]string{"run", image}, container.Args...)...), nil
+}
+
+// ConcurrencyModel returns the concurrency model of a RevisionSpec, applying the
+// same default as Knative
+func ConcurrencyModel(spec serving.RevisionSpec) serving.RevisionRequestConcurrencyModelType {
+	if spec.ConcurrencyModel == "" {
+		return serving.RevisionRequestConcurrencyModelMulti
+	}
+
+	return spec.ConcurrencyModel
+}
+
+// registries are well-known docker registry hosts which are never go import paths
+var registries = []string{"docker.io", "index.docker.io", "gcr.io", "quay.io"}
+
+// isImportPath returns true if image looks like a go import path rather than a
+// docker image reference, i.e. it has no tag or digest and its first element is
+// a domain name which isn't a well-known registry
+func isImportPath(image string) bool {
+	if strings.ContainsAny(image, ":@") {
+		return false
+	}
+
+	parts := strings.Split(image, "/")
+	if len(parts) < 2 || !strings.Contains(parts[0], ".") {
+		return false
+	}
+
+	for _, r := range registries {
+		if parts[0] == r || strings.HasSuffix(parts[0], "."+r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,106 @@
+package local_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/local"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestEnv(t *testing.T) {
+	env := local.Env(corev1.Container{
+		Env: []corev1.EnvVar{{Name: "FOO", Value: "bar"}},
+	}, local.NamesFor("svc", "svc"), 1234)
+
+	errorIfNotEqual(t, env, []string{
+		"FOO=bar",
+		"PORT=1234",
+		"K_CONFIGURATION=svc",
+		"K_REVISION=svc-local",
+		"K_SERVICE=svc",
+	}, "expected env to be '%s' but was '%s'")
+}
+
+func TestEnvWithoutService(t *testing.T) {
+	env := local.Env(corev1.Container{}, local.NamesFor("", "config"), 8080)
+
+	errorIfNotEqual(t, env, []string{
+		"PORT=8080",
+		"K_CONFIGURATION=config",
+		"K_REVISION=config-local",
+	}, "expected env to be '%s' but was '%s'")
+}
+
+func TestCommandWithImportPath(t *testing.T) {
+	cmd, err := local.Command(corev1.Container{
+		Image: "github.com/julz/knightrider/test/cmd/hello-world",
+		Args:  []string{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, cmd.Args, []string{"go", "run", "github.com/julz/knightrider/test/cmd/hello-world", "a", "b"}, "expected command to be '%s' but was '%s'")
+}
+
+func TestCommandWithLocalBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "run-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "app"), []byte("#!/bin/sh\necho \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// a bare name, which exec would otherwise look for in the PATH
+	cmd, err := local.Command(corev1.Container{
+		Image:   "app",
+		Command: []string{"x"},
+		Args:    []string{"y"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, string(out), "x y\n", "expected the binary to output '%s' but it output '%s'")
+}
+
+func TestCommandWithDockerImage(t *testing.T) {
+	for _, image := range []string{"busybox", "docker.io/busybox", "gcr.io/foo/bar", "github.com/foo/bar:latest"} {
+		if _, err := local.Command(corev1.Container{Image: image}); err == nil {
+			t.Errorf("expected image %s to be rejected", image)
+		}
+	}
+}
+
+func TestConcurrencyModelDefaultsToMulti(t *testing.T) {
+	errorIfNotEqual(t, local.ConcurrencyModel(serving.RevisionSpec{}), serving.RevisionRequestConcurrencyModelMulti, "expected default concurrency model to be '%s' but was '%s'")
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
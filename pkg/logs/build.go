@@ -0,0 +1,108 @@
+package logs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/julz/knightrider/pkg/builds"
+	"github.com/julz/knightrider/pkg/kube"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Build streams the logs of each step of the named Build in order, prefixing
+// each line with the step's name, until the Build's Succeeded condition is True
+// or False. Steps are the init containers of the build's pod, so as well as the
+// Build's own steps this includes the steps of its template and the steps
+// which fetch its source. It returns the final Succeeded condition
+func (s *Streamer) Build(name string) (*build.BuildCondition, error) {
+	var podName string
+	for {
+		b, err := s.getBuild(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if b.Status.Cluster != nil && b.Status.Cluster.PodName != "" {
+			podName = b.Status.Cluster.PodName
+			break
+		}
+
+		if done := builds.Completed(b); done != nil {
+			return done, nil
+		}
+
+		s.poll()
+	}
+
+	var pod corev1.Pod
+	if err := s.Client.Get(kube.Pods, podName, &pod); err != nil {
+		return nil, err
+	}
+
+	for i, c := range pod.Spec.InitContainers {
+		started, err := s.waitForStart(name, pod.Name, i)
+		if err != nil {
+			return nil, err
+		}
+
+		if !started {
+			break
+		}
+
+		if err := s.stream(pod.Name, c.Name, strings.TrimPrefix(c.Name, builds.StepPrefix), true); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		b, err := s.getBuild(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if done := builds.Completed(b); done != nil {
+			return done, nil
+		}
+
+		s.poll()
+	}
+}
+
+// waitForStart waits for the i'th init container of the pod to start, returning
+// false if the build completes without it ever starting
+func (s *Streamer) waitForStart(buildName, podName string, i int) (bool, error) {
+	for {
+		var pod corev1.Pod
+		if err := s.Client.Get(kube.Pods, podName, &pod); err != nil {
+			return false, err
+		}
+
+		if i < len(pod.Status.InitContainerStatuses) {
+			state := pod.Status.InitContainerStatuses[i].State
+			if state.Running != nil || state.Terminated != nil {
+				return true, nil
+			}
+		}
+
+		b, err := s.getBuild(buildName)
+		if err != nil {
+			return false, err
+		}
+
+		if builds.Completed(b) != nil {
+			return false, nil
+		}
+
+		s.poll()
+	}
+}
+
+func (s *Streamer) getBuild(name string) (*build.Build, error) {
+	var b build.Build
+	if err := s.Client.Get(kube.Builds, name, &b); err != nil {
+		return nil, fmt.Errorf("could not get build %s: %s", name, err)
+	}
+
+	return &b, nil
+}
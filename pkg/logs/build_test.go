@@ -0,0 +1,69 @@
+package logs_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	"github.com/julz/knightrider/pkg/logs"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildLogs(t *testing.T) {
+	b := knative.NewBuild("my-build")
+	b.Status.Cluster = &build.ClusterSpec{PodName: "my-build-pod"}
+	b.Status.Conditions = []build.BuildCondition{{Type: build.BuildSucceeded, Status: corev1.ConditionFalse, Reason: "BuildFailed"}}
+
+	pod := &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "my-build-pod"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "build-step-git-source"}, {Name: "build-step-compile"}, {Name: "build-step-push"}},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+				{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}},
+				{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}},
+			},
+		},
+	}
+
+	c := kube.NewFake(b, pod)
+	c.SetLogs("my-build-pod", "build-step-git-source", "cloning\ncloned\n")
+	c.SetLogs("my-build-pod", "build-step-compile", "compiling\nerror!\n")
+
+	var out bytes.Buffer
+	s := &logs.Streamer{Client: c, Out: &out, Sleep: func(_ time.Duration) {}}
+	cond, err := s.Build("my-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, out.String(), "[git-source] cloning\n[git-source] cloned\n[compile] compiling\n[compile] error!\n", "expected logs to be '%s' but were '%s'")
+	errorIfNotEqual(t, cond.Reason, "BuildFailed", "expected build to finish with reason '%s' but was '%s'")
+}
+
+func TestBuildLogsWithoutPod(t *testing.T) {
+	b := knative.NewBuild("my-build")
+	b.Status.Conditions = []build.BuildCondition{{Type: build.BuildSucceeded, Status: corev1.ConditionFalse, Reason: "Invalid"}}
+
+	s := &logs.Streamer{Client: kube.NewFake(b), Out: &bytes.Buffer{}}
+	cond, err := s.Build("my-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, cond.Reason, "Invalid", "expected build to finish with reason '%s' but was '%s'")
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
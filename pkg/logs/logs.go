@@ -0,0 +1,59 @@
+package logs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/julz/knightrider/pkg/kube"
+)
+
+// Streamer streams the logs of Builds and Revisions from a cluster
+type Streamer struct {
+	Client kube.Client
+
+	// Out receives the logs, with each line prefixed by the step or pod it came from
+	Out io.Writer
+
+	// PollInterval is how often to check for new containers and pods, defaults to 2 seconds
+	PollInterval time.Duration
+
+	// Sleep defaults to time.Sleep, and can be replaced in tests
+	Sleep func(time.Duration)
+
+	mu sync.Mutex
+}
+
+// stream copies the logs of a container to Out, prefixing each line
+func (s *Streamer) stream(pod, container, prefix string, follow bool) error {
+	r, err := s.Client.Logs(pod, container, follow)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s.mu.Lock()
+		fmt.Fprintf(s.Out, "[%s] %s\n", prefix, scanner.Text())
+		s.mu.Unlock()
+	}
+
+	return scanner.Err()
+}
+
+func (s *Streamer) poll() {
+	interval := s.PollInterval
+	if interval == 0 {
+		interval = 2 * time.Second
+	}
+
+	if s.Sleep == nil {
+		time.Sleep(interval)
+		return
+	}
+
+	s.Sleep(interval)
+}
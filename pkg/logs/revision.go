@@ -0,0 +1,101 @@
+package logs
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/julz/knightrider/pkg/kube"
+	knativeserving "github.com/knative/serving/pkg/apis/serving"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// UserContainer is the name knative serving gives the container running the
+// user's image in each pod of a Revision
+const UserContainer = "user-container"
+
+// Revision streams the user-container logs of every pod of the named Revision,
+// prefixing each line with the pod's name. If follow is true, logs are streamed
+// as they are written and new pods are picked up as they start, until stop is
+// closed or the logs of a pod can't be streamed
+func (s *Streamer) Revision(name string, follow bool, stop <-chan struct{}) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, 1)
+	seen := make(map[string]bool)
+
+	for {
+		var pods corev1.PodList
+		if err := s.Client.List(kube.Pods, fmt.Sprintf("%s=%s", knativeserving.RevisionLabelKey, name), &pods); err != nil {
+			return err
+		}
+
+		for _, p := range pods.Items {
+			if seen[p.Name] || !started(p) {
+				continue
+			}
+
+			seen[p.Name] = true
+			wg.Add(1)
+			go func(pod string) {
+				defer wg.Done()
+				if err := s.stream(pod, UserContainer, pod, follow); err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+				}
+			}(p.Name)
+		}
+
+		if !follow {
+			break
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case err := <-errs:
+			return err
+		default:
+			s.poll()
+		}
+	}
+
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// ServiceRevision returns the revision of the named Service whose logs should
+// be shown: its latest ready revision, or its latest created revision if none
+// is ready yet
+func ServiceRevision(c kube.Client, name string) (string, error) {
+	var s serving.Service
+	if err := c.Get(kube.Services, name, &s); err != nil {
+		return "", err
+	}
+
+	if s.Status.LatestReadyRevisionName != "" {
+		return s.Status.LatestReadyRevisionName, nil
+	}
+
+	if s.Status.LatestCreatedRevisionName != "" {
+		return s.Status.LatestCreatedRevisionName, nil
+	}
+
+	return "", fmt.Errorf("service %s has no revisions yet", name)
+}
+
+func started(p corev1.Pod) bool {
+	for _, c := range p.Status.ContainerStatuses {
+		if c.Name == UserContainer {
+			return c.State.Running != nil || c.State.Terminated != nil
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,75 @@
+package logs_test
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	"github.com/julz/knightrider/pkg/logs"
+	knativeserving "github.com/knative/serving/pkg/apis/serving"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRevisionLogs(t *testing.T) {
+	c := kube.NewFake(
+		revisionPod("pod-a", "rev", true),
+		revisionPod("pod-b", "rev", true),
+		revisionPod("pod-c", "rev", false),
+		revisionPod("pod-d", "other-rev", true),
+	)
+	c.SetLogs("pod-a", "user-container", "hello from a\n")
+	c.SetLogs("pod-b", "user-container", "hello from b\n")
+
+	var out bytes.Buffer
+	s := &logs.Streamer{Client: c, Out: &out}
+	if err := s.Revision("rev", false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	sort.Strings(lines)
+	errorIfNotEqual(t, lines, []string{"[pod-a] hello from a", "[pod-b] hello from b"}, "expected logs to be '%s' but were '%s'")
+}
+
+func TestRevisionLogsFollowFails(t *testing.T) {
+	// the fake has no logs for the pod, so streaming them fails
+	c := kube.NewFake(revisionPod("pod-a", "rev", true))
+
+	s := &logs.Streamer{Client: c, Out: &bytes.Buffer{}, Sleep: func(time.Duration) {}}
+	if err := s.Revision("rev", true, make(chan struct{})); err == nil {
+		t.Error("expected following logs which can't be streamed to fail")
+	}
+}
+
+func TestServiceRevision(t *testing.T) {
+	s := knative.NewRunLatestService("svc")
+	s.Status.LatestCreatedRevisionName = "svc-00002"
+	s.Status.LatestReadyRevisionName = "svc-00001"
+
+	rev, err := logs.ServiceRevision(kube.NewFake(s), "svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, rev, "svc-00001", "expected revision to be '%s' but was '%s'")
+}
+
+func revisionPod(name, revision string, running bool) *corev1.Pod {
+	state := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}
+	if running {
+		state = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	}
+
+	return &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{knativeserving.RevisionLabelKey: revision}},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "user-container", State: state}},
+		},
+	}
+}
@@ -0,0 +1,395 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/julz/knightrider/pkg/knative"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+)
+
+// Result is what migrating a manifest did
+type Result struct {
+	// Changes describes each change made, e.g. "service/foo: runLatest -> traffic to the latest revision"
+	Changes []string
+
+	// Skipped describes each serving object which couldn't be migrated, and why
+	Skipped []string
+
+	// Output is the migrated manifest, which is the same as the input if
+	// nothing changed
+	Output []byte
+}
+
+// Changed returns true if anything was migrated
+func (r Result) Changed() bool {
+	return len(r.Changes) > 0
+}
+
+// Manifest migrates the v1alpha1 serving Services, Configurations and Routes
+// in a yml (or json) manifest to the given newer serving api version.
+//
+// Documents without anything to migrate are left exactly as they were, and
+// comments at the top of migrated documents are kept, but comments inside
+// migrated documents can't be, so they are listed in the changes
+func Manifest(content []byte, version string) (Result, error) {
+	if version == knative.V1Alpha1 {
+		return Result{}, fmt.Errorf("cannot migrate to %s, expected %s or %s", version, knative.V1Beta1, knative.V1)
+	}
+
+	if _, err := knative.ForVersion(nil, version); err != nil {
+		return Result{}, err
+	}
+
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '{' {
+		return migrateJSON(content, version)
+	}
+
+	var result Result
+	var out bytes.Buffer
+	for _, doc := range split(content) {
+		out.WriteString(doc.separator)
+
+		migrated, err := migrateDocument(doc.text, version, &result)
+		if err != nil {
+			return Result{}, err
+		}
+
+		if migrated == nil {
+			out.WriteString(doc.text)
+			continue
+		}
+
+		header, body := splitHeader(doc.text)
+		if n := countComments(body); n > 0 {
+			result.Changes = append(result.Changes, fmt.Sprintf("%s: dropped %d comment(s) from inside the object", describe(migrated.original), n))
+		}
+
+		b, err := yaml.Marshal(migrated.converted)
+		if err != nil {
+			return Result{}, err
+		}
+
+		out.WriteString(header)
+		out.Write(b)
+	}
+
+	result.Output = out.Bytes()
+	return result, nil
+}
+
+type migratedDocument struct {
+	original  interface{}
+	converted interface{}
+}
+
+// migrateDocument migrates the object in a document, returning nil if there's
+// nothing to migrate
+func migrateDocument(text, version string, result *Result) (*migratedDocument, error) {
+	objects, err := knative.Decode(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(objects) != 1 {
+		for _, o := range objects {
+			if migratable(o) {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: objects in lists are not migrated", describe(o)))
+			}
+		}
+
+		return nil, nil
+	}
+
+	o := objects[0]
+	if !migratable(o) {
+		return nil, nil
+	}
+
+	// anything the typed object doesn't have would be lost by migrating
+	dropped, err := droppedFields(text, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dropped) > 0 {
+		result.Skipped = append(result.Skipped, fmt.Sprintf("%s: kr doesn't know these fields, so migrating would drop them: %s", describe(o), strings.Join(dropped, ", ")))
+		return nil, nil
+	}
+
+	converted, err := knative.ForVersion(o, version)
+	if err != nil {
+		result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %s", describe(o), err))
+		return nil, nil
+	}
+
+	result.Changes = append(result.Changes, changes(o, version)...)
+	return &migratedDocument{original: o, converted: prune(converted)}, nil
+}
+
+// droppedFields returns the paths of the fields in a document which aren't in
+// the object decoded from it. Empty fields don't count, since dropping them
+// doesn't change anything
+func droppedFields(text string, o interface{}) ([]string, error) {
+	b, err := yaml.YAMLToJSON([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+
+	var original interface{}
+	if err := json.Unmarshal(b, &original); err != nil {
+		return nil, err
+	}
+
+	if b, err = json.Marshal(o); err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	missing("", original, decoded, &dropped)
+	sort.Strings(dropped)
+	return dropped, nil
+}
+
+// missing adds the paths of non-empty values in original which aren't in
+// decoded to dropped
+func missing(path string, original, decoded interface{}, dropped *[]string) {
+	switch original := original.(type) {
+	case map[string]interface{}:
+		d, _ := decoded.(map[string]interface{})
+		for k, v := range original {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+
+			if _, ok := d[k]; !ok && !empty(v) {
+				*dropped = append(*dropped, p)
+				continue
+			}
+
+			missing(p, v, d[k], dropped)
+		}
+	case []interface{}:
+		d, _ := decoded.([]interface{})
+		for i, v := range original {
+			if i < len(d) {
+				missing(fmt.Sprintf("%s[%d]", path, i), v, d[i], dropped)
+			}
+		}
+	}
+}
+
+func empty(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+
+	return false
+}
+
+func migrateJSON(content []byte, version string) (Result, error) {
+	var result Result
+	migrated, err := migrateDocument(string(content), version, &result)
+	if err != nil || migrated == nil {
+		result.Output = content
+		return result, err
+	}
+
+	b, err := json.MarshalIndent(migrated.converted, "", "  ")
+	if err != nil {
+		return Result{}, err
+	}
+
+	result.Output = append(b, '\n')
+	return result, nil
+}
+
+func migratable(o interface{}) bool {
+	switch o.(type) {
+	case *serving.Service, *serving.Configuration, *serving.Route:
+		return true
+	}
+
+	return false
+}
+
+// changes describes what migrating o will change
+func changes(o interface{}, version string) []string {
+	name := describe(o)
+	changes := []string{fmt.Sprintf("%s: apiVersion serving.knative.dev/%s -> serving.knative.dev/%s", name, knative.V1Alpha1, version)}
+
+	var config *serving.ConfigurationSpec
+	switch o := o.(type) {
+	case *serving.Service:
+		config = knative.ServiceConfiguration(o)
+		if o.Spec.RunLatest != nil {
+			changes = append(changes, name+": runLatest -> template, with all traffic to the latest revision")
+		} else {
+			changes = append(changes, fmt.Sprintf("%s: pinned to %s -> template, with all traffic to revision %s", name, o.Spec.Pinned.RevisionName, o.Spec.Pinned.RevisionName))
+		}
+	case *serving.Configuration:
+		config = &o.Spec
+		changes = append(changes, name+": revisionTemplate -> template")
+	case *serving.Route:
+		for _, t := range o.Spec.Traffic {
+			if t.Name != "" {
+				changes = append(changes, fmt.Sprintf("%s: traffic target name %s -> tag %s", name, t.Name, t.Name))
+			}
+
+			if t.ConfigurationName != "" {
+				changes = append(changes, fmt.Sprintf("%s: traffic to configuration %s -> latestRevision of configuration %s", name, t.ConfigurationName, t.ConfigurationName))
+			}
+		}
+	}
+
+	if config != nil {
+		switch config.RevisionTemplate.Spec.ConcurrencyModel {
+		case serving.RevisionRequestConcurrencyModelSingle:
+			changes = append(changes, name+": concurrencyModel Single -> containerConcurrency 1")
+		case serving.RevisionRequestConcurrencyModelMulti:
+			changes = append(changes, name+": concurrencyModel Multi -> no containerConcurrency limit")
+		}
+	}
+
+	return changes
+}
+
+func describe(o interface{}) string {
+	name := ""
+	switch o := o.(type) {
+	case *serving.Service:
+		name = o.Name
+	case *serving.Configuration:
+		name = o.Name
+	case *serving.Route:
+		name = o.Name
+	}
+
+	return strings.ToLower(knative.Kind(o)) + "/" + name
+}
+
+// prune removes the null fields, empty maps and empty names which the typed
+// objects always marshal, like creationTimestamp, resources and the name of a
+// container, and the status, so the output looks like it was written by hand.
+// Other empty strings, like empty args, are kept as they were written on
+// purpose
+func prune(o interface{}) interface{} {
+	b, _ := json.Marshal(o)
+
+	var m map[string]interface{}
+	json.Unmarshal(b, &m)
+	delete(m, "status")
+
+	return pruneValue(m)
+}
+
+func pruneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			// nothing in kubernetes can have an empty name
+			if value = pruneValue(value); value == nil || (k == "name" && value == "") {
+				delete(v, k)
+			} else {
+				v[k] = value
+			}
+		}
+
+		if len(v) == 0 {
+			return nil
+		}
+	case []interface{}:
+		// items of lists are kept even if they're empty, so they stay in place
+		for i := range v {
+			if pruned := pruneValue(v[i]); pruned != nil {
+				v[i] = pruned
+			} else if _, ok := v[i].(map[string]interface{}); !ok {
+				v[i] = nil
+			}
+		}
+	}
+
+	return v
+}
+
+// document is a yml document, and the separator line which came before it
+type document struct {
+	separator string
+	text      string
+}
+
+// split splits a yml stream at '---' lines, keeping the separators so the
+// stream can be put back together exactly
+func split(content []byte) []document {
+	var docs []document
+	current := document{}
+	reader := bufio.NewReader(bytes.NewReader(content))
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, "---") && strings.TrimSpace(line[3:]) == "" {
+			docs = append(docs, current)
+			current = document{separator: line}
+		} else {
+			current.text += line
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	return append(docs, current)
+}
+
+// splitHeader splits a document in to the blank and comment lines at its
+// start, and the rest
+func splitHeader(text string) (string, string) {
+	i := 0
+	for i < len(text) {
+		end := strings.IndexByte(text[i:], '\n')
+		if end < 0 {
+			end = len(text) - i - 1
+		}
+
+		if line := strings.TrimSpace(text[i : i+end+1]); line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+
+		i += end + 1
+	}
+
+	return text[:i], text[i:]
+}
+
+func countComments(text string) int {
+	n := 0
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "#") || strings.Contains(line, " #") {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,279 @@
+package migrate_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/migrate"
+)
+
+const manifest = `# the frontend
+apiVersion: serving.knative.dev/v1alpha1
+kind: Service
+metadata:
+  name: frontend
+spec:
+  runLatest:
+    configuration:
+      revisionTemplate:
+        spec:
+          concurrencyModel: Single
+          container:
+            image: docker.io/foo/frontend # pinned by ci
+---
+# left exactly as it was
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+---
+apiVersion: serving.knative.dev/v1alpha1
+kind: Route
+metadata:
+  name: backend
+spec:
+  traffic:
+  - revisionName: backend-00001
+    percent: 90
+  - configurationName: backend
+    name: next
+    percent: 10
+`
+
+const migrated = `# the frontend
+apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: frontend
+spec:
+  template:
+    spec:
+      containerConcurrency: 1
+      containers:
+      - image: docker.io/foo/frontend
+  traffic:
+  - latestRevision: true
+    percent: 100
+---
+# left exactly as it was
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+---
+apiVersion: serving.knative.dev/v1
+kind: Route
+metadata:
+  name: backend
+spec:
+  traffic:
+  - percent: 90
+    revisionName: backend-00001
+  - configurationName: backend
+    latestRevision: true
+    percent: 10
+    tag: next
+`
+
+func TestManifest(t *testing.T) {
+	result, err := migrate.Manifest([]byte(manifest), knative.V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, string(result.Output), migrated, "expected output to be:\n%s\nbut was:\n%s")
+	errorIfNotEqual(t, result.Changed(), true, "expected changed to be %t but was %t")
+	errorIfNotEqual(t, result.Skipped, []string(nil), "expected nothing to be skipped, '%v', but was '%v'")
+	errorIfNotEqual(t, result.Changes, []string{
+		"service/frontend: apiVersion serving.knative.dev/v1alpha1 -> serving.knative.dev/v1",
+		"service/frontend: runLatest -> template, with all traffic to the latest revision",
+		"service/frontend: concurrencyModel Single -> containerConcurrency 1",
+		"service/frontend: dropped 1 comment(s) from inside the object",
+		"route/backend: apiVersion serving.knative.dev/v1alpha1 -> serving.knative.dev/v1",
+		"route/backend: traffic target name next -> tag next",
+		"route/backend: traffic to configuration backend -> latestRevision of configuration backend",
+	}, "expected changes to be '%v' but were '%v'")
+}
+
+func TestManifestPinnedService(t *testing.T) {
+	result, err := migrate.Manifest([]byte(`apiVersion: serving.knative.dev/v1alpha1
+kind: Service
+metadata:
+  name: foo
+spec:
+  pinned:
+    revisionName: foo-00002
+    configuration:
+      revisionTemplate:
+        spec:
+          container:
+            image: foo
+`), knative.V1Beta1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, string(result.Output), `apiVersion: serving.knative.dev/v1beta1
+kind: Service
+metadata:
+  name: foo
+spec:
+  template:
+    spec:
+      containers:
+      - image: foo
+  traffic:
+  - percent: 100
+    revisionName: foo-00002
+`, "expected output to be:\n%s\nbut was:\n%s")
+}
+
+func TestManifestKeepsEmptyStrings(t *testing.T) {
+	result, err := migrate.Manifest([]byte(`apiVersion: serving.knative.dev/v1alpha1
+kind: Configuration
+metadata:
+  name: foo
+spec:
+  revisionTemplate:
+    spec:
+      container:
+        image: foo
+        args: ["", "x"]
+`), knative.V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, string(result.Output), `apiVersion: serving.knative.dev/v1
+kind: Configuration
+metadata:
+  name: foo
+spec:
+  template:
+    spec:
+      containers:
+      - args:
+        - ""
+        - x
+        image: foo
+`, "expected output to be:\n%s\nbut was:\n%s")
+}
+
+func TestManifestUnchanged(t *testing.T) {
+	for name, content := range map[string]string{
+		"non serving objects": "# a secret\napiVersion: v1\nkind: Secret\nmetadata:\n  name: foo\n",
+		"a v1 service":        "apiVersion: serving.knative.dev/v1\nkind: Service\nmetadata:\n  name: foo\n",
+		"only comments":       "# nothing here\n",
+	} {
+		result, err := migrate.Manifest([]byte(content), knative.V1)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		errorIfNotEqual(t, string(result.Output), content, name+": expected output to be '%s' but was '%s'")
+		errorIfNotEqual(t, result.Changed(), false, name+": expected changed to be %t but was %t")
+	}
+}
+
+func TestManifestSkipsBuilds(t *testing.T) {
+	content := `apiVersion: serving.knative.dev/v1alpha1
+kind: Configuration
+metadata:
+  name: foo
+spec:
+  build:
+    steps:
+    - image: ubuntu
+  revisionTemplate:
+    spec:
+      container:
+        image: foo
+`
+
+	result, err := migrate.Manifest([]byte(content), knative.V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, string(result.Output), content, "expected output to be unchanged, '%s', but was '%s'")
+	errorIfNotEqual(t, result.Changed(), false, "expected changed to be %t but was %t")
+	errorIfNotEqual(t, len(result.Skipped), 1, "expected %d object to be skipped but got %d")
+	if !strings.HasPrefix(result.Skipped[0], "configuration/foo: ") {
+		t.Errorf("expected the skipped configuration to be named, but got '%s'", result.Skipped[0])
+	}
+}
+
+func TestManifestSkipsUnknownFields(t *testing.T) {
+	content := `apiVersion: serving.knative.dev/v1alpha1
+kind: Service
+metadata:
+  name: foo
+spec:
+  runLatest:
+    configuration:
+      revisionTemplate:
+        spec:
+          timeoutSeconds: 30
+          containerConcurrency: 5
+          container:
+            image: foo
+`
+
+	result, err := migrate.Manifest([]byte(content), knative.V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, string(result.Output), content, "expected output to be unchanged, '%s', but was '%s'")
+	errorIfNotEqual(t, result.Changed(), false, "expected changed to be %t but was %t")
+	errorIfNotEqual(t, result.Skipped, []string{
+		"service/foo: kr doesn't know these fields, so migrating would drop them: " +
+			"spec.runLatest.configuration.revisionTemplate.spec.containerConcurrency, spec.runLatest.configuration.revisionTemplate.spec.timeoutSeconds",
+	}, "expected skipped to be '%v' but was '%v'")
+}
+
+func TestManifestJSON(t *testing.T) {
+	result, err := migrate.Manifest([]byte(`{"apiVersion": "serving.knative.dev/v1alpha1", "kind": "Configuration", "metadata": {"name": "foo"},
+	"spec": {"revisionTemplate": {"spec": {"container": {"image": "foo"}}}}}`), knative.V1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, string(result.Output), `{
+  "apiVersion": "serving.knative.dev/v1",
+  "kind": "Configuration",
+  "metadata": {
+    "name": "foo"
+  },
+  "spec": {
+    "template": {
+      "spec": {
+        "containers": [
+          {
+            "image": "foo"
+          }
+        ]
+      }
+    }
+  }
+}
+`, "expected output to be:\n%s\nbut was:\n%s")
+}
+
+func TestManifestErrors(t *testing.T) {
+	if _, err := migrate.Manifest([]byte(manifest), knative.V1Alpha1); err == nil {
+		t.Error("expected an error migrating to v1alpha1")
+	}
+
+	if _, err := migrate.Manifest([]byte("kind: [Service"), knative.V1); err == nil {
+		t.Error("expected an error migrating invalid yml")
+	}
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
@@ -0,0 +1,158 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// rule is one line of a .gitignore or .dockerignore file
+type rule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// rules is an ordered list of rules, where the last rule to match a path wins
+type rules []rule
+
+// ignored returns true if the slash separated path p (relative to the root of
+// the directory being packaged) is ignored
+func (rs rules) ignored(p string, dir bool) bool {
+	ignored := false
+	for _, r := range rs {
+		if r.dirOnly && !dir {
+			continue
+		}
+
+		if r.pattern.MatchString(p) {
+			ignored = !r.negate
+		}
+	}
+
+	return ignored
+}
+
+// readIgnoreFile returns the rules in the ignore file at file, or nil if it
+// doesn't exist. Patterns are relative to base, the slash separated directory
+// of the file relative to the root. Dockerignore patterns are always
+// anchored to base, gitignore patterns without a slash match at any depth
+func readIgnoreFile(file, base string, docker bool) (rules, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var rs rules
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseRule(scanner.Text(), base, docker); ok {
+			rs = append(rs, r)
+		}
+	}
+
+	return rs, scanner.Err()
+}
+
+func parseRule(line, base string, docker bool) (rule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if docker {
+		line = strings.TrimSpace(line)
+	}
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+
+	if docker {
+		line = path.Clean(filepath.ToSlash(line))
+	} else if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	anchored := docker || strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" || line == "." {
+		return rule{}, false
+	}
+
+	prefix := "^"
+	if base != "" {
+		prefix += regexp.QuoteMeta(base) + "/"
+	}
+
+	if !anchored {
+		prefix += "(?:.*/)?"
+	}
+
+	// like git, patterns which can't be parsed are ignored
+	pattern, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+	if err != nil {
+		return rule{}, false
+	}
+
+	r.pattern = pattern
+	return r, true
+}
+
+// globToRegexp converts a gitignore style glob, where * and ? don't match
+// slashes but ** matches any number of directories, to a regular expression
+func globToRegexp(glob string) string {
+	var re bytes.Buffer
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return re.String()
+}
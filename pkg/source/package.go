@@ -0,0 +1,145 @@
+package source
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Files returns the slash separated paths, relative to dir, of the files,
+// directories and symlinks in dir which should be packaged: everything except
+// .git and anything ignored by the .gitignore files in dir and its
+// subdirectories, or by dir's .dockerignore
+func Files(dir string) ([]string, error) {
+	docker, err := readIgnoreFile(filepath.Join(dir, ".dockerignore"), "", true)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = walk(dir, "", nil, func(rel string, info os.FileInfo, git rules) bool {
+		if info.IsDir() && info.Name() == ".git" {
+			return false
+		}
+
+		if git.ignored(rel, info.IsDir()) || docker.ignored(rel, info.IsDir()) {
+			return false
+		}
+
+		files = append(files, rel)
+		return true
+	})
+
+	return files, err
+}
+
+// walk calls fn for each entry below root/rel in lexical order, with the
+// gitignore rules which apply to it. Directories are only walked if fn
+// returns true
+func walk(root, rel string, git rules, fn func(rel string, info os.FileInfo, git rules) bool) error {
+	dir := filepath.Join(root, filepath.FromSlash(rel))
+	own, err := readIgnoreFile(filepath.Join(dir, ".gitignore"), rel, false)
+	if err != nil {
+		return err
+	}
+
+	// rules in deeper .gitignore files come later, so they win
+	git = append(append(rules(nil), git...), own...)
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range entries {
+		p := path.Join(rel, info.Name())
+		if !fn(p, info, git) || !info.IsDir() {
+			continue
+		}
+
+		if err := walk(root, p, git, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Package writes a gzipped tarball of the Files in dir to w. Times and owners
+// are zeroed, so packaging the same files always gives the same tarball (and
+// so the same digest)
+func Package(dir string, w io.Writer) error {
+	files, err := Files(dir)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, rel := range files {
+		if err := addFile(tw, filepath.Join(dir, filepath.FromSlash(rel)), rel); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+func addFile(tw *tar.Writer, file, rel string) error {
+	info, err := os.Lstat(file)
+	if err != nil {
+		return err
+	}
+
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(file); err != nil {
+			return err
+		}
+	} else if !info.IsDir() && !info.Mode().IsRegular() {
+		// sockets, devices and the like can't be packaged
+		return nil
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+
+	header.Name = rel
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	header.ModTime = time.Unix(0, 0)
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uid, header.Gid = 0, 0
+	header.Uname, header.Gname = "", ""
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,147 @@
+package source_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/julz/knightrider/pkg/source"
+)
+
+// tempTree creates a directory containing files, where the keys are slash
+// separated paths and the values are the files' contents
+func tempTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "kr-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestFiles(t *testing.T) {
+	dir := tempTree(t, map[string]string{
+		".git/HEAD":             "ref: refs/heads/master",
+		".gitignore":            "# build output\n*.log\n!keep.log\n/bin\nvendor/\ndocs/**/*.tmp\n",
+		".dockerignore":         "secrets\n**/*.pem\n",
+		"main.go":               "package main",
+		"debug.log":             "",
+		"keep.log":              "",
+		"bin/app":               "",
+		"cmd/bin/tool.go":       "package bin",
+		"vendor/lib/lib.go":     "package lib",
+		"docs/a/b/notes.tmp":    "",
+		"docs/a/b/notes.md":     "",
+		"secrets/token":         "",
+		"certs/server.pem":      "",
+		"web/.gitignore":        "node_modules\n!important.log\n",
+		"web/node_modules/x.js": "",
+		"web/important.log":     "",
+		"web/index.js":          "",
+	})
+
+	defer os.RemoveAll(dir)
+
+	files, err := source.Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, files, []string{
+		".dockerignore",
+		".gitignore",
+		"certs",
+		"cmd",
+		"cmd/bin",
+		"cmd/bin/tool.go",
+		"docs",
+		"docs/a",
+		"docs/a/b",
+		"docs/a/b/notes.md",
+		"keep.log",
+		"main.go",
+		"web",
+		"web/.gitignore",
+		"web/important.log",
+		"web/index.js",
+	}, "expected files to be '%v' but were '%v'")
+}
+
+func TestPackage(t *testing.T) {
+	dir := tempTree(t, map[string]string{
+		"main.go":     "package main",
+		"pkg/util.go": "package pkg",
+	})
+
+	defer os.RemoveAll(dir)
+
+	var first, second bytes.Buffer
+	if err := source.Package(dir, &first); err != nil {
+		t.Fatal(err)
+	}
+
+	// new times shouldn't change the tarball
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(dir, "main.go"), later, later)
+	if err := source.Package(dir, &second); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("expected packaging the same files twice to give the same tarball")
+	}
+
+	errorIfNotEqual(t, untar(t, first.Bytes()), map[string]string{
+		"main.go":     "package main",
+		"pkg/":        "",
+		"pkg/util.go": "package pkg",
+	}, "expected tarball to contain '%v' but it contained '%v'")
+}
+
+// untar returns the names and contents of the entries in a gzipped tarball
+func untar(t *testing.T, b []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, _ := ioutil.ReadAll(tr)
+		entries[header.Name] = string(content)
+	}
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
@@ -0,0 +1,403 @@
+package source
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Media types of the parts of the images pushed by a Registry
+const (
+	ManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	ConfigMediaType   = "application/vnd.docker.container.image.v1+json"
+	LayerMediaType    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// Registry pushes images to a docker registry with the v2 registry api
+type Registry struct {
+	// Insecure talks to the registry over http rather than https
+	Insecure bool
+
+	// Username and Password, if set, are used to authenticate with the
+	// registry, or with the token server it sends us to
+	Username, Password string
+
+	// Client is the http client to use, or nil for the default client
+	Client *http.Client
+
+	token string
+}
+
+// Reference is a parsed image reference, e.g. docker.io/me/app:tag
+type Reference struct {
+	// Host is the registry's host (and port), e.g. docker.io or localhost:5000
+	Host string
+
+	// Repository is the path of the image in the registry, e.g. me/app
+	Repository string
+
+	// Tag is the tag, or "" if there isn't one
+	Tag string
+}
+
+func (r Reference) String() string {
+	if r.Tag == "" {
+		return r.Host + "/" + r.Repository
+	}
+
+	return r.Host + "/" + r.Repository + ":" + r.Tag
+}
+
+// apiHost is where the registry api for r is served: docker hub's is not on
+// docker.io itself
+func (r Reference) apiHost() string {
+	if r.Host == "docker.io" {
+		return "registry-1.docker.io"
+	}
+
+	return r.Host
+}
+
+var repositoryPattern = regexp.MustCompile(`^[a-z0-9]+(?:[._-]+[a-z0-9]+)*(?:/[a-z0-9]+(?:[._-]+[a-z0-9]+)*)*$`)
+
+// ParseReference parses an image reference which doesn't have a digest. Like
+// docker, references without a registry host are on docker hub
+func ParseReference(ref string) (Reference, error) {
+	if strings.Contains(ref, "@") {
+		return Reference{}, fmt.Errorf("%s: expected a repository and optional tag, not a digest", ref)
+	}
+
+	var r Reference
+	name := ref
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		name, r.Tag = ref[:i], ref[i+1:]
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		r.Host, r.Repository = parts[0], parts[1]
+	} else {
+		r.Host, r.Repository = "docker.io", name
+		if !strings.Contains(name, "/") {
+			r.Repository = "library/" + name
+		}
+	}
+
+	if !repositoryPattern.MatchString(r.Repository) {
+		return Reference{}, fmt.Errorf("%s: %s is not a valid repository name", ref, r.Repository)
+	}
+
+	return r, nil
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Size      int    `json:"size"`
+	Digest    string `json:"digest"`
+}
+
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// PushLayer pushes an image whose only layer is layer, a gzipped tarball such
+// as the output of Package, to ref, and returns a reference to the image by
+// digest, e.g. docker.io/me/app@sha256:...
+func (reg *Registry) PushLayer(ref Reference, layer []byte) (string, error) {
+	uncompressed, err := gzip.NewReader(bytes.NewReader(layer))
+	if err != nil {
+		return "", fmt.Errorf("layer is not gzipped: %s", err)
+	}
+
+	diffID := sha256.New()
+	if _, err := io.Copy(diffID, uncompressed); err != nil {
+		return "", fmt.Errorf("layer is not gzipped: %s", err)
+	}
+
+	config, err := json.Marshal(map[string]interface{}{
+		"architecture": "amd64",
+		"os":           "linux",
+		"config":       map[string]interface{}{},
+		"rootfs": map[string]interface{}{
+			"type":     "layers",
+			"diff_ids": []string{fmt.Sprintf("sha256:%x", diffID.Sum(nil))},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	m := manifest{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+		Config:        descriptor{MediaType: ConfigMediaType, Size: len(config), Digest: digest(config)},
+		Layers:        []descriptor{{MediaType: LayerMediaType, Size: len(layer), Digest: digest(layer)}},
+	}
+
+	for _, blob := range [][]byte{layer, config} {
+		if err := reg.pushBlob(ref, blob); err != nil {
+			return "", err
+		}
+	}
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+
+	resp, err := reg.do(ref, "PUT", reg.url(ref, "/manifests/"+tag), ManifestMediaType, body)
+	if err != nil {
+		return "", err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("could not push manifest to %s: %s", ref, resp.Status)
+	}
+
+	return ref.Host + "/" + ref.Repository + "@" + digest(body), nil
+}
+
+// pushBlob uploads blob in one go, unless the registry already has it
+func (reg *Registry) pushBlob(ref Reference, blob []byte) error {
+	d := digest(blob)
+	resp, err := reg.do(ref, "HEAD", reg.url(ref, "/blobs/"+d), "", nil)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	if resp, err = reg.do(ref, "POST", reg.url(ref, "/blobs/uploads/"), "", nil); err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("could not start upload to %s: %s", ref, resp.Status)
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("could not start upload to %s: bad upload location: %s", ref, err)
+	}
+
+	q := location.Query()
+	q.Set("digest", d)
+	location.RawQuery = q.Encode()
+
+	if resp, err = reg.do(ref, "PUT", location.String(), "application/octet-stream", blob); err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("could not upload %s to %s: %s", d, ref, resp.Status)
+	}
+
+	return nil
+}
+
+func (reg *Registry) url(ref Reference, path string) string {
+	scheme := "https"
+	if reg.Insecure {
+		scheme = "http"
+	}
+
+	return scheme + "://" + ref.apiHost() + "/v2/" + ref.Repository + path
+}
+
+// do sends a request, authenticating and retrying once if the registry asks
+// for credentials
+func (reg *Registry) do(ref Reference, method, u, contentType string, body []byte) (*http.Response, error) {
+	resp, err := reg.send(method, u, contentType, body)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	resp.Body.Close()
+	if err := reg.authenticate(ref, resp.Header.Get("WWW-Authenticate")); err != nil {
+		return nil, err
+	}
+
+	return reg.send(method, u, contentType, body)
+}
+
+func (reg *Registry) send(method, u, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if reg.token != "" {
+		req.Header.Set("Authorization", "Bearer "+reg.token)
+	} else if reg.Username != "" {
+		req.SetBasicAuth(reg.Username, reg.Password)
+	}
+
+	return reg.client().Do(req)
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate answers a WWW-Authenticate challenge: basic challenges are
+// answered with the Username and Password, and bearer challenges with a token
+// from the token server they point to
+func (reg *Registry) authenticate(ref Reference, challenge string) error {
+	if strings.HasPrefix(strings.ToLower(challenge), "basic") {
+		if reg.Username == "" {
+			return fmt.Errorf("%s needs a username and password", ref.Host)
+		}
+
+		return nil
+	}
+
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer") {
+		return fmt.Errorf("%s: unauthorized, and doesn't say how to authenticate", ref.Host)
+	}
+
+	params := make(map[string]string)
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("%s: bad token realm in %q", ref.Host, challenge)
+	}
+
+	q := realm.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+
+	q.Set("scope", "repository:"+ref.Repository+":pull,push")
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if reg.Username != "" {
+		req.SetBasicAuth(reg.Username, reg.Password)
+	}
+
+	resp, err := reg.client().Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get a token to push to %s: %s", ref, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("could not get a token to push to %s: %s", ref, err)
+	}
+
+	if reg.token = token.Token; reg.token == "" {
+		reg.token = token.AccessToken
+	}
+
+	return nil
+}
+
+func (reg *Registry) client() *http.Client {
+	if reg.Client != nil {
+		return reg.Client
+	}
+
+	return http.DefaultClient
+}
+
+func digest(b []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+}
+
+// DockerCredentials returns the username and password docker login stored for
+// host in $DOCKER_CONFIG/config.json (or ~/.docker/config.json), or empty
+// strings if there aren't any. Credential helpers aren't supported
+func DockerCredentials(host string) (string, string, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".docker")
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	}
+
+	if err != nil {
+		return "", "", err
+	}
+
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+
+	if err := json.Unmarshal(b, &config); err != nil {
+		return "", "", fmt.Errorf("could not read docker config: %s", err)
+	}
+
+	keys := []string{host, "https://" + host, "http://" + host}
+	if host == "docker.io" {
+		keys = append(keys, "https://index.docker.io/v1/")
+	}
+
+	for _, key := range keys {
+		auth, ok := config.Auths[key]
+		if !ok || auth.Auth == "" {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", fmt.Errorf("could not read docker credentials for %s: %s", host, err)
+		}
+
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("could not read docker credentials for %s: expected user:password", host)
+		}
+
+		return parts[0], parts[1], nil
+	}
+
+	return "", "", nil
+}
@@ -0,0 +1,230 @@
+package source_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/source"
+)
+
+// registry is a stand-in for a docker registry, which keeps the blobs and
+// manifests pushed to it in memory and, if token is set, wants a bearer token
+// which it hands out to user:pass
+type registry struct {
+	token, user, pass string
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int
+}
+
+func newRegistry() (*registry, *httptest.Server) {
+	r := &registry{blobs: make(map[string][]byte), manifests: make(map[string][]byte)}
+	return r, httptest.NewServer(r)
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/token" {
+		if user, pass, _ := req.BasicAuth(); user != r.user || pass != r.pass {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"token": r.token})
+		return
+	}
+
+	if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="stand-in"`, req.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	path := strings.TrimPrefix(req.URL.Path, "/v2/me/app-source")
+	switch {
+	case req.Method == "HEAD" && strings.HasPrefix(path, "/blobs/"):
+		if _, ok := r.blobs[strings.TrimPrefix(path, "/blobs/")]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case req.Method == "POST" && path == "/blobs/uploads/":
+		w.Header().Set("Location", "/v2/me/app-source/blobs/uploads/some-uuid?state=abc")
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == "PUT" && path == "/blobs/uploads/some-uuid":
+		d := req.URL.Query().Get("digest")
+		if req.URL.Query().Get("state") != "abc" || d != fmt.Sprintf("sha256:%x", sha256.Sum256(body)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		r.uploads++
+		r.blobs[d] = body
+		w.WriteHeader(http.StatusCreated)
+	case req.Method == "PUT" && strings.HasPrefix(path, "/manifests/"):
+		if req.Header.Get("Content-Type") != source.ManifestMediaType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		r.manifests[strings.TrimPrefix(path, "/manifests/")] = body
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// pull returns the files of the image tagged tag, checking the blobs the
+// manifest refers to were all pushed
+func (r *registry) pull(t *testing.T, tag string) (string, map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, ok := r.manifests[tag]
+	if !ok {
+		t.Fatalf("expected a manifest to be pushed for %s", tag)
+	}
+
+	var m struct {
+		Config struct{ Digest string }
+		Layers []struct{ Digest string }
+	}
+
+	json.Unmarshal(body, &m)
+	if _, ok := r.blobs[m.Config.Digest]; !ok {
+		t.Errorf("expected the config blob to be pushed")
+	}
+
+	if len(m.Layers) != 1 {
+		t.Fatalf("expected one layer but got %d", len(m.Layers))
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(body)), untar(t, r.blobs[m.Layers[0].Digest])
+}
+
+func TestPushLayer(t *testing.T) {
+	reg, server := newRegistry()
+	defer server.Close()
+
+	dir := tempTree(t, map[string]string{"main.go": "package main", "app.log": "", ".gitignore": "*.log"})
+	defer os.RemoveAll(dir)
+
+	var layer bytes.Buffer
+	if err := source.Package(dir, &layer); err != nil {
+		t.Fatal(err)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	ref, err := source.ParseReference(host + "/me/app-source:dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image, err := (&source.Registry{Insecure: true}).PushLayer(ref, layer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, files := reg.pull(t, "dev")
+	errorIfNotEqual(t, image, host+"/me/app-source@"+digest, "expected the pushed image to be '%s' but was '%s'")
+	errorIfNotEqual(t, files, map[string]string{".gitignore": "*.log", "main.go": "package main"}, "expected the image to contain '%v' but it contained '%v'")
+
+	// blobs the registry already has aren't uploaded again
+	if _, err := (&source.Registry{Insecure: true}).PushLayer(ref, layer.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, reg.uploads, 2, "expected %d blobs to be uploaded but %d were")
+}
+
+func TestPushLayerWithToken(t *testing.T) {
+	reg, server := newRegistry()
+	defer server.Close()
+
+	reg.token, reg.user, reg.pass = "let-me-in", "me", "secret"
+
+	dir := tempTree(t, map[string]string{"main.go": "package main"})
+	defer os.RemoveAll(dir)
+
+	var layer bytes.Buffer
+	if err := source.Package(dir, &layer); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, _ := source.ParseReference(strings.TrimPrefix(server.URL, "http://") + "/me/app-source")
+	if _, err := (&source.Registry{Insecure: true, Username: "me", Password: "wrong"}).PushLayer(ref, layer.Bytes()); err == nil {
+		t.Error("expected pushing with the wrong password to fail")
+	}
+
+	if _, err := (&source.Registry{Insecure: true, Username: "me", Password: "secret"}).PushLayer(ref, layer.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	reg.pull(t, "latest")
+}
+
+func TestParseReference(t *testing.T) {
+	for ref, expected := range map[string]source.Reference{
+		"localhost:5000/app":       {Host: "localhost:5000", Repository: "app"},
+		"gcr.io/me/app:v1":         {Host: "gcr.io", Repository: "me/app", Tag: "v1"},
+		"me/app":                   {Host: "docker.io", Repository: "me/app"},
+		"busybox:latest":           {Host: "docker.io", Repository: "library/busybox", Tag: "latest"},
+		"registry.local/a/b/c:dev": {Host: "registry.local", Repository: "a/b/c", Tag: "dev"},
+	} {
+		r, err := source.ParseReference(ref)
+		if err != nil {
+			t.Errorf("%s: %s", ref, err)
+		}
+
+		errorIfNotEqual(t, r, expected, ref+": expected '%v' but got '%v'")
+	}
+
+	for _, ref := range []string{"me/App", "me/app@sha256:abc", "gcr.io/"} {
+		if _, err := source.ParseReference(ref); err == nil {
+			t.Errorf("expected %s not to parse", ref)
+		}
+	}
+}
+
+func TestDockerCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kr-docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	defer os.Setenv("DOCKER_CONFIG", os.Getenv("DOCKER_CONFIG"))
+	os.Setenv("DOCKER_CONFIG", dir)
+
+	auth := base64.StdEncoding.EncodeToString([]byte("me:secret"))
+	ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"auths": {
+		"https://index.docker.io/v1/": {"auth": "`+auth+`"},
+		"localhost:5000": {"auth": "`+auth+`"}
+	}}`), 0600)
+
+	for _, host := range []string{"docker.io", "localhost:5000"} {
+		user, pass, err := source.DockerCredentials(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errorIfNotEqual(t, user+":"+pass, "me:secret", host+": expected credentials '%s' but got '%s'")
+	}
+
+	user, _, _ := source.DockerCredentials("gcr.io")
+	errorIfNotEqual(t, user, "", "expected no credentials for gcr.io, '%s', but got '%s'")
+}
@@ -0,0 +1,191 @@
+package tekton
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SourceResource is the name of the git resource of Tasks generated from
+// builds. Knative builds check their source out to /workspace, but tekton
+// checks it out to /workspace/source, so paths in steps are rewritten to match
+const SourceResource = "source"
+
+const workspace = "/workspace"
+
+// FromBuildTemplate converts a knative BuildTemplate to a Task which takes
+// its parameters as params, and a git source
+func FromBuildTemplate(t *build.BuildTemplate) *Task {
+	task := &Task{
+		TypeMeta:   metav1.TypeMeta{APIVersion: APIVersion, Kind: "Task"},
+		ObjectMeta: t.ObjectMeta,
+		Spec: TaskSpec{
+			Inputs: &Inputs{
+				Resources: []TaskResource{{Name: SourceResource, Type: "git"}},
+			},
+			Volumes: t.Spec.Volumes,
+		},
+	}
+
+	var params []string
+	for _, p := range t.Spec.Parameters {
+		params = append(params, p.Name)
+	}
+
+	for _, p := range t.Spec.Parameters {
+		param := ParamSpec{Name: p.Name, Description: p.Description}
+		if p.Default != nil {
+			param.Default = rewrite(*p.Default, params, true)
+		}
+
+		task.Spec.Inputs.Params = append(task.Spec.Inputs.Params, param)
+	}
+
+	task.Spec.Steps = steps(t.Spec.Steps, params, true)
+	return task
+}
+
+// FromBuild converts a knative Build to a TaskRun, preceded by a git
+// PipelineResource if the Build has a git source. Builds of a template run
+// the Task generated from the template by FromBuildTemplate, builds with
+// steps run them in an inline Task
+func FromBuild(b *build.Build) ([]interface{}, error) {
+	run := &TaskRun{
+		TypeMeta:   metav1.TypeMeta{APIVersion: APIVersion, Kind: "TaskRun"},
+		ObjectMeta: b.ObjectMeta,
+		Spec: TaskRunSpec{
+			ServiceAccount: b.Spec.ServiceAccountName,
+		},
+	}
+
+	var objects []interface{}
+	source := b.Spec.Source
+	if source != nil {
+		if source.Git == nil {
+			return nil, fmt.Errorf("build %s: only git sources can be converted to tekton", b.Name)
+		}
+
+		resource := &PipelineResource{
+			TypeMeta: metav1.TypeMeta{APIVersion: APIVersion, Kind: "PipelineResource"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      b.Name + "-" + SourceResource,
+				Namespace: b.Namespace,
+			},
+			Spec: PipelineResourceSpec{
+				Type: "git",
+				Params: []Param{
+					{Name: "url", Value: source.Git.Url},
+					{Name: "revision", Value: source.Git.Revision},
+				},
+			},
+		}
+
+		objects = append(objects, resource)
+		run.Spec.Inputs.Resources = []TaskResourceBinding{{
+			Name:        SourceResource,
+			ResourceRef: PipelineResourceRef{Name: resource.Name},
+		}}
+	}
+
+	if t := b.Spec.Template; t != nil {
+		if len(t.Env) > 0 {
+			return nil, fmt.Errorf("build %s: tekton task runs cannot set the environment of a task's steps", b.Name)
+		}
+
+		if len(b.Spec.Volumes) > 0 {
+			return nil, fmt.Errorf("build %s: tekton task runs cannot add volumes to a task", b.Name)
+		}
+
+		if source == nil {
+			return nil, fmt.Errorf("build %s: tasks generated from build templates need a git source", b.Name)
+		}
+
+		// the task sees the source in its own directory, so paths in the
+		// arguments have to move there too
+		run.Spec.TaskRef = &TaskRef{Name: t.Name}
+		for _, a := range t.Arguments {
+			run.Spec.Inputs.Params = append(run.Spec.Inputs.Params, Param{Name: a.Name, Value: rewrite(a.Value, nil, true)})
+		}
+	} else {
+		run.Spec.TaskSpec = &TaskSpec{
+			Steps:   steps(b.Spec.Steps, nil, source != nil),
+			Volumes: b.Spec.Volumes,
+		}
+
+		if source != nil {
+			run.Spec.TaskSpec.Inputs = &Inputs{
+				Resources: []TaskResource{{Name: SourceResource, Type: "git"}},
+			}
+		}
+	}
+
+	return append(objects, run), nil
+}
+
+// steps returns copies of the knative build steps with references to params
+// and, if there's a source, the workspace rewritten for tekton
+func steps(containers []corev1.Container, params []string, source bool) []corev1.Container {
+	var steps []corev1.Container
+	for _, c := range containers {
+		step := *c.DeepCopy()
+		step.Image = rewrite(step.Image, params, source)
+		step.WorkingDir = rewrite(step.WorkingDir, params, source)
+		for i := range step.Command {
+			step.Command[i] = rewrite(step.Command[i], params, source)
+		}
+
+		for i := range step.Args {
+			step.Args[i] = rewrite(step.Args[i], params, source)
+		}
+
+		for i := range step.Env {
+			step.Env[i].Value = rewrite(step.Env[i].Value, params, source)
+		}
+
+		if source && step.WorkingDir == "" {
+			step.WorkingDir = workspace + "/" + SourceResource
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps
+}
+
+// rewrite turns knative ${PARAM} references to params in to tekton
+// ${inputs.params.PARAM} references, and if there's a source moves paths in
+// the workspace to the source's directory
+func rewrite(s string, params []string, source bool) string {
+	for _, p := range params {
+		s = strings.Replace(s, "${"+p+"}", "${inputs.params."+p+"}", -1)
+	}
+
+	if !source {
+		return s
+	}
+
+	var out bytes.Buffer
+	for {
+		i := strings.Index(s, workspace)
+		if i < 0 {
+			out.WriteString(s)
+			return out.String()
+		}
+
+		end := i + len(workspace)
+		out.WriteString(s[:end])
+		if (i == 0 || !isPathChar(s[i-1])) && (end == len(s) || s[end] == '/' || !isPathChar(s[end])) {
+			out.WriteString("/" + SourceResource)
+		}
+
+		s = s[end:]
+	}
+}
+
+func isPathChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.' || c == '/'
+}
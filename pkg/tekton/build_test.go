@@ -0,0 +1,125 @@
+package tekton_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFromBuildTemplate(t *testing.T) {
+	bt := knative.NewBuildTemplate("kaniko",
+		knative.WithParameter("IMAGE", "where to push the image"),
+		knative.WithParameterDefault("DOCKERFILE", "path to the Dockerfile", "/workspace/Dockerfile"),
+		knative.WithTemplateStep("build", "gcr.io/kaniko-project/executor", "--dockerfile=${DOCKERFILE}", "--destination=${IMAGE}", "--context=/workspace"),
+	)
+
+	task := tekton.FromBuildTemplate(bt)
+
+	errorIfNotEqual(t, task.Kind, "Task", "expected kind to be '%s' but was '%s'")
+	errorIfNotEqual(t, task.APIVersion, "tekton.dev/v1alpha1", "expected api version to be '%s' but was '%s'")
+	errorIfNotEqual(t, task.Name, "kaniko", "expected name to be '%s' but was '%s'")
+	errorIfNotEqual(t, task.Spec.Inputs.Resources, []tekton.TaskResource{{Name: "source", Type: "git"}}, "expected resources to be '%v' but were '%v'")
+	errorIfNotEqual(t, task.Spec.Inputs.Params, []tekton.ParamSpec{
+		{Name: "IMAGE", Description: "where to push the image"},
+		{Name: "DOCKERFILE", Description: "path to the Dockerfile", Default: "/workspace/source/Dockerfile"},
+	}, "expected params to be '%v' but were '%v'")
+	errorIfNotEqual(t, task.Spec.Steps, []corev1.Container{{
+		Name:       "build",
+		Image:      "gcr.io/kaniko-project/executor",
+		Args:       []string{"--dockerfile=${inputs.params.DOCKERFILE}", "--destination=${inputs.params.IMAGE}", "--context=/workspace/source"},
+		WorkingDir: "/workspace/source",
+	}}, "expected steps to be '%v' but were '%v'")
+
+	errorIfNotEqual(t, bt.Spec.Steps[0].Args[0], "--dockerfile=${DOCKERFILE}", "expected the build template not to be modified, '%s', but was '%s'")
+}
+
+func TestFromBuildWithTemplate(t *testing.T) {
+	b := knative.NewBuild("my-build",
+		knative.WithGitSource("https://github.com/foo/bar", "abc123"),
+		knative.WithBuildTemplate("kaniko", map[string]string{"IMAGE": "docker.io/foo/bar"}, nil),
+		knative.WithServiceAccount("buildbot"),
+	)
+
+	objects, err := tekton.FromBuild(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, len(objects), 2, "expected %d objects but got %d")
+
+	resource := objects[0].(*tekton.PipelineResource)
+	errorIfNotEqual(t, resource.Name, "my-build-source", "expected resource name to be '%s' but was '%s'")
+	errorIfNotEqual(t, resource.Spec, tekton.PipelineResourceSpec{
+		Type: "git",
+		Params: []tekton.Param{
+			{Name: "url", Value: "https://github.com/foo/bar"},
+			{Name: "revision", Value: "abc123"},
+		},
+	}, "expected resource spec to be '%v' but was '%v'")
+
+	run := objects[1].(*tekton.TaskRun)
+	errorIfNotEqual(t, run.Name, "my-build", "expected task run name to be '%s' but was '%s'")
+	errorIfNotEqual(t, run.Kind, "TaskRun", "expected kind to be '%s' but was '%s'")
+	errorIfNotEqual(t, run.Spec.TaskRef, &tekton.TaskRef{Name: "kaniko"}, "expected task ref to be '%v' but was '%v'")
+	errorIfNotEqual(t, run.Spec.ServiceAccount, "buildbot", "expected service account to be '%s' but was '%s'")
+	errorIfNotEqual(t, run.Spec.Inputs, tekton.TaskRunInputs{
+		Resources: []tekton.TaskResourceBinding{{Name: "source", ResourceRef: tekton.PipelineResourceRef{Name: "my-build-source"}}},
+		Params:    []tekton.Param{{Name: "IMAGE", Value: "docker.io/foo/bar"}},
+	}, "expected inputs to be '%v' but were '%v'")
+}
+
+func TestFromBuildWithWorkspaceArguments(t *testing.T) {
+	b := knative.NewBuild("my-build",
+		knative.WithGitSource("https://github.com/foo/bar", "abc123"),
+		knative.WithBuildTemplate("kaniko", map[string]string{"DOCKERFILE": "/workspace/Dockerfile"}, nil),
+	)
+
+	objects, err := tekton.FromBuild(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := objects[1].(*tekton.TaskRun)
+	errorIfNotEqual(t, run.Spec.Inputs.Params, []tekton.Param{{Name: "DOCKERFILE", Value: "/workspace/source/Dockerfile"}}, "expected params to be '%v' but were '%v'")
+}
+
+func TestFromBuildWithSteps(t *testing.T) {
+	b := knative.NewBuild("my-build", knative.WithStep("test", "golang", "go", "test", "./..."))
+
+	objects, err := tekton.FromBuild(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, len(objects), 1, "expected %d object but got %d")
+
+	run := objects[0].(*tekton.TaskRun)
+	errorIfNotEqual(t, run.Spec.TaskSpec, &tekton.TaskSpec{
+		Steps: []corev1.Container{{Name: "test", Image: "golang", Args: []string{"go", "test", "./..."}}},
+	}, "expected inline task to be '%v' but was '%v'")
+}
+
+func TestFromBuildErrors(t *testing.T) {
+	for name, options := range map[string][]knative.BuildSpecOption{
+		"template without a source": {
+			knative.WithBuildTemplate("kaniko", nil, nil),
+		},
+		"template with env": {
+			knative.WithGitSource("https://github.com/foo/bar", "master"),
+			knative.WithBuildTemplate("kaniko", nil, map[string]string{"FOO": "bar"}),
+		},
+	} {
+		if _, err := tekton.FromBuild(knative.NewBuild("foo", options...)); err == nil {
+			t.Errorf("expected an error converting a build with a %s", name)
+		}
+	}
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
@@ -0,0 +1,104 @@
+package tekton
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// APIVersion is the version of the tekton pipelines objects generated
+const APIVersion = "tekton.dev/v1alpha1"
+
+// The tekton pipelines types aren't vendored, so these are the parts of
+// tekton.dev/v1alpha1 needed to express what a knative build can
+
+// Task is a reusable set of steps, like a knative BuildTemplate
+type Task struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec TaskSpec `json:"spec"`
+}
+
+// TaskSpec is the inputs and steps of a Task
+type TaskSpec struct {
+	Inputs  *Inputs            `json:"inputs,omitempty"`
+	Steps   []corev1.Container `json:"steps,omitempty"`
+	Volumes []corev1.Volume    `json:"volumes,omitempty"`
+}
+
+// Inputs are the resources and params a Task takes
+type Inputs struct {
+	Resources []TaskResource `json:"resources,omitempty"`
+	Params    []ParamSpec    `json:"params,omitempty"`
+}
+
+// TaskResource is a resource a Task takes, e.g. a git repository
+type TaskResource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ParamSpec is a param a Task takes, which steps refer to as ${inputs.params.NAME}
+type ParamSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+}
+
+// TaskRun runs a Task, like a knative Build
+type TaskRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec TaskRunSpec `json:"spec"`
+}
+
+// TaskRunSpec is the Task to run, either by reference or inline, and its inputs
+type TaskRunSpec struct {
+	TaskRef        *TaskRef      `json:"taskRef,omitempty"`
+	TaskSpec       *TaskSpec     `json:"taskSpec,omitempty"`
+	Inputs         TaskRunInputs `json:"inputs,omitempty"`
+	ServiceAccount string        `json:"serviceAccount,omitempty"`
+}
+
+// TaskRef refers to a Task by name
+type TaskRef struct {
+	Name string `json:"name"`
+}
+
+// TaskRunInputs are the values of the inputs of the Task being run
+type TaskRunInputs struct {
+	Resources []TaskResourceBinding `json:"resources,omitempty"`
+	Params    []Param               `json:"params,omitempty"`
+}
+
+// TaskResourceBinding binds a PipelineResource to one of a Task's resources
+type TaskResourceBinding struct {
+	Name        string              `json:"name"`
+	ResourceRef PipelineResourceRef `json:"resourceRef"`
+}
+
+// PipelineResourceRef refers to a PipelineResource by name
+type PipelineResourceRef struct {
+	Name string `json:"name"`
+}
+
+// Param is the value of a param
+type Param struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PipelineResource is an input to a TaskRun, e.g. a git repository at a revision
+type PipelineResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec PipelineResourceSpec `json:"spec"`
+}
+
+// PipelineResourceSpec is the type of a PipelineResource and where to find it
+type PipelineResourceSpec struct {
+	Type   string  `json:"type"`
+	Params []Param `json:"params"`
+}
@@ -0,0 +1,232 @@
+package traffic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+)
+
+// Names of the traffic targets used for blue/green deploys. Named targets are
+// reachable at their own sub-domain of the route, e.g. green.my-route.default.example.com
+const (
+	BlueTarget  = "blue"
+	GreenTarget = "green"
+)
+
+// Preview adds green as a named 0% traffic target, so it can be tried out at its
+// own sub-domain without receiving any of the main traffic. It returns the
+// objects to apply and the url green will be reachable at (which is empty if the
+// route has no domain yet).
+//
+// Routes (resource kube.Routes) get the target added directly. runLatest and
+// pinned Services (resource kube.Services) can't have named targets, so a
+// separate NAME-preview Route is generated which sends all traffic to the
+// Service's current revision, as blue, and 0% to green
+func Preview(c kube.Client, resource, name, green string) ([]interface{}, string, error) {
+	switch resource {
+	case kube.Routes:
+		var r serving.Route
+		if err := c.Get(kube.Routes, name, &r); err != nil {
+			return nil, "", err
+		}
+
+		var options []knative.RouteOption
+		for _, t := range r.Spec.Traffic {
+			if t.Name != GreenTarget {
+				options = append(options, trafficOptions([]serving.TrafficTarget{t})...)
+			}
+		}
+
+		options = append(options, knative.WithTrafficToRevision(GreenTarget, green, 0))
+		preview := knative.NewRoute(name, options...)
+		preview.ObjectMeta = knative.UserMeta(r.ObjectMeta)
+		return []interface{}{preview}, targetURL(GreenTarget, r.Status.Domain), nil
+	case kube.Services:
+		s, blue, err := serviceRevision(c, name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		existing, err := optionalRoute(c, previewRoute(name))
+		if err != nil {
+			return nil, "", err
+		}
+
+		preview := knative.NewRoute(previewRoute(name),
+			knative.WithTrafficToRevision(BlueTarget, blue, 100),
+			knative.WithTrafficToRevision(GreenTarget, green, 0),
+		)
+
+		preview.ObjectMeta = knative.UserMeta(existing.ObjectMeta)
+		return []interface{}{preview}, targetURL(GreenTarget, previewDomain(s)), nil
+	}
+
+	return nil, "", fmt.Errorf("blue/green deploys are only supported for routes and services, not %s", resource)
+}
+
+// Promote sends all traffic to green, keeping the revision which was previously
+// serving reachable as a named 0% blue target. Any other targets are kept at
+// 0%. It returns the objects to apply and the url blue will be reachable at.
+//
+// Services are pinned to green, which must be a ready Revision of the Service,
+// and their NAME-preview Route is updated to keep blue reachable
+func Promote(c kube.Client, resource, name, green string) ([]interface{}, string, error) {
+	switch resource {
+	case kube.Routes:
+		var r serving.Route
+		if err := c.Get(kube.Routes, name, &r); err != nil {
+			return nil, "", err
+		}
+
+		blue := mostTrafficExcept(r.Status.Traffic, green)
+		if blue == "" {
+			blue = mostTrafficExcept(r.Spec.Traffic, green)
+		}
+
+		if blue == "" {
+			return nil, "", fmt.Errorf("route %s is not routing traffic to any revision other than %s", name, green)
+		}
+
+		return []interface{}{promotedRoute(r, blue, green)}, targetURL(BlueTarget, r.Status.Domain), nil
+	case kube.Services:
+		s, blue, err := serviceRevision(c, name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if blue == green {
+			return nil, "", fmt.Errorf("service %s is already serving %s", name, green)
+		}
+
+		if err := checkRevision(c, green, map[string]bool{name: true}); err != nil {
+			return nil, "", err
+		}
+
+		config := knative.ServiceConfiguration(s)
+		pinned := knative.NewPinnedService(name, green, func(spec *serving.ConfigurationSpec) {
+			*spec = *config
+		})
+
+		pinned.ObjectMeta = knative.UserMeta(s.ObjectMeta)
+
+		preview, err := optionalRoute(c, previewRoute(name))
+		if err != nil {
+			return nil, "", err
+		}
+
+		return []interface{}{pinned, promotedRoute(preview, blue, green)}, targetURL(BlueTarget, previewDomain(s)), nil
+	}
+
+	return nil, "", fmt.Errorf("blue/green deploys are only supported for routes and services, not %s", resource)
+}
+
+// promotedRoute returns the Route r with all traffic sent to green, and blue
+// kept as a 0% target. The Route's other targets are kept at 0%, so they stay
+// reachable, and it keeps its labels and annotations
+func promotedRoute(r serving.Route, blue, green string) *serving.Route {
+	options := []knative.RouteOption{
+		knative.WithTrafficToRevision(GreenTarget, green, 100),
+		knative.WithTrafficToRevision(BlueTarget, blue, 0),
+	}
+
+	options = append(options, trafficOptions(parked(r.Spec.Traffic, func(t serving.TrafficTarget) bool {
+		if t.Name == "" {
+			return t.RevisionName != blue && t.RevisionName != green
+		}
+
+		return t.Name != BlueTarget && t.Name != GreenTarget
+	}))...)
+
+	promoted := knative.NewRoute(r.Name, options...)
+	promoted.ObjectMeta = knative.UserMeta(r.ObjectMeta)
+	return promoted
+}
+
+// parked returns the targets keep returns true for, with no traffic
+func parked(traffic []serving.TrafficTarget, keep func(serving.TrafficTarget) bool) []serving.TrafficTarget {
+	var targets []serving.TrafficTarget
+	for _, t := range traffic {
+		if keep(t) {
+			t.Percent = 0
+			targets = append(targets, t)
+		}
+	}
+
+	return targets
+}
+
+// optionalRoute returns the named Route, or an empty Route with just the name
+// if it doesn't exist yet
+func optionalRoute(c kube.Client, name string) (serving.Route, error) {
+	var r serving.Route
+	if err := c.Get(kube.Routes, name, &r); err != nil {
+		if !kube.IsNotFound(err) {
+			return r, err
+		}
+
+		r.Name = name
+	}
+
+	return r, nil
+}
+
+// serviceRevision returns the named Service and the revision it is currently serving
+func serviceRevision(c kube.Client, name string) (*serving.Service, string, error) {
+	var s serving.Service
+	if err := c.Get(kube.Services, name, &s); err != nil {
+		return nil, "", err
+	}
+
+	if knative.ServiceConfiguration(&s) == nil {
+		return nil, "", fmt.Errorf("service %s is neither runLatest nor pinned", name)
+	}
+
+	revision := s.Status.LatestReadyRevisionName
+	if s.Spec.Pinned != nil {
+		revision = s.Spec.Pinned.RevisionName
+	} else if r := mostTraffic(s.Status.Traffic); r != "" {
+		revision = r
+	}
+
+	if revision == "" {
+		return nil, "", fmt.Errorf("service %s is not serving any revision yet", name)
+	}
+
+	return &s, revision, nil
+}
+
+func previewRoute(service string) string {
+	return service + "-preview"
+}
+
+// previewDomain returns the domain the preview Route of a Service will get, by
+// swapping the service name at the start of the Service's domain
+func previewDomain(s *serving.Service) string {
+	if !strings.HasPrefix(s.Status.Domain, s.Name+".") {
+		return ""
+	}
+
+	return previewRoute(s.Name) + strings.TrimPrefix(s.Status.Domain, s.Name)
+}
+
+func targetURL(target, domain string) string {
+	if domain == "" {
+		return ""
+	}
+
+	return "http://" + target + "." + domain
+}
+
+func mostTrafficExcept(traffic []serving.TrafficTarget, except string) string {
+	var others []serving.TrafficTarget
+	for _, t := range traffic {
+		if t.RevisionName != except {
+			others = append(others, t)
+		}
+	}
+
+	return mostTraffic(others)
+}
@@ -0,0 +1,108 @@
+package traffic_test
+
+import (
+	"testing"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	"github.com/julz/knightrider/pkg/traffic"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPreviewRoute(t *testing.T) {
+	r := knative.NewRoute("route", knative.WithTrafficToConfiguration("", "config", 100), knative.WithTrafficToRevision("green", "older", 0))
+	r.Status.Domain = "route.default.example.com"
+	r.Labels = map[string]string{"team": "a"}
+
+	objects, url, err := traffic.Preview(kube.NewFake(r), kube.Routes, "route", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, objects[0].(*serving.Route).Labels, map[string]string{"team": "a"}, "expected route to keep labels '%v' but had '%v'")
+
+	errorIfNotEqual(t, objects[0].(*serving.Route).Spec.Traffic, []serving.TrafficTarget{
+		{ConfigurationName: "config", Percent: 100},
+		{Name: "green", RevisionName: "new", Percent: 0},
+	}, "expected route traffic to be '%v' but was '%v'")
+
+	errorIfNotEqual(t, url, "http://green.route.default.example.com", "expected preview url to be '%s' but was '%s'")
+}
+
+func TestPromoteRoute(t *testing.T) {
+	r := knative.NewRoute("route",
+		knative.WithTrafficToConfiguration("", "config", 90),
+		knative.WithTrafficToRevision("canary", "other", 10),
+		knative.WithTrafficToRevision("green", "new", 0),
+	)
+	r.Status.Domain = "route.default.example.com"
+	r.Status.Traffic = []serving.TrafficTarget{{RevisionName: "old", Percent: 90}, {Name: "canary", RevisionName: "other", Percent: 10}, {Name: "green", RevisionName: "new", Percent: 0}}
+
+	objects, url, err := traffic.Promote(kube.NewFake(r), kube.Routes, "route", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, objects[0].(*serving.Route).Spec.Traffic, []serving.TrafficTarget{
+		{Name: "green", RevisionName: "new", Percent: 100},
+		{Name: "blue", RevisionName: "old", Percent: 0},
+		{ConfigurationName: "config", Percent: 0},
+		{Name: "canary", RevisionName: "other", Percent: 0},
+	}, "expected route traffic to be '%v' but was '%v'")
+
+	errorIfNotEqual(t, url, "http://blue.route.default.example.com", "expected blue url to be '%s' but was '%s'")
+}
+
+func TestPreviewService(t *testing.T) {
+	s := knative.NewRunLatestService("svc")
+	s.Status.Domain = "svc.default.example.com"
+	s.Status.Traffic = []serving.TrafficTarget{{RevisionName: "svc-00001", Percent: 100}}
+
+	objects, url, err := traffic.Preview(kube.NewFake(s), kube.Services, "svc", "svc-00002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	preview := objects[0].(*serving.Route)
+	errorIfNotEqual(t, preview.Name, "svc-preview", "expected preview route to be called '%s' but was '%s'")
+	errorIfNotEqual(t, preview.Spec.Traffic, []serving.TrafficTarget{
+		{Name: "blue", RevisionName: "svc-00001", Percent: 100},
+		{Name: "green", RevisionName: "svc-00002", Percent: 0},
+	}, "expected preview route traffic to be '%v' but was '%v'")
+
+	errorIfNotEqual(t, url, "http://green.svc-preview.default.example.com", "expected preview url to be '%s' but was '%s'")
+}
+
+func TestPromoteService(t *testing.T) {
+	s := knative.NewPinnedService("svc", "svc-00001", knative.WithRevisionTemplate("image", nil, nil))
+	s.Annotations = map[string]string{knative.GitCommitAnnotation: "abc123", "kubectl.kubernetes.io/last-applied-configuration": "{}"}
+	c := kube.NewFake(
+		s,
+		revision("svc-00001", "svc", 1, corev1.ConditionTrue),
+		revision("svc-00002", "svc", 2, corev1.ConditionTrue),
+		revision("svc-00003", "svc", 3, corev1.ConditionFalse),
+		revision("other-00001", "other", 1, corev1.ConditionTrue),
+	)
+
+	objects, _, err := traffic.Promote(c, kube.Services, "svc", "svc-00002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinned := objects[0].(*serving.Service)
+	errorIfNotEqual(t, pinned.Annotations, map[string]string{knative.GitCommitAnnotation: "abc123"}, "expected service to keep annotations '%v' but had '%v'")
+	errorIfNotEqual(t, pinned.Spec.Pinned.RevisionName, "svc-00002", "expected service to be pinned to '%s' but was '%s'")
+	errorIfNotEqual(t, pinned.Spec.Pinned.Configuration.RevisionTemplate.Spec.Container.Image, "image", "expected service to keep image '%s' but was '%s'")
+
+	errorIfNotEqual(t, objects[1].(*serving.Route).Spec.Traffic, []serving.TrafficTarget{
+		{Name: "green", RevisionName: "svc-00002", Percent: 100},
+		{Name: "blue", RevisionName: "svc-00001", Percent: 0},
+	}, "expected preview route traffic to be '%v' but was '%v'")
+
+	for _, green := range []string{"svc-00007", "svc-00003", "other-00001"} {
+		if _, _, err := traffic.Promote(c, kube.Services, "svc", green); err == nil {
+			t.Errorf("expected an error promoting %s", green)
+		}
+	}
+}
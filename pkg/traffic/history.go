@@ -0,0 +1,78 @@
+package traffic
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/julz/knightrider/pkg/kube"
+	knativeserving "github.com/knative/serving/pkg/apis/serving"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Revision summarises a Revision of a Configuration
+type Revision struct {
+	Name       string
+	Created    time.Time
+	Image      string
+	Generation string
+	Ready      corev1.ConditionStatus
+	Percent    int
+}
+
+// ServiceHistory returns the Revisions of the named Service, oldest first, along
+// with the percentage of the Service's traffic each of them currently receives
+func ServiceHistory(c kube.Client, name string) ([]Revision, error) {
+	var s serving.Service
+	if err := c.Get(kube.Services, name, &s); err != nil {
+		return nil, err
+	}
+
+	revisions, err := configurationRevisions(c, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []Revision
+	for _, r := range revisions {
+		ready := corev1.ConditionUnknown
+		if cond := r.Status.GetCondition(serving.RevisionConditionReady); cond != nil {
+			ready = cond.Status
+		}
+
+		percent := 0
+		for _, t := range s.Status.Traffic {
+			if t.RevisionName == r.Name {
+				percent += t.Percent
+			}
+		}
+
+		history = append(history, Revision{
+			Name:       r.Name,
+			Created:    r.CreationTimestamp.Time,
+			Image:      r.Spec.Container.Image,
+			Generation: r.Annotations[knativeserving.ConfigurationGenerationAnnotationKey],
+			Ready:      ready,
+			Percent:    percent,
+		})
+	}
+
+	return history, nil
+}
+
+// configurationRevisions returns the Revisions created by the named
+// Configuration, oldest first
+func configurationRevisions(c kube.Client, configuration string) ([]serving.Revision, error) {
+	var list serving.RevisionList
+	if err := c.List(kube.Revisions, fmt.Sprintf("%s=%s", knativeserving.ConfigurationLabelKey, configuration), &list); err != nil {
+		return nil, err
+	}
+
+	revisions := list.Items
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].CreationTimestamp.Before(&revisions[j].CreationTimestamp)
+	})
+
+	return revisions, nil
+}
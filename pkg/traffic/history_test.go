@@ -0,0 +1,66 @@
+package traffic_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	"github.com/julz/knightrider/pkg/traffic"
+	knativeserving "github.com/knative/serving/pkg/apis/serving"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var epoch = time.Date(2018, 8, 1, 0, 0, 0, 0, time.Local)
+
+func TestServiceHistory(t *testing.T) {
+	s := knative.NewRunLatestService("foo")
+	s.Status.Traffic = []serving.TrafficTarget{{RevisionName: "foo-00002", Percent: 100}}
+
+	c := kube.NewFake(
+		s,
+		revision("foo-00002", "foo", 2, corev1.ConditionTrue),
+		revision("foo-00001", "foo", 1, corev1.ConditionTrue),
+		revision("foo-00003", "foo", 3, corev1.ConditionFalse),
+		revision("bar-00001", "bar", 1, corev1.ConditionTrue),
+	)
+
+	history, err := traffic.ServiceHistory(c, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, history, []traffic.Revision{
+		{Name: "foo-00001", Created: epoch.Add(1 * time.Minute), Image: "image-1", Generation: "1", Ready: corev1.ConditionTrue},
+		{Name: "foo-00002", Created: epoch.Add(2 * time.Minute), Image: "image-2", Generation: "2", Ready: corev1.ConditionTrue, Percent: 100},
+		{Name: "foo-00003", Created: epoch.Add(3 * time.Minute), Image: "image-3", Generation: "3", Ready: corev1.ConditionFalse},
+	}, "expected history to be '%v' but was '%v'")
+}
+
+func revision(name, configuration string, generation int, ready corev1.ConditionStatus) *serving.Revision {
+	return &serving.Revision{
+		TypeMeta: metav1.TypeMeta{APIVersion: "serving.knative.dev/v1alpha1", Kind: "Revision"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(epoch.Add(time.Duration(generation) * time.Minute)),
+			Labels:            map[string]string{knativeserving.ConfigurationLabelKey: configuration},
+			Annotations:       map[string]string{knativeserving.ConfigurationGenerationAnnotationKey: strconv.Itoa(generation)},
+		},
+		Spec: serving.RevisionSpec{
+			Container: corev1.Container{Image: "image-" + strconv.Itoa(generation)},
+		},
+		Status: serving.RevisionStatus{
+			Conditions: []serving.RevisionCondition{{Type: serving.RevisionConditionReady, Status: ready}},
+		},
+	}
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}
@@ -0,0 +1,44 @@
+package traffic
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPProbe returns a probe which makes the given number of GET requests to url
+// and fails if fewer than minSuccessRate of them succeed. Any response other
+// than a 5xx is counted as a success
+func HTTPProbe(url string, requests int, minSuccessRate float64) func() error {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return func() error {
+		// with no requests there's no success rate, so nothing to pass
+		if requests < 1 {
+			return fmt.Errorf("the probe needs to make at least 1 request, not %d", requests)
+		}
+
+		succeeded := 0
+		var lastErr error
+		for i := 0; i < requests; i++ {
+			resp, err := client.Get(url)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				succeeded++
+			} else {
+				lastErr = fmt.Errorf("%s returned %s", url, resp.Status)
+			}
+		}
+
+		if rate := float64(succeeded) / float64(requests); rate < minSuccessRate {
+			return fmt.Errorf("success rate %.2f is below %.2f, last error: %s", rate, minSuccessRate, lastErr)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,194 @@
+package traffic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	knativeserving "github.com/knative/serving/pkg/apis/serving"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+)
+
+// RollbackService returns the named Service pinned to revision, which must be
+// a ready Revision of the Service. If revision is empty the Service is pinned
+// to the newest ready Revision created before the one it is currently serving.
+// The Service keeps its labels and annotations
+func RollbackService(c kube.Client, name, revision string) (*serving.Service, error) {
+	var s serving.Service
+	if err := c.Get(kube.Services, name, &s); err != nil {
+		return nil, err
+	}
+
+	config := knative.ServiceConfiguration(&s)
+	if config == nil {
+		return nil, fmt.Errorf("service %s is neither runLatest nor pinned", name)
+	}
+
+	if revision == "" {
+		current := s.Status.LatestReadyRevisionName
+		if s.Spec.Pinned != nil {
+			current = s.Spec.Pinned.RevisionName
+		} else if r := mostTraffic(s.Status.Traffic); r != "" {
+			current = r
+		}
+
+		var err error
+		if revision, err = previousRevision(c, name, current); err != nil {
+			return nil, err
+		}
+	} else if err := checkRevision(c, revision, map[string]bool{name: true}); err != nil {
+		return nil, err
+	}
+
+	pinned := knative.NewPinnedService(name, revision, func(spec *serving.ConfigurationSpec) {
+		*spec = *config
+	})
+
+	pinned.ObjectMeta = knative.UserMeta(s.ObjectMeta)
+	return pinned, nil
+}
+
+// RollbackRoute returns the named Route with all of its traffic sent to
+// revision, which must be a ready Revision of a Configuration the Route sends
+// traffic to. If revision is empty, traffic is sent to the newest ready
+// Revision created before the one currently receiving the most traffic. The
+// Route keeps its named targets, with no traffic, and its labels and
+// annotations
+func RollbackRoute(c kube.Client, name, revision string) (*serving.Route, error) {
+	var r serving.Route
+	if err := c.Get(kube.Routes, name, &r); err != nil {
+		return nil, err
+	}
+
+	if revision == "" {
+		current := mostTraffic(r.Status.Traffic)
+		if current == "" {
+			return nil, fmt.Errorf("route %s is not currently routing traffic to any revision", name)
+		}
+
+		var rev serving.Revision
+		if err := c.Get(kube.Revisions, current, &rev); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if revision, err = previousRevision(c, rev.Labels[knativeserving.ConfigurationLabelKey], current); err != nil {
+			return nil, err
+		}
+	} else {
+		configurations, err := routeConfigurations(c, r)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkRevision(c, revision, configurations); err != nil {
+			return nil, err
+		}
+	}
+
+	// named targets, like the blue and green of a blue/green deploy, stay
+	// reachable at their own urls
+	options := []knative.RouteOption{knative.WithTrafficToRevision("", revision, 100)}
+	options = append(options, trafficOptions(parked(r.Spec.Traffic, func(t serving.TrafficTarget) bool {
+		return t.Name != ""
+	}))...)
+
+	rolledBack := knative.NewRoute(name, options...)
+	rolledBack.ObjectMeta = knative.UserMeta(r.ObjectMeta)
+	return rolledBack, nil
+}
+
+// checkRevision returns an error unless revision exists, belongs to one of
+// configurations and is ready
+func checkRevision(c kube.Client, revision string, configurations map[string]bool) error {
+	var rev serving.Revision
+	if err := c.Get(kube.Revisions, revision, &rev); err != nil {
+		if kube.IsNotFound(err) {
+			return fmt.Errorf("revision %s does not exist", revision)
+		}
+
+		return err
+	}
+
+	if configuration := rev.Labels[knativeserving.ConfigurationLabelKey]; !configurations[configuration] {
+		var names []string
+		for name := range configurations {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		return fmt.Errorf("revision %s belongs to configuration %s, not %s", revision, configuration, strings.Join(names, " or "))
+	}
+
+	if !rev.Status.IsReady() {
+		return fmt.Errorf("revision %s is not ready, so can't be rolled back to", revision)
+	}
+
+	return nil
+}
+
+// routeConfigurations returns the Configurations a Route sends traffic to,
+// either directly or through one of their Revisions
+func routeConfigurations(c kube.Client, r serving.Route) (map[string]bool, error) {
+	configurations := make(map[string]bool)
+	for _, t := range append(r.Spec.Traffic, r.Status.Traffic...) {
+		if t.ConfigurationName != "" {
+			configurations[t.ConfigurationName] = true
+		}
+
+		if t.RevisionName != "" {
+			var rev serving.Revision
+			if err := c.Get(kube.Revisions, t.RevisionName, &rev); err != nil {
+				if kube.IsNotFound(err) {
+					continue
+				}
+
+				return nil, err
+			}
+
+			configurations[rev.Labels[knativeserving.ConfigurationLabelKey]] = true
+		}
+	}
+
+	return configurations, nil
+}
+
+// previousRevision returns the newest ready Revision of configuration created
+// before current
+func previousRevision(c kube.Client, configuration, current string) (string, error) {
+	revisions, err := configurationRevisions(c, configuration)
+	if err != nil {
+		return "", err
+	}
+
+	previous := ""
+	for _, r := range revisions {
+		if r.Name == current {
+			if previous == "" {
+				return "", fmt.Errorf("no ready revision of %s older than %s to roll back to", configuration, current)
+			}
+
+			return previous, nil
+		}
+
+		if r.Status.IsReady() {
+			previous = r.Name
+		}
+	}
+
+	return "", fmt.Errorf("current revision %s not found in the revisions of %s", current, configuration)
+}
+
+// mostTraffic returns the name of the revision receiving the most traffic
+func mostTraffic(traffic []serving.TrafficTarget) string {
+	name, max := "", -1
+	for _, t := range traffic {
+		if t.RevisionName != "" && t.Percent > max {
+			name, max = t.RevisionName, t.Percent
+		}
+	}
+
+	return name
+}
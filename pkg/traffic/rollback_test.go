@@ -0,0 +1,120 @@
+package traffic_test
+
+import (
+	"testing"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	"github.com/julz/knightrider/pkg/traffic"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRollbackServiceToPreviousReadyRevision(t *testing.T) {
+	s := knative.NewRunLatestService("foo", knative.WithRevisionTemplate("image", nil, nil))
+	s.Status.Traffic = []serving.TrafficTarget{{RevisionName: "foo-00003", Percent: 100}}
+
+	c := kube.NewFake(
+		s,
+		revision("foo-00001", "foo", 1, corev1.ConditionTrue),
+		revision("foo-00002", "foo", 2, corev1.ConditionFalse),
+		revision("foo-00003", "foo", 3, corev1.ConditionTrue),
+	)
+
+	rolledBack, err := traffic.RollbackService(c, "foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, rolledBack.Spec.Pinned.RevisionName, "foo-00001", "expected service to be pinned to '%s' but was '%s'")
+	errorIfNotEqual(t, rolledBack.Spec.Pinned.Configuration.RevisionTemplate.Spec.Container.Image, "image", "expected service to keep image '%s' but was '%s'")
+}
+
+func TestRollbackServiceToRevision(t *testing.T) {
+	s := knative.NewPinnedService("foo", "foo-00002")
+	s.Labels = map[string]string{"team": "a"}
+	s.Annotations = map[string]string{knative.GitCommitAnnotation: "abc123", "kubectl.kubernetes.io/last-applied-configuration": "{}"}
+
+	c := kube.NewFake(
+		s,
+		revision("foo-00001", "foo", 1, corev1.ConditionTrue),
+		revision("foo-00002", "foo", 2, corev1.ConditionTrue),
+		revision("foo-00003", "foo", 3, corev1.ConditionFalse),
+		revision("bar-00001", "bar", 1, corev1.ConditionTrue),
+	)
+
+	rolledBack, err := traffic.RollbackService(c, "foo", "foo-00001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, rolledBack.Spec.Pinned.RevisionName, "foo-00001", "expected service to be pinned to '%s' but was '%s'")
+	errorIfNotEqual(t, rolledBack.Labels, map[string]string{"team": "a"}, "expected service to keep labels '%v' but had '%v'")
+	errorIfNotEqual(t, rolledBack.Annotations, map[string]string{knative.GitCommitAnnotation: "abc123"}, "expected service to keep annotations '%v' but had '%v'")
+
+	for _, to := range []string{"foo-00007", "foo-00003", "bar-00001"} {
+		if _, err := traffic.RollbackService(c, "foo", to); err == nil {
+			t.Errorf("expected an error rolling back to %s", to)
+		}
+	}
+}
+
+func TestRollbackServiceWithNoPreviousRevision(t *testing.T) {
+	c := kube.NewFake(
+		knative.NewPinnedService("foo", "foo-00001"),
+		revision("foo-00001", "foo", 1, corev1.ConditionTrue),
+	)
+
+	if _, err := traffic.RollbackService(c, "foo", ""); err == nil {
+		t.Errorf("expected an error when there is no previous revision")
+	}
+}
+
+func TestRollbackRoute(t *testing.T) {
+	r := knative.NewRoute("foo")
+	r.Status.Traffic = []serving.TrafficTarget{
+		{RevisionName: "config-00001", Percent: 10},
+		{RevisionName: "config-00002", Percent: 90},
+	}
+
+	c := kube.NewFake(
+		r,
+		revision("config-00001", "config", 1, corev1.ConditionTrue),
+		revision("config-00002", "config", 2, corev1.ConditionTrue),
+	)
+
+	rolledBack, err := traffic.RollbackRoute(c, "foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, rolledBack.Spec.Traffic, []serving.TrafficTarget{
+		{RevisionName: "config-00001", Percent: 100},
+	}, "expected route traffic to be '%v' but was '%v'")
+}
+
+func TestRollbackRouteToRevision(t *testing.T) {
+	r := knative.NewRoute("foo", knative.WithTrafficToConfiguration("", "config", 100), knative.WithTrafficToRevision("blue", "config-00002", 0))
+	r.Labels = map[string]string{"team": "a"}
+
+	c := kube.NewFake(
+		r,
+		revision("config-00001", "config", 1, corev1.ConditionTrue),
+		revision("other-00001", "other", 1, corev1.ConditionTrue),
+	)
+
+	rolledBack, err := traffic.RollbackRoute(c, "foo", "config-00001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, rolledBack.Spec.Traffic, []serving.TrafficTarget{
+		{RevisionName: "config-00001", Percent: 100},
+		{Name: "blue", RevisionName: "config-00002", Percent: 0},
+	}, "expected route traffic to be '%v' but was '%v'")
+	errorIfNotEqual(t, rolledBack.Labels, map[string]string{"team": "a"}, "expected route to keep labels '%v' but had '%v'")
+
+	if _, err := traffic.RollbackRoute(c, "foo", "other-00001"); err == nil {
+		t.Error("expected an error rolling back to a revision of a configuration the route doesn't use")
+	}
+}
@@ -0,0 +1,355 @@
+package traffic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// RolloutAnnotation records the state of an in-progress rollout on its Route, so
+// that an interrupted rollout can be resumed
+const RolloutAnnotation = knative.AnnotationPrefix + "rollout"
+
+// Rollout is the state of a progressive shift of a Route's traffic to a Revision
+type Rollout struct {
+	Revision string `json:"revision"`
+	Steps    []int  `json:"steps"`
+
+	// Step is the index in to Steps of the step currently being rolled out
+	Step int `json:"step"`
+
+	// Original is the Route's traffic before the rollout started, which is
+	// restored if the rollout is aborted
+	Original []serving.TrafficTarget `json:"original"`
+}
+
+// Rollouter shifts a Route's traffic to a Revision in steps, checking the Route
+// is healthy between each step and aborting back to the original split if not
+type Rollouter struct {
+	Client kube.Client
+
+	// Interval is how long to wait between steps
+	Interval time.Duration
+
+	// ReadyTimeout is how long to wait for the Route and Revision to become ready after each step
+	ReadyTimeout time.Duration
+
+	// PollInterval is how often to check readiness, defaults to 2 seconds
+	PollInterval time.Duration
+
+	// Probe, if set, is called after each step's interval and aborts the rollout if it fails
+	Probe func() error
+
+	// Log receives progress messages, defaults to ioutil.Discard
+	Log io.Writer
+
+	// Sleep defaults to time.Sleep, and can be replaced in tests
+	Sleep func(time.Duration)
+}
+
+// Start begins rolling out revision to the named Route in the given steps, each
+// of which is a percentage of traffic. If the Route already has an in-progress
+// rollout to the same revision (or revision is empty), it is resumed instead
+func (r *Rollouter) Start(route, revision string, steps []int) error {
+	var existing serving.Route
+	if err := r.Client.Get(kube.Routes, route, &existing); err != nil {
+		return err
+	}
+
+	if saved, ok := existing.Annotations[RolloutAnnotation]; ok {
+		rollout, err := decodeRollout(saved)
+		if err != nil {
+			return fmt.Errorf("could not read saved rollout of %s: %s", route, err)
+		}
+
+		if revision == "" || revision == rollout.Revision {
+			fmt.Fprintf(r.log(), "resuming rollout of %s to %s at %d%%\n", route, rollout.Revision, rollout.Steps[rollout.Step])
+			return r.run(route, rollout)
+		}
+
+		return fmt.Errorf("route %s already has a rollout to %s in progress", route, rollout.Revision)
+	}
+
+	if revision == "" {
+		return fmt.Errorf("route %s has no rollout in progress to resume", route)
+	}
+
+	if err := validateSteps(steps); err != nil {
+		return err
+	}
+
+	rollout := Rollout{
+		Revision: revision,
+		Steps:    steps,
+		Original: existing.Spec.Traffic,
+	}
+
+	if _, err := Split(rollout.Original, revision, steps[0]); err != nil {
+		return err
+	}
+
+	return r.run(route, rollout)
+}
+
+func (r *Rollouter) run(route string, rollout Rollout) error {
+	for ; rollout.Step < len(rollout.Steps); rollout.Step++ {
+		percent := rollout.Steps[rollout.Step]
+		fmt.Fprintf(r.log(), "routing %d%% of %s to %s\n", percent, route, rollout.Revision)
+
+		if err := r.apply(route, rollout); err != nil {
+			return err
+		}
+
+		if err := r.waitReady(route, rollout.Revision); err != nil {
+			return r.abort(route, rollout, err)
+		}
+
+		r.sleep(r.Interval)
+
+		if err := r.check(route, rollout.Revision); err != nil {
+			return r.abort(route, rollout, err)
+		}
+	}
+
+	// re-apply the final step without the rollout annotation to mark the rollout complete
+	rollout.Step = len(rollout.Steps) - 1
+	split, _ := Split(rollout.Original, rollout.Revision, rollout.Steps[rollout.Step])
+	rt, err := r.route(route, split)
+	if err != nil {
+		return err
+	}
+
+	return r.Client.Apply(rt)
+}
+
+func (r *Rollouter) apply(route string, rollout Rollout) error {
+	split, err := Split(rollout.Original, rollout.Revision, rollout.Steps[rollout.Step])
+	if err != nil {
+		return err
+	}
+
+	state, err := json.Marshal(rollout)
+	if err != nil {
+		return err
+	}
+
+	rt, err := r.route(route, split)
+	if err != nil {
+		return err
+	}
+
+	knative.Annotate(&rt.ObjectMeta, RolloutAnnotation, string(state))
+	return r.Client.Apply(rt)
+}
+
+func (r *Rollouter) abort(route string, rollout Rollout, cause error) error {
+	fmt.Fprintf(r.log(), "aborting rollout of %s: %s\n", route, cause)
+	rt, err := r.route(route, rollout.Original)
+	if err == nil {
+		err = r.Client.Apply(rt)
+	}
+
+	if err != nil {
+		return fmt.Errorf("rollout failed (%s) and could not be aborted: %s", cause, err)
+	}
+
+	return fmt.Errorf("rollout aborted, traffic restored to the original split: %s", cause)
+}
+
+// route returns the named Route with the given traffic, keeping its labels and
+// annotations, except for any saved rollout
+func (r *Rollouter) route(name string, traffic []serving.TrafficTarget) (*serving.Route, error) {
+	var existing serving.Route
+	if err := r.Client.Get(kube.Routes, name, &existing); err != nil {
+		return nil, err
+	}
+
+	rt := knative.NewRoute(name, trafficOptions(traffic)...)
+	rt.ObjectMeta = knative.UserMeta(existing.ObjectMeta)
+	if rt.Annotations != nil {
+		delete(rt.Annotations, RolloutAnnotation)
+	}
+
+	return rt, nil
+}
+
+// waitReady waits for the Route and Revision to become ready, failing if either
+// becomes not ready or ReadyTimeout passes
+func (r *Rollouter) waitReady(route, revision string) error {
+	poll := r.PollInterval
+	if poll == 0 {
+		poll = 2 * time.Second
+	}
+
+	var err error
+	for waited := time.Duration(0); ; waited += poll {
+		var status corev1.ConditionStatus
+		if status, err = r.readiness(route, revision); status != corev1.ConditionUnknown {
+			return err
+		}
+
+		if waited >= r.ReadyTimeout {
+			return fmt.Errorf("timed out after %s: %s", r.ReadyTimeout, err)
+		}
+
+		r.sleep(poll)
+	}
+}
+
+// check verifies that the Route and Revision are still ready, and runs the probe
+func (r *Rollouter) check(route, revision string) error {
+	if status, err := r.readiness(route, revision); status != corev1.ConditionTrue {
+		return err
+	}
+
+	if r.Probe != nil {
+		return r.Probe()
+	}
+
+	return nil
+}
+
+// readiness returns the combined readiness of the Route and Revision, with an
+// error describing why they are not ready if they aren't
+func (r *Rollouter) readiness(route, revision string) (corev1.ConditionStatus, error) {
+	var rt serving.Route
+	if err := r.Client.Get(kube.Routes, route, &rt); err != nil {
+		return corev1.ConditionFalse, err
+	}
+
+	if rt.Status.ObservedGeneration < rt.Spec.Generation {
+		return corev1.ConditionUnknown, fmt.Errorf("route %s has not observed the latest generation", route)
+	}
+
+	if c := rt.Status.GetCondition(serving.RouteConditionReady); c == nil {
+		return corev1.ConditionUnknown, fmt.Errorf("route %s has no Ready condition yet", route)
+	} else if c.Status != corev1.ConditionTrue {
+		return c.Status, fmt.Errorf("route %s is not ready: %s %s", route, c.Reason, c.Message)
+	}
+
+	var rev serving.Revision
+	if err := r.Client.Get(kube.Revisions, revision, &rev); err != nil {
+		return corev1.ConditionFalse, err
+	}
+
+	if c := rev.Status.GetCondition(serving.RevisionConditionReady); c == nil {
+		return corev1.ConditionUnknown, fmt.Errorf("revision %s has no Ready condition yet", revision)
+	} else if c.Status != corev1.ConditionTrue {
+		return c.Status, fmt.Errorf("revision %s is not ready: %s %s", revision, c.Reason, c.Message)
+	}
+
+	return corev1.ConditionTrue, nil
+}
+
+func (r *Rollouter) log() io.Writer {
+	if r.Log == nil {
+		return ioutil.Discard
+	}
+
+	return r.Log
+}
+
+func (r *Rollouter) sleep(d time.Duration) {
+	if r.Sleep == nil {
+		time.Sleep(d)
+		return
+	}
+
+	r.Sleep(d)
+}
+
+// Split returns the traffic targets which send percent of traffic to revision,
+// sharing the rest between the other targets in proportion to their current traffic
+func Split(targets []serving.TrafficTarget, revision string, percent int) ([]serving.TrafficTarget, error) {
+	canary := serving.TrafficTarget{RevisionName: revision, Percent: percent}
+
+	var others []serving.TrafficTarget
+	total := 0
+	for _, t := range targets {
+		if t.RevisionName == revision {
+			canary.Name = t.Name
+			continue
+		}
+
+		others = append(others, t)
+		total += t.Percent
+	}
+
+	rest := 100 - percent
+	if rest > 0 && total == 0 {
+		return nil, fmt.Errorf("cannot route %d%% of traffic to %s: there is no other traffic to share the remaining %d%% with", percent, revision, rest)
+	}
+
+	largest, assigned := -1, 0
+	for i := range others {
+		share := others[i].Percent * rest / total
+		if largest < 0 || others[i].Percent > others[largest].Percent {
+			largest = i
+		}
+
+		others[i].Percent = share
+		assigned += share
+	}
+
+	if largest >= 0 {
+		others[largest].Percent += rest - assigned
+	}
+
+	return append(others, canary), nil
+}
+
+func trafficOptions(targets []serving.TrafficTarget) []knative.RouteOption {
+	var options []knative.RouteOption
+	for _, t := range targets {
+		if t.ConfigurationName != "" {
+			options = append(options, knative.WithTrafficToConfiguration(t.Name, t.ConfigurationName, t.Percent))
+		} else {
+			options = append(options, knative.WithTrafficToRevision(t.Name, t.RevisionName, t.Percent))
+		}
+	}
+
+	return options
+}
+
+// decodeRollout reads the Rollout saved in a RolloutAnnotation, checking its
+// step is one of its steps, since the annotation may have been edited by hand
+func decodeRollout(saved string) (Rollout, error) {
+	var rollout Rollout
+	if err := json.Unmarshal([]byte(saved), &rollout); err != nil {
+		return rollout, err
+	}
+
+	if err := validateSteps(rollout.Steps); err != nil {
+		return rollout, err
+	}
+
+	if rollout.Step < 0 || rollout.Step >= len(rollout.Steps) {
+		return rollout, fmt.Errorf("step %d is not one of the %d steps %v", rollout.Step, len(rollout.Steps), rollout.Steps)
+	}
+
+	return rollout, nil
+}
+
+func validateSteps(steps []int) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("at least one step is required")
+	}
+
+	last := 0
+	for _, s := range steps {
+		if s <= last || s > 100 {
+			return fmt.Errorf("steps must be increasing percentages between 1 and 100, got %v", steps)
+		}
+
+		last = s
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package traffic_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	"github.com/julz/knightrider/pkg/traffic"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSplit(t *testing.T) {
+	split, err := traffic.Split([]serving.TrafficTarget{
+		{RevisionName: "a", Percent: 60},
+		{Name: "b", ConfigurationName: "config", Percent: 30},
+		{Name: "canary", RevisionName: "c", Percent: 10},
+	}, "c", 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, split, []serving.TrafficTarget{
+		{RevisionName: "a", Percent: 50},
+		{Name: "b", ConfigurationName: "config", Percent: 25},
+		{Name: "canary", RevisionName: "c", Percent: 25},
+	}, "expected split to be '%v' but was '%v'")
+}
+
+func TestSplitWithNoOtherTraffic(t *testing.T) {
+	if _, err := traffic.Split(nil, "c", 10); err == nil {
+		t.Errorf("expected an error when there is nothing to share the remaining traffic with")
+	}
+
+	split, err := traffic.Split(nil, "c", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, split, []serving.TrafficTarget{{RevisionName: "c", Percent: 100}}, "expected split to be '%v' but was '%v'")
+}
+
+func TestRollout(t *testing.T) {
+	c := rolloutFake(corev1.ConditionTrue)
+
+	var slept []time.Duration
+	r := &traffic.Rollouter{Client: c, Interval: time.Minute, ReadyTimeout: time.Minute, Sleep: func(d time.Duration) { slept = append(slept, d) }}
+	if err := r.Start("route", "new", []int{10, 50, 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, appliedTraffic(c), [][]serving.TrafficTarget{
+		{{RevisionName: "old", Percent: 90}, {RevisionName: "new", Percent: 10}},
+		{{RevisionName: "old", Percent: 50}, {RevisionName: "new", Percent: 50}},
+		{{RevisionName: "old", Percent: 0}, {RevisionName: "new", Percent: 100}},
+		{{RevisionName: "old", Percent: 0}, {RevisionName: "new", Percent: 100}},
+	}, "expected applied traffic to be '%v' but was '%v'")
+
+	errorIfNotEqual(t, slept, []time.Duration{time.Minute, time.Minute, time.Minute}, "expected to sleep '%v' but slept '%v'")
+
+	var final serving.Route
+	c.Get(kube.Routes, "route", &final)
+	if _, ok := final.Annotations[traffic.RolloutAnnotation]; ok {
+		t.Errorf("expected the rollout annotation to be removed when the rollout completes")
+	}
+	errorIfNotEqual(t, final.Labels, map[string]string{"team": "a"}, "expected route to keep labels '%v' but had '%v'")
+	errorIfNotEqual(t, final.Annotations, map[string]string{"owner": "me"}, "expected route to keep annotations '%v' but had '%v'")
+}
+
+func TestRolloutAbortsWhenProbeFails(t *testing.T) {
+	c := rolloutFake(corev1.ConditionTrue)
+
+	r := &traffic.Rollouter{Client: c, ReadyTimeout: time.Minute, Sleep: func(time.Duration) {}, Probe: func() error {
+		return errors.New("boom")
+	}}
+
+	if err := r.Start("route", "new", []int{10, 100}); err == nil {
+		t.Fatal("expected the rollout to fail")
+	}
+
+	errorIfNotEqual(t, appliedTraffic(c), [][]serving.TrafficTarget{
+		{{RevisionName: "old", Percent: 90}, {RevisionName: "new", Percent: 10}},
+		{{RevisionName: "old", Percent: 100}},
+	}, "expected applied traffic to be '%v' but was '%v'")
+}
+
+func TestRolloutAbortsWhenRevisionFails(t *testing.T) {
+	c := rolloutFake(corev1.ConditionFalse)
+
+	r := &traffic.Rollouter{Client: c, ReadyTimeout: time.Minute, Sleep: func(time.Duration) {}}
+	if err := r.Start("route", "new", []int{10, 100}); err == nil {
+		t.Fatal("expected the rollout to fail")
+	}
+
+	errorIfNotEqual(t, len(c.Applied), 2, "expected %d routes to be applied but were %d")
+}
+
+func TestRolloutResumes(t *testing.T) {
+	c := rolloutFake(corev1.ConditionTrue)
+
+	state, _ := json.Marshal(traffic.Rollout{
+		Revision: "new",
+		Steps:    []int{10, 50, 100},
+		Step:     1,
+		Original: []serving.TrafficTarget{{RevisionName: "old", Percent: 100}},
+	})
+
+	var route serving.Route
+	c.Get(kube.Routes, "route", &route)
+	knative.Annotate(&route.ObjectMeta, traffic.RolloutAnnotation, string(state))
+	c.Add(&route)
+
+	r := &traffic.Rollouter{Client: c, ReadyTimeout: time.Minute, Sleep: func(time.Duration) {}}
+	if err := r.Start("route", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, appliedTraffic(c)[0], []serving.TrafficTarget{
+		{RevisionName: "old", Percent: 50}, {RevisionName: "new", Percent: 50},
+	}, "expected rollout to resume with '%v' but was '%v'")
+}
+
+func TestRolloutWithStaleState(t *testing.T) {
+	for name, rollout := range map[string]traffic.Rollout{
+		"step past the end": {Revision: "new", Steps: []int{10, 50, 100}, Step: 3},
+		"negative step":     {Revision: "new", Steps: []int{10, 50, 100}, Step: -1},
+		"no steps":          {Revision: "new"},
+	} {
+		c := rolloutFake(corev1.ConditionTrue)
+		state, _ := json.Marshal(rollout)
+
+		var route serving.Route
+		c.Get(kube.Routes, "route", &route)
+		knative.Annotate(&route.ObjectMeta, traffic.RolloutAnnotation, string(state))
+		c.Add(&route)
+
+		r := &traffic.Rollouter{Client: c, ReadyTimeout: time.Minute, Sleep: func(time.Duration) {}}
+		if err := r.Start("route", "", nil); err == nil {
+			t.Errorf("%s: expected resuming the rollout to fail", name)
+		}
+
+		errorIfNotEqual(t, len(c.Applied), 0, name+": expected %d routes to be applied but were %d")
+	}
+}
+
+func TestHTTPProbeWithNoRequests(t *testing.T) {
+	if err := traffic.HTTPProbe("http://127.0.0.1:1", 0, 0.95)(); err == nil {
+		t.Error("expected a probe making no requests to fail")
+	}
+}
+
+func rolloutFake(revisionReady corev1.ConditionStatus) *kube.Fake {
+	route := knative.NewRoute("route", knative.WithTrafficToRevision("", "old", 100))
+	route.Labels = map[string]string{"team": "a"}
+	route.Annotations = map[string]string{"owner": "me"}
+	route.Status.Conditions = []serving.RouteCondition{{Type: serving.RouteConditionReady, Status: corev1.ConditionTrue}}
+
+	return kube.NewFake(route, revision("new", "config", 2, revisionReady))
+}
+
+func appliedTraffic(c *kube.Fake) [][]serving.TrafficTarget {
+	var traffic [][]serving.TrafficTarget
+	for _, o := range c.Applied {
+		traffic = append(traffic, o.(*serving.Route).Spec.Traffic)
+	}
+
+	return traffic
+}
@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/ghodss/yaml"
+	"github.com/julz/knightrider/pkg/git"
+)
+
+// Config says what to do when each repository is pushed to
+type Config struct {
+	Hooks []Hook `json:"hooks"`
+}
+
+// Hook maps pushes to a branch of a repository to a build, or to an update of
+// a service's build
+type Hook struct {
+	// Repo is the url of the repository, in any form git remotes take
+	Repo string `json:"repo"`
+
+	// Branch is the branch to build, or a glob like release-*. Defaults to
+	// master
+	Branch string `json:"branch,omitempty"`
+
+	// Secret is the secret the webhook was set up with, used to check pushes
+	// really come from the git server. SecretEnv names an environment
+	// variable to read it from instead
+	Secret    string `json:"secret,omitempty"`
+	SecretEnv string `json:"secretEnv,omitempty"`
+
+	// Build, if set, is the recipe for a Build to create for each push
+	Build *BuildRecipe `json:"build,omitempty"`
+
+	// Service, if set, is the name of a Service whose build should build each
+	// push, so that it rolls out a new revision
+	Service string `json:"service,omitempty"`
+}
+
+// BuildRecipe describes the Build to create for a push
+type BuildRecipe struct {
+	// Name is the prefix of the name of each build, which is followed by the
+	// short commit sha
+	Name string `json:"name"`
+
+	// Template, Arguments and Env configure a build template to build with
+	Template  string            `json:"template,omitempty"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+
+	// Dockerfile and Image build a Dockerfile with kaniko instead of a
+	// template (see knative.WithKanikoStep). Context defaults to the root
+	// of the repository
+	Dockerfile string `json:"dockerfile,omitempty"`
+	Context    string `json:"context,omitempty"`
+	Image      string `json:"image,omitempty"`
+
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+}
+
+// LoadConfig reads a Config from yml (or json), filling in defaults and
+// reading secrets from the environment, and checks every hook makes sense
+func LoadConfig(r io.Reader) (Config, error) {
+	var c Config
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return c, err
+	}
+
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return c, err
+	}
+
+	if len(c.Hooks) == 0 {
+		return c, fmt.Errorf("no hooks are configured")
+	}
+
+	for i := range c.Hooks {
+		h := &c.Hooks[i]
+		if h.Repo == "" {
+			return c, fmt.Errorf("hook %d has no repo", i+1)
+		}
+
+		if h.Branch == "" {
+			h.Branch = "master"
+		}
+
+		if _, err := path.Match(h.Branch, ""); err != nil {
+			return c, fmt.Errorf("%s: bad branch pattern %s", h.Repo, h.Branch)
+		}
+
+		if h.SecretEnv != "" {
+			h.Secret = os.Getenv(h.SecretEnv)
+		}
+
+		// anyone who knows the url could apply builds without the secret
+		if h.Secret == "" {
+			return c, fmt.Errorf("%s: every hook needs a secret (or a secretEnv which is set), so pushes can be verified", h.Repo)
+		}
+
+		if (h.Build == nil) == (h.Service == "") {
+			return c, fmt.Errorf("%s: a hook needs either a build or a service", h.Repo)
+		}
+
+		if b := h.Build; b != nil {
+			if b.Name == "" {
+				return c, fmt.Errorf("%s: the build needs a name", h.Repo)
+			}
+
+			if (b.Template == "") == (b.Dockerfile == "") {
+				return c, fmt.Errorf("%s: the build needs either a template or a dockerfile", h.Repo)
+			}
+
+			if b.Dockerfile != "" && b.Image == "" {
+				return c, fmt.Errorf("%s: the build needs an image to push the dockerfile's image to", h.Repo)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// forRepo returns true if the hook is for repo, which has been through
+// git.NormalizeURL
+func (h Hook) forRepo(repo string) bool {
+	return git.NormalizeURL(h.Repo) == repo
+}
+
+// forBranch returns true if the hook builds pushes to branch
+func (h Hook) forBranch(branch string) bool {
+	ok, _ := path.Match(h.Branch, branch)
+	return ok
+}
@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/julz/knightrider/pkg/git"
+)
+
+// Providers of webhooks
+const (
+	GitHub = "github"
+	GitLab = "gitlab"
+	Gitea  = "gitea"
+)
+
+// Push is a push of Commit to Branch of Repo
+type Push struct {
+	Provider string
+
+	// Repo is the url of the repository, normalized by git.NormalizeURL
+	Repo string
+
+	Branch string
+	Commit string
+}
+
+// delivery is a request from a git server, which may or may not be a push
+type delivery struct {
+	provider string
+	event    string
+	body     []byte
+	header   http.Header
+}
+
+// newDelivery works out which git server sent a request from its headers, or
+// returns an error if it doesn't look like a webhook. Gitea also sends
+// github's headers, so is checked first
+func newDelivery(header http.Header, body []byte) (*delivery, error) {
+	d := &delivery{body: body, header: header}
+	switch {
+	case header.Get("X-Gitea-Event") != "":
+		d.provider, d.event = Gitea, header.Get("X-Gitea-Event")
+	case header.Get("X-GitHub-Event") != "":
+		d.provider, d.event = GitHub, header.Get("X-GitHub-Event")
+	case header.Get("X-Gitlab-Event") != "":
+		d.provider, d.event = GitLab, header.Get("X-Gitlab-Event")
+	default:
+		return nil, fmt.Errorf("expected a github, gitlab or gitea webhook")
+	}
+
+	return d, nil
+}
+
+func (d *delivery) isPush() bool {
+	return d.event == "push" || d.event == "Push Hook"
+}
+
+// push parses the body of a push event
+func (d *delivery) push() (Push, error) {
+	var payload struct {
+		Ref         string `json:"ref"`
+		After       string `json:"after"`
+		CheckoutSHA string `json:"checkout_sha"`
+		Deleted     bool   `json:"deleted"`
+		Repository  struct {
+			CloneURL   string `json:"clone_url"`
+			GitHTTPURL string `json:"git_http_url"`
+		} `json:"repository"`
+		Project struct {
+			GitHTTPURL string `json:"git_http_url"`
+		} `json:"project"`
+	}
+
+	p := Push{Provider: d.provider}
+	if err := json.Unmarshal(d.body, &payload); err != nil {
+		return p, fmt.Errorf("could not read %s push: %s", d.provider, err)
+	}
+
+	p.Commit = payload.After
+	url := payload.Repository.CloneURL
+	if d.provider == GitLab {
+		p.Commit = payload.CheckoutSHA
+		if url = payload.Project.GitHTTPURL; url == "" {
+			url = payload.Repository.GitHTTPURL
+		}
+	}
+
+	// pushes which delete a branch have no commit to build
+	if payload.Deleted || strings.Trim(p.Commit, "0") == "" {
+		p.Commit = ""
+	}
+
+	if url == "" || !strings.HasPrefix(payload.Ref, "refs/heads/") {
+		return p, fmt.Errorf("%s push has no repository url or branch", d.provider)
+	}
+
+	p.Repo = git.NormalizeURL(url)
+	p.Branch = strings.TrimPrefix(payload.Ref, "refs/heads/")
+	return p, nil
+}
+
+// verify checks the delivery was sent by a git server which knows secret:
+// github and gitea sign the body with an HMAC, gitlab sends the secret itself
+func (d *delivery) verify(secret string) bool {
+	switch d.provider {
+	case GitHub:
+		if sig := d.header.Get("X-Hub-Signature-256"); sig != "" {
+			return validMAC(sha256.New, secret, d.body, strings.TrimPrefix(sig, "sha256="))
+		}
+
+		return validMAC(sha1.New, secret, d.body, strings.TrimPrefix(d.header.Get("X-Hub-Signature"), "sha1="))
+	case Gitea:
+		return validMAC(sha256.New, secret, d.body, d.header.Get("X-Gitea-Signature"))
+	case GitLab:
+		return subtle.ConstantTimeCompare([]byte(d.header.Get("X-Gitlab-Token")), []byte(secret)) == 1
+	}
+
+	return false
+}
+
+func validMAC(h func() hash.Hash, secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,205 @@
+package webhooks
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+)
+
+// maxPayload is the largest webhook body the Server reads
+const maxPayload = 25 << 20
+
+// Server receives push webhooks from github, gitlab and gitea, and creates the
+// builds (or updates the services) that the Hooks of its Config say to
+type Server struct {
+	Config Config
+	Client kube.Client
+
+	// Log is where each push, and what was done about it, is logged.
+	// Defaults to ioutil.Discard
+	Log io.Writer
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "webhooks are POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPayload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d, err := newDelivery(r.Header, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// other events, like github's ping, are acknowledged if they're signed
+	if !d.isPush() {
+		for _, h := range s.Config.Hooks {
+			if d.verify(h.Secret) {
+				s.respond(w, http.StatusAccepted, "ignoring %s %s event", d.provider, d.event)
+				return
+			}
+		}
+
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+
+	p, err := d.push()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var verified []Hook
+	known := false
+	for _, h := range s.Config.Hooks {
+		if !h.forRepo(p.Repo) {
+			continue
+		}
+
+		known = true
+		if d.verify(h.Secret) {
+			verified = append(verified, h)
+		}
+	}
+
+	// pushes to repos without hooks get the same response as bad signatures,
+	// so which repos have hooks can't be found out without a secret
+	if len(verified) == 0 {
+		if known {
+			fmt.Fprintf(s.log(), "%s push to %s has a bad signature\n", p.Provider, p.Repo)
+		} else {
+			fmt.Fprintf(s.log(), "%s push to %s, which has no hooks\n", p.Provider, p.Repo)
+		}
+
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+
+	if p.Commit == "" {
+		s.respond(w, http.StatusAccepted, "ignoring deletion of %s %s", p.Repo, p.Branch)
+		return
+	}
+
+	var applied []string
+	for _, h := range verified {
+		if !h.forBranch(p.Branch) {
+			continue
+		}
+
+		what, err := s.Handle(h, p)
+		if err != nil {
+			fmt.Fprintf(s.log(), "%s push of %s to %s %s: %s\n", p.Provider, p.Commit, p.Repo, p.Branch, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		applied = append(applied, what)
+	}
+
+	if len(applied) == 0 {
+		s.respond(w, http.StatusAccepted, "ignoring push to %s %s", p.Repo, p.Branch)
+		return
+	}
+
+	s.respond(w, http.StatusOK, "%s push of %s to %s %s: applied %s", p.Provider, p.Commit, p.Repo, p.Branch, strings.Join(applied, ", "))
+}
+
+// respond logs a message and sends it as the response
+func (s *Server) respond(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintln(s.log(), msg)
+
+	w.WriteHeader(status)
+	fmt.Fprintln(w, msg)
+}
+
+// Handle does what a hook says to do with a push: creates a Build of the
+// pushed commit, or updates a runLatest Service's build to build it. It
+// returns what was applied, e.g. build/app-1a2b3c4
+func (s *Server) Handle(h Hook, p Push) (string, error) {
+	if h.Build != nil {
+		b := knative.NewBuild(fmt.Sprintf("%s-%s", h.Build.Name, shortSHA(p.Commit)), buildOptions(h, p)...)
+		knative.Annotate(&b.ObjectMeta, knative.GitCommitAnnotation, p.Commit)
+		if err := s.Client.Apply(b); err != nil {
+			return "", err
+		}
+
+		return "build/" + b.Name, nil
+	}
+
+	var svc serving.Service
+	if err := s.Client.Get(kube.Services, h.Service, &svc); err != nil {
+		return "", err
+	}
+
+	// a pinned service keeps serving its pinned revision, so the revision
+	// built from the push would never get any traffic
+	if svc.Spec.Pinned != nil {
+		return "", fmt.Errorf("service %s is pinned to %s, so a new build would get no traffic", h.Service, svc.Spec.Pinned.RevisionName)
+	}
+
+	// only the spec and the user's metadata are applied, not the status and
+	// the fields the server manages
+	updated := serving.Service{TypeMeta: svc.TypeMeta, ObjectMeta: knative.UserMeta(svc.ObjectMeta), Spec: svc.Spec}
+	config := knative.ServiceConfiguration(&updated)
+	if config == nil || config.Build == nil {
+		return "", fmt.Errorf("service %s has no build to update", h.Service)
+	}
+
+	knative.WithGitSource(h.Repo, p.Commit)(config.Build)
+	knative.Annotate(&updated.ObjectMeta, knative.GitCommitAnnotation, p.Commit)
+	if err := s.Client.Apply(&updated); err != nil {
+		return "", err
+	}
+
+	return "service/" + updated.Name, nil
+}
+
+// buildOptions returns the options for the build a hook's BuildRecipe
+// describes, of the pushed commit. The repo is cloned from the url in the
+// hook, so it can be, for example, an ssh url for a private repo
+func buildOptions(h Hook, p Push) []knative.BuildSpecOption {
+	r := h.Build
+	options := []knative.BuildSpecOption{knative.WithGitSource(h.Repo, p.Commit)}
+	if r.Template != "" {
+		options = append(options, knative.WithBuildTemplate(r.Template, r.Arguments, r.Env))
+	} else {
+		options = append(options, knative.WithKanikoStep(r.Dockerfile, r.Context, r.Image))
+	}
+
+	if r.ServiceAccount != "" {
+		options = append(options, knative.WithServiceAccount(r.ServiceAccount))
+	}
+
+	return options
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+
+	return sha
+}
+
+func (s *Server) log() io.Writer {
+	if s.Log == nil {
+		return ioutil.Discard
+	}
+
+	return s.Log
+}
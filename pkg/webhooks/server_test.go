@@ -0,0 +1,225 @@
+package webhooks_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/julz/knightrider/pkg/knative"
+	"github.com/julz/knightrider/pkg/kube"
+	"github.com/julz/knightrider/pkg/webhooks"
+	build "github.com/knative/build/pkg/apis/build/v1alpha1"
+	serving "github.com/knative/serving/pkg/apis/serving/v1alpha1"
+)
+
+const secret = "s3cr3t"
+
+var config = webhooks.Config{Hooks: []webhooks.Hook{
+	{
+		Repo:   "git@github.com:julz/hello.git",
+		Branch: "master",
+		Secret: secret,
+		Build:  &webhooks.BuildRecipe{Name: "hello", Template: "buildpack", Arguments: map[string]string{"IMAGE": "gcr.io/me/hello"}},
+	},
+	{
+		Repo:    "http://example.com/mike/diaspora.git",
+		Branch:  "release-*",
+		Secret:  secret,
+		Service: "diaspora",
+	},
+	{
+		Repo:   "http://localhost:3000/gitea/webhooks",
+		Branch: "develop",
+		Secret: secret,
+		Build:  &webhooks.BuildRecipe{Name: "webhooks", Dockerfile: "Dockerfile", Image: "gcr.io/me/webhooks"},
+	},
+}}
+
+func TestGitHubPush(t *testing.T) {
+	c := kube.NewFake()
+	rec := deliver(&webhooks.Server{Config: config, Client: c}, githubRequest(t, "push", "github-push.json", secret))
+
+	errorIfNotEqual(t, rec.Code, http.StatusOK, "expected status %d but got %d")
+
+	var b build.Build
+	if err := c.Get(kube.Builds, "hello-0d1a26e", &b); err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, b.Spec.Source.Git, &build.GitSourceSpec{Url: "git@github.com:julz/hello.git", Revision: "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c"}, "expected git source '%v' but got '%v'")
+	errorIfNotEqual(t, b.Spec.Template.Name, "buildpack", "expected template '%s' but got '%s'")
+	errorIfNotEqual(t, b.Annotations[knative.GitCommitAnnotation], "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c", "expected commit annotation '%s' but got '%s'")
+}
+
+func TestGitLabPushUpdatesService(t *testing.T) {
+	existing := knative.NewRunLatestService("diaspora",
+		knative.WithBuild(knative.WithGitSource("http://example.com/mike/diaspora.git", "master"), knative.WithBuildTemplate("kaniko", nil, nil)),
+		knative.WithImage("gcr.io/me/diaspora"),
+	)
+	existing.Labels = map[string]string{"team": "a"}
+	existing.Annotations = map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"}
+	existing.ResourceVersion = "42"
+	existing.Status.Domain = "diaspora.default.example.com"
+	c := kube.NewFake(existing)
+
+	rec := deliver(&webhooks.Server{Config: config, Client: c}, gitlabRequest(t))
+
+	errorIfNotEqual(t, rec.Code, http.StatusOK, "expected status %d but got %d")
+
+	svc := c.Applied[0].(*serving.Service)
+	errorIfNotEqual(t, svc.Spec.RunLatest.Configuration.Build.Source.Git.Revision, "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", "expected service to build revision '%s' but it builds '%s'")
+	errorIfNotEqual(t, svc.Spec.RunLatest.Configuration.Build.Template.Name, "kaniko", "expected service to keep template '%s' but got '%s'")
+	errorIfNotEqual(t, svc.Labels, map[string]string{"team": "a"}, "expected service to keep labels '%v' but had '%v'")
+	errorIfNotEqual(t, svc.Annotations, map[string]string{knative.GitCommitAnnotation: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"}, "expected service annotations to be '%v' but were '%v'")
+	errorIfNotEqual(t, svc.ResourceVersion, "", "expected the resource version to be left to the server, '%s', but was '%s'")
+	errorIfNotEqual(t, svc.Status, serving.ServiceStatus{}, "expected no status to be applied, '%v', but was '%v'")
+}
+
+func TestGitLabPushToPinnedService(t *testing.T) {
+	c := kube.NewFake(knative.NewPinnedService("diaspora", "diaspora-00001",
+		knative.WithBuild(knative.WithGitSource("http://example.com/mike/diaspora.git", "master"), knative.WithBuildTemplate("kaniko", nil, nil)),
+	))
+
+	rec := deliver(&webhooks.Server{Config: config, Client: c}, gitlabRequest(t))
+
+	errorIfNotEqual(t, rec.Code, http.StatusInternalServerError, "expected status %d but got %d")
+	errorIfNotEqual(t, len(c.Applied), 0, "expected %d objects to be applied but %d were")
+}
+
+func TestGiteaPush(t *testing.T) {
+	c := kube.NewFake()
+	r := request(t, "gitea-push.json")
+	r.Header.Set("X-Gitea-Event", "push")
+	r.Header.Set("X-GitHub-Event", "push")
+	r.Header.Set("X-Gitea-Signature", sign(t, "gitea-push.json", secret))
+	rec := deliver(&webhooks.Server{Config: config, Client: c}, r)
+
+	errorIfNotEqual(t, rec.Code, http.StatusOK, "expected status %d but got %d")
+
+	var b build.Build
+	if err := c.Get(kube.Builds, "webhooks-bffeb74", &b); err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, b.Spec.Steps[0].Image, knative.KanikoImage, "expected a kaniko step '%s' but got '%s'")
+}
+
+func TestIgnoredDeliveries(t *testing.T) {
+	other := config
+	other.Hooks = []webhooks.Hook{config.Hooks[0]}
+	other.Hooks[0].Branch = "release"
+
+	for name, tc := range map[string]struct {
+		server   *webhooks.Server
+		request  *http.Request
+		expected int
+	}{
+		"bad signature":  {&webhooks.Server{Config: config}, githubRequest(t, "push", "github-push.json", "wrong"), http.StatusUnauthorized},
+		"unknown repo":   {&webhooks.Server{Config: webhooks.Config{Hooks: config.Hooks[1:]}}, githubRequest(t, "push", "github-push.json", secret), http.StatusUnauthorized},
+		"other branch":   {&webhooks.Server{Config: other}, githubRequest(t, "push", "github-push.json", secret), http.StatusAccepted},
+		"ping":           {&webhooks.Server{Config: config}, githubRequest(t, "ping", "github-ping.json", secret), http.StatusAccepted},
+		"unsigned ping":  {&webhooks.Server{Config: config}, githubRequest(t, "ping", "github-ping.json", "wrong"), http.StatusUnauthorized},
+		"not a webhook":  {&webhooks.Server{Config: config}, request(t, "github-push.json"), http.StatusBadRequest},
+		"deleted branch": {&webhooks.Server{Config: config}, deletion(t), http.StatusAccepted},
+	} {
+		c := kube.NewFake()
+		tc.server.Client = c
+
+		rec := deliver(tc.server, tc.request)
+		errorIfNotEqual(t, rec.Code, tc.expected, name+": expected status %d but got %d")
+		errorIfNotEqual(t, len(c.Applied), 0, name+": expected %d objects to be applied but %d were")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	c, err := webhooks.LoadConfig(strings.NewReader(`
+hooks:
+- repo: https://github.com/me/app
+  secret: abc
+  build:
+    name: app
+    template: kaniko
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorIfNotEqual(t, c.Hooks[0].Branch, "master", "expected branch to default to '%s' but was '%s'")
+
+	for name, yml := range map[string]string{
+		"no hooks":        `hooks: []`,
+		"no secret":       "hooks:\n- repo: r\n  service: s",
+		"build & service": "hooks:\n- repo: r\n  secret: s\n  service: s\n  build: {name: b, template: t}",
+		"no template":     "hooks:\n- repo: r\n  secret: s\n  build: {name: b}",
+		"no image":        "hooks:\n- repo: r\n  secret: s\n  build: {name: b, dockerfile: Dockerfile}",
+		"bad branch":      "hooks:\n- repo: r\n  branch: '['\n  secret: s\n  service: s",
+	} {
+		if _, err := webhooks.LoadConfig(strings.NewReader(yml)); err == nil {
+			t.Errorf("%s: expected config to be invalid", name)
+		}
+	}
+}
+
+func deliver(s *webhooks.Server, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, r)
+	return rec
+}
+
+func githubRequest(t *testing.T, event, payload, key string) *http.Request {
+	r := request(t, payload)
+	r.Header.Set("X-GitHub-Event", event)
+	r.Header.Set("X-Hub-Signature-256", "sha256="+sign(t, payload, key))
+	return r
+}
+
+func gitlabRequest(t *testing.T) *http.Request {
+	r := request(t, "gitlab-push.json")
+	r.Header.Set("X-Gitlab-Event", "Push Hook")
+	r.Header.Set("X-Gitlab-Token", secret)
+	return r
+}
+
+// deletion is a github push which deletes the master branch
+func deletion(t *testing.T) *http.Request {
+	body := strings.Replace(string(read(t, "github-push.json")), `"deleted": false`, `"deleted": true`, 1)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("X-GitHub-Event", "push")
+	r.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func request(t *testing.T, payload string) *http.Request {
+	return httptest.NewRequest("POST", "/", bytes.NewReader(read(t, payload)))
+}
+
+func sign(t *testing.T, payload, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(read(t, payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func read(t *testing.T, payload string) []byte {
+	b, err := ioutil.ReadFile("testdata/" + payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func errorIfNotEqual(t *testing.T, actual, expected interface{}, msg string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf(msg, expected, actual)
+	}
+}